@@ -4,12 +4,15 @@ import (
 	"context"
 	"flag"
 	"log"
+	"net"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	"assignment/internal/dht"
+	"assignment/internal/logging"
 	"assignment/internal/transport"
 )
 
@@ -23,6 +26,114 @@ func main() {
 
 	// Get log file directory
 	logFilePath := flag.String("logfile", "", "Path to log file")
+
+	// Log backend, text lines or one JSON object per line
+	logFormat := flag.String("log-format", logging.FormatText, "Log format, 'text' or 'json'")
+
+	// Only log maintenance changes and failures, not routine no-op ticks
+	quietMaintenance := flag.Bool("quiet-maintenance", false, "Suppress routine maintenance logging")
+
+	// Number of bits in the identifier space, all nodes in a ring must agree
+	m := flag.Int("m", dht.M, "Number of bits in the identifier space")
+	hashName := flag.String("hash", dht.DefaultHasher.Name(), "Hash function placing keys and nodes on the ring, 'sha1', 'sha256' or 'fnv'. Must match on every node")
+
+	// Number of backup successors kept for fault tolerance
+	successors := flag.Int("successors", dht.DefaultSuccessorListSize, "Length of the successor list")
+
+	// Attempts per transport call in stabilization and lookups
+	retries := flag.Int("retries", dht.DefaultMaxRetries, "Attempts per maintenance/lookup RPC")
+
+	// Failed predecessor checks in a row before the predecessor is considered dead
+	predecessorFailures := flag.Int("predecessor-failures", dht.DefaultPredecessorFailureThreshold, "Consecutive failed predecessor checks, one per maintenance round, before the predecessor is cleared")
+
+	// How FindSuccessor resolves keys
+	lookup := flag.String("lookup", dht.LookupRecursive, "Lookup mode, 'recursive' or 'iterative'")
+
+	// Answer lookups from the local tables when they cover the key
+	preferLocal := flag.Bool("prefer-local", false, "Answer lookups covered by the finger table or successor list without remote hops")
+
+	// Ping a resolved finger before committing it
+	verifyFingers := flag.Bool("verify-fingers", false, "Check that a resolved finger is alive before updating the finger table")
+
+	// Store every key on the owner and its first successors
+	replication := flag.Int("replication", dht.DefaultReplicationFactor, "Number of nodes every key is stored on, the owner and the next nodes of the successor list")
+	readStrategy := flag.String("read-strategy", dht.ReadPrimary, "Copy the predecessor of a key's owner reads the key from, 'primary', 'round-robin' over the owner and its replicas or 'latency-weighted' by recent RPC latency")
+
+	// Capacity of the local store
+	maxKeys := flag.Int("max-keys", 0, "Number of keys the node stores at capacity, reported in /stats, 0 for unbounded")
+	maxBytes := flag.Int("max-bytes", 0, "Total length in bytes of the values the node stores at capacity, reported in /stats, 0 for unbounded")
+	rejectWhenFull := flag.Bool("reject-when-full", false, "Reject writes with 507 once -max-keys or -max-bytes is reached, updates of stored keys that fit are still accepted")
+
+	// Ceiling of the key expiry
+	maxTTL := flag.Duration("max-ttl", 0, "Longest a written key lives, a longer X-TTL-Seconds is shortened to it and keys without one expire after it, 0 for no ceiling")
+
+	// Per-key access counters of /hot-keys
+	hotKeys := flag.Int("hot-keys", dht.DefaultHotKeyCapacity, "Number of keys whose gets and puts are counted for /hot-keys, the least accessed is evicted for a new one, 0 disables counting")
+
+	// Encrypt stored values at rest
+	encryptionKey := flag.String("encryption-key", "", "Passphrase of the AES-GCM key stored values are encrypted with, disabled if empty")
+
+	// Separate listeners for inter-node RPCs and client traffic
+	rpcAddr := flag.String("rpc-addr", "", "Address advertised to peers for RPCs as host:port, overrides -hostname and -port")
+	clientAddr := flag.String("client-addr", "", "Separate listen address for client traffic as host:port")
+	bind := flag.String("bind", "", "IP address of the interface the rpc listener binds to, all interfaces if empty, independent of the advertised address")
+
+	// Serve and send all traffic over TLS
+	tlsCert := flag.String("tls-cert", "", "TLS certificate file, enables https on all listeners together with -tls-key")
+	tlsKey := flag.String("tls-key", "", "TLS private key file")
+	tlsCA := flag.String("tls-ca", "", "CA file to verify other nodes' certificates, the system pool if empty")
+
+	// Transport of the inter-node ring RPCs
+	rpcProtocol := flag.String("transport", "http", "Transport of the inter-node RPCs, 'http' or 'grpc'")
+
+	// Enable the built-in load generator endpoint
+	benchmark := flag.Bool("benchmark", false, "Enable the /benchmark load generator endpoint")
+
+	// Enable the endpoints forcing a node's state, for test harnesses
+	enableAdmin := flag.Bool("enable-admin", false, "Enable POST /admin/links to set the successor and predecessor of a node directly")
+
+	// Maintenance interval and rpc timeouts, raise them for high-latency deployments
+	timing := dht.DefaultTiming()
+	flag.DurationVar(&timing.MaintenanceInterval, "maintenance-interval", timing.MaintenanceInterval, "Base interval of the maintenance loop")
+	flag.DurationVar(&timing.FastTimeout, "fast-timeout", timing.FastTimeout, "Timeout of ring RPCs such as ping, lookups and link updates")
+	flag.DurationVar(&timing.SlowTimeout, "slow-timeout", timing.SlowTimeout, "Timeout of data transfers such as handoff and replication")
+	flag.DurationVar(&timing.ForwardTimeout, "forward-timeout", timing.ForwardTimeout, "Timeout of a forwarded storage request")
+
+	// Stretch the maintenance interval while peers answer 503 or time out, e.g. after a mass join
+	maxMaintenanceBackoff := flag.Int("max-maintenance-backoff", dht.DefaultMaxMaintenanceBackoff, "Largest multiple of the maintenance interval rounds are stretched to while peers are overloaded, 1 to disable")
+
+	// Answer the "/" endpoint index while crashed or left, e.g. for liveness probes
+	rootCrashExempt := flag.Bool("root-crash-exempt", false, "Answer the / endpoint index also while the node is crashed or has left")
+
+	// Key count ratio above which /skew reports the ring as unbalanced
+	skewThreshold := flag.Float64("skew-threshold", transport.DefaultSkewThreshold, "Most/least loaded key count ratio reported as unbalanced by /skew")
+
+	// Idle kept-alive connections per peer, reused by the ring RPCs and forwards
+	maxIdleConns := flag.Int("max-idle-conns-per-host", transport.DefaultMaxIdleConnsPerHost, "Idle connections kept open per peer for reuse by RPCs and forwarded requests")
+
+	// Bound on the forwarding chain of a storage request
+	maxHops := flag.Int("max-hops", transport.DefaultMaxHops, "Hops a storage request is forwarded over before it is refused with 508 Loop Detected")
+
+	// Overall deadline of a storage request across its hops
+	requestTimeout := flag.Duration("request-timeout", transport.DefaultRequestTimeout, "Overall deadline of a storage request across all its hops, answered with 504 once exceeded")
+
+	// Load shedding of client requests
+	maxInFlight := flag.Int("max-in-flight", 0, "Client requests handled at once before shedding with 503, 0 for no cap")
+	goroutineSoftLimit := flag.Int("goroutine-soft-limit", 0, "Goroutine count above which client requests are shed more aggressively, 0 to disable")
+
+	// Persist the stored keys across restarts
+	dataFile := flag.String("data-file", "", "Snapshot file of the stored keys, a write-ahead log is kept next to it. Reloaded on startup, empty to keep keys in memory only")
+
+	// Ring positions of the process
+	vnodes := flag.Int("vnodes", 1, "Number of virtual nodes the process holds on the ring, each with an id of its own, to spread the keys more evenly")
+
+	// Join an existing ring on startup, retried until the seed is up
+	join := flag.String("join", "", "Address of a ring node to join on startup as host:port")
+	joinTimeout := flag.Duration("join-timeout", time.Minute, "How long to retry the startup join before giving up")
+
+	// Leave the ring on SIGINT/SIGTERM instead of vanishing, neighbours then don't have to detect the failure
+	leaveOnShutdown := flag.Bool("leave-on-shutdown", false, "Leave the ring, handing off the keys, before shutting down on a signal")
+	leaveTimeout := flag.Duration("leave-timeout", 10*time.Second, "How long the leave on shutdown may take before the node shuts down anyway")
 	flag.Parse()
 
 	// Create or open log file
@@ -36,32 +147,163 @@ func main() {
 	log.SetOutput(file)
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
-	// Create node instance
-	node := dht.Create(*hostname + ":" + *port)
+	logger, err := logging.New(*logFormat, file)
+	if err != nil {
+		log.Fatalf("Failed to create logger: %v", err)
+	}
+
+	// The rpc address is the node's identity in the ring
+	if *rpcAddr != "" {
+		host, p, err := net.SplitHostPort(*rpcAddr)
+		if err != nil {
+			log.Fatalf("Invalid rpc address '%s': %v", *rpcAddr, err)
+		}
+		*hostname, *port = host, p
+	}
+
+	if err := timing.Validate(); err != nil {
+		log.Fatalf("Invalid timing: %v", err)
+	}
+
+	if (*tlsCert == "") != (*tlsKey == "") {
+		log.Fatalf("-tls-cert and -tls-key must be set together")
+	}
+
+	if *rejectWhenFull && *maxKeys <= 0 && *maxBytes <= 0 {
+		log.Fatalf("-reject-when-full requires -max-keys or -max-bytes")
+	}
+
+	hasher, err := dht.HasherByName(*hashName)
 	if err != nil {
-		log.Fatalf("Failed to create node: %v", err)
+		log.Fatalf("Invalid -hash: %v", err)
+	}
+
+	if *vnodes < 1 {
+		log.Fatalf("-vnodes must be at least 1")
+	}
+
+	// Create the node instances, one per vnode. Vnode i is placed by "host:port#i", the first keeps
+	// "host:port", and persists to its own data file.
+	nodes := make([]*dht.Node, *vnodes)
+	for i := range nodes {
+		nodeDataFile := *dataFile
+		if nodeDataFile != "" && i > 0 {
+			nodeDataFile += "." + strconv.Itoa(i)
+		}
+		nodes[i] = dht.Create(dht.VnodeAddress(*hostname+":"+*port, i),
+			dht.WithQuietMaintenance(*quietMaintenance),
+			dht.WithM(*m),
+			dht.WithHasher(hasher),
+			dht.WithSuccessorListSize(*successors),
+			dht.WithMaxRetries(*retries),
+			dht.WithPredecessorFailureThreshold(*predecessorFailures),
+			dht.WithVerifyFingers(*verifyFingers),
+			dht.WithLookupMode(*lookup),
+			dht.WithPreferLocalFingers(*preferLocal),
+			dht.WithEncryptionKey(*encryptionKey),
+			dht.WithReplicationFactor(*replication),
+			dht.WithReadStrategy(*readStrategy),
+			dht.WithMaxKeys(*maxKeys, *rejectWhenFull),
+			dht.WithMaxBytes(*maxBytes),
+			dht.WithHotKeyCapacity(*hotKeys),
+			dht.WithMaxTTL(*maxTTL),
+			dht.WithTiming(timing),
+			dht.WithMaxMaintenanceBackoff(*maxMaintenanceBackoff),
+			dht.WithDataFile(nodeDataFile),
+			dht.WithLogger(logger),
+		)
+		if err := nodes[i].PersistenceErr(); err != nil {
+			log.Fatalf("Failed to restore data from %s: %v", nodeDataFile, err)
+		}
+	}
+	node := nodes[0]
+	var extraVnodes []dht.INode
+	for _, vnode := range nodes[1:] {
+		extraVnodes = append(extraVnodes, vnode)
 	}
 
 	// Create HTTPTransport instance
-	transport, err := transport.New(*hostname, *port, node)
+	httpTransport, err := transport.New(*hostname, *port, node,
+		transport.WithBenchmark(*benchmark),
+		transport.WithAdmin(*enableAdmin),
+		transport.WithClientAddr(*clientAddr),
+		transport.WithBindAddr(*bind),
+		transport.WithSkewThreshold(*skewThreshold),
+		transport.WithMaxHops(*maxHops),
+		transport.WithRequestTimeout(*requestTimeout),
+		transport.WithMaxIdleConnsPerHost(*maxIdleConns),
+		transport.WithRootCrashExempt(*rootCrashExempt),
+		transport.WithTLS(*tlsCert, *tlsKey, *tlsCA),
+		transport.WithMaxInFlight(*maxInFlight),
+		transport.WithGoroutineSoftLimit(*goroutineSoftLimit),
+		transport.WithTiming(timing),
+		transport.WithVnodes(extraVnodes...),
+		transport.WithLogger(logger),
+	)
 	if err != nil {
 		log.Fatalf("Failed to create server: %v", err)
 	}
 
+	// Serve the ring RPCs over gRPC if asked to, client traffic and handoff stay on HTTP
+	var rpcTransport interface {
+		dht.Transport
+		Start() error
+		Stop(ctx context.Context) error
+		Address() string
+	}
+	switch *rpcProtocol {
+	case "http":
+		rpcTransport = httpTransport
+	case "grpc":
+		rpcTransport = transport.NewGRPC(httpTransport)
+	default:
+		log.Fatalf("Unknown transport %q, expected http or grpc", *rpcProtocol)
+	}
+
 	// Start server in goroutine
 	go func() {
-		if err := transport.Start(); err != nil {
+		if err := rpcTransport.Start(); err != nil {
 			log.Fatalf("Server error: %v", err)
 		}
 	}()
 
-	log.Printf("Server started on '%s'", transport.Address())
+	logger.Info("Main", "server started", "rpc_address", rpcTransport.Address())
 
-	// Set transport so that node can use it to communicate with other nodes
-	node.SetTransport(transport)
+	// Set transport so that the nodes can use it to communicate with other nodes, then start
+	// their maintenance goroutines, stopped with their RPCs in flight on shutdown
+	maintenanceCtx, stopMaintenance := context.WithCancel(context.Background())
+	defer stopMaintenance()
+	for _, vnode := range nodes {
+		vnode.SetTransport(rpcTransport)
+		go vnode.RunMaintenance(maintenanceCtx)
+	}
 
-	// Start the maintenance goroutines
-	go node.RunMaintenance(context.Background())
+	// Join the ring through the seed, the node stays a ring of its own if the seed never comes up
+	if *join != "" {
+		go func() {
+			if err := httpTransport.JoinWithRetry(context.Background(), *join, *joinTimeout); err != nil {
+				logger.Error("Main", "startup join failed, running as a ring of its own", "err", err)
+			}
+		}()
+	} else if peers := node.RestoredPeers(); len(peers) > 0 {
+		// Rejoin the ring of the restored data through the nodes linked to before the restart,
+		// other vnodes of this process are not in that ring yet
+		go func() {
+			for _, peer := range peers {
+				if process, _ := dht.SplitVnode(peer); process == httpTransport.Address() {
+					continue
+				}
+				if err := httpTransport.Join(peer); err == nil {
+					logger.Info("Main", "rejoined the ring after restart", "peer", peer)
+					return
+				}
+			}
+			logger.Warn("Main", "no peer of the previous run reachable, running as a ring of its own", "peers", peers)
+			joinVnodes(httpTransport, *joinTimeout, logger)
+		}()
+	} else if len(nodes) > 1 {
+		go joinVnodes(httpTransport, *joinTimeout, logger)
+	}
 
 	// Channel to listen for OS signals
 	stop := make(chan os.Signal, 1)
@@ -70,13 +312,54 @@ func main() {
 	// Wait for shutdown signal
 	<-stop
 
+	// Hand off the keys and link the neighbours around the node while it still serves their RPCs
+	if *leaveOnShutdown {
+		leaveOnShutdownSignal(httpTransport, *leaveTimeout, logger)
+	}
+	stopMaintenance()
+
 	// Graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	log.Println("Server received shutdown signal")
+	logger.Info("Main", "server received shutdown signal")
 
-	if err := transport.Stop(ctx); err != nil {
+	if err := rpcTransport.Stop(ctx); err != nil {
 		log.Fatalf("Server shutdown error: %v", err)
 	}
 }
+
+// leaveOnShutdownSignal leaves the ring, giving up after the timeout. A leave that fails or times
+// out leaves the node to be detected as failed by its neighbours.
+func leaveOnShutdownSignal(t *transport.HTTPTransport, timeout time.Duration, logger logging.Logger) {
+
+	logger.Info("Main", "leaving the ring before shutting down", "timeout", timeout)
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := t.Leave()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			logger.Error("Main", "failed to leave the ring on shutdown", "err", err)
+			return
+		}
+		logger.Info("Main", "left the ring")
+	case <-time.After(timeout):
+		logger.Error("Main", "leave on shutdown timed out, shutting down anyway", "timeout", timeout)
+	}
+}
+
+// joinVnodes links the vnodes of a process that did not join a ring into a ring of their own
+// through the first one, retried until the server is up
+func joinVnodes(t *transport.HTTPTransport, timeout time.Duration, logger logging.Logger) {
+	if len(t.Vnodes()) == 1 {
+		return
+	}
+	if err := t.JoinWithRetry(context.Background(), t.Address(), timeout); err != nil {
+		logger.Error("Main", "failed to link the vnodes into a ring", "err", err)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"assignment/internal/dht"
+	"assignment/internal/logging"
+	"assignment/internal/transport"
+)
+
+func quietLogger() logging.Logger {
+	return logging.NewText(log.New(io.Discard, "", 0))
+}
+
+// freePort returns a port nothing listens on
+func freePort(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer lis.Close()
+	_, port, _ := net.SplitHostPort(lis.Addr().String())
+	return port
+}
+
+// startServer starts a node and its transport like main does, waiting until it answers pings
+func startServer(t *testing.T) (*dht.Node, *transport.HTTPTransport) {
+	t.Helper()
+	port := freePort(t)
+	node := dht.Create("127.0.0.1:"+port, dht.WithLogger(quietLogger()))
+	tr, err := transport.New("127.0.0.1", port, node, transport.WithLogger(quietLogger()))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	node.SetTransport(tr)
+	go func() { _ = tr.Start() }()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = tr.Stop(ctx)
+	})
+
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		resp, err := http.Get("http://" + tr.Address() + "/ping")
+		if err == nil {
+			resp.Body.Close()
+			return node, tr
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server on %s not up: %v", tr.Address(), err)
+		}
+	}
+}
+
+func TestLeaveOnShutdownNotifiesNeighbours(t *testing.T) {
+	ctx := context.Background()
+	var nodes []*dht.Node
+	var transports []*transport.HTTPTransport
+	for i := range 3 {
+		node, tr := startServer(t)
+		if i > 0 {
+			if err := tr.Join(nodes[0].Address()); err != nil {
+				t.Fatalf("Join: %v", err)
+			}
+		}
+		nodes = append(nodes, node)
+		transports = append(transports, tr)
+	}
+	for range 3 {
+		for _, node := range nodes {
+			node.Stabilize(ctx)
+		}
+	}
+
+	leaving, tr := nodes[1], transports[1]
+	_, successor := leaving.Successor()
+	_, predecessor := leaving.Predecessor()
+	key := ""
+	for i := 0; key == ""; i++ {
+		if candidate := fmt.Sprintf("key-%d", i); leaving.NextHop(candidate) == "" {
+			key = candidate
+		}
+	}
+	if _, _, err := leaving.Put(key, []byte("value"), 0); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	// The shutdown path of main: leave while the server is up, then stop it
+	leaveOnShutdownSignal(tr, 5*time.Second, quietLogger())
+	stopCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := tr.Stop(stopCtx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	// The neighbours were linked around the node and the successor took its key before it closed
+	byAddress := map[string]*dht.Node{nodes[0].Address(): nodes[0], nodes[2].Address(): nodes[2]}
+	if _, got := byAddress[predecessor].Successor(); got != successor {
+		t.Errorf("successor of the predecessor after the shutdown = %s, want %s", got, successor)
+	}
+	if _, got := byAddress[successor].Predecessor(); got != predecessor {
+		t.Errorf("predecessor of the successor after the shutdown = %s, want %s", got, predecessor)
+	}
+	if value, _, err := byAddress[successor].Get(key); err != nil || string(value.Value) != "value" {
+		t.Errorf("key on the successor after the shutdown = %q, %v, want \"value\"", value.Value, err)
+	}
+}
+
+func TestLeaveOnShutdownGivesUpAfterTimeout(t *testing.T) {
+	node, tr := startServer(t)
+
+	// A successor that accepts connections but never answers holds the handoff up
+	blackhole, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer blackhole.Close()
+	node.SetSuccessor(blackhole.Addr().String())
+	if _, _, err := node.Put("key", []byte("value"), 0); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	const timeout = 200 * time.Millisecond
+	start := time.Now()
+	leaveOnShutdownSignal(tr, timeout, quietLogger())
+	if elapsed := time.Since(start); elapsed < timeout || elapsed > timeout+time.Second {
+		t.Errorf("leave on shutdown returned after %v, want about the %v timeout", elapsed, timeout)
+	}
+}
@@ -0,0 +1,206 @@
+package client
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"assignment/internal/dht"
+)
+
+// Default attempts of a request before giving up, override with WithRetries
+const DefaultRetries = 3
+
+// Backoff before the first retry, doubled after every failed attempt up to maxRetryBackoff
+const (
+	retryBackoff    = 100 * time.Millisecond
+	maxRetryBackoff = 2 * time.Second
+)
+
+// Unavailable reason of a 503 from a node that is down, retried on the next entry node right away
+const reasonCrashed = "crashed"
+
+// Client performs typed ring operations against the HTTP endpoints of the nodes.
+// Requests go to the entry nodes in turn, a node that can't be reached or answers 503 is
+// retried on the next one. Redirects to the owner of a key are followed.
+type Client struct {
+	nodes      []string
+	next       atomic.Uint64 // entry node of the next request
+	scheme     string
+	httpClient *http.Client
+	retries    int
+	m          int
+	hasher     dht.Hasher
+}
+
+// Option configures optional behaviour of the client in New
+type Option func(*Client)
+
+// WithHTTPClient sends the requests with the given http client, e.g. to set a custom timeout
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTLS talks https to the nodes, verified with the given config
+func WithTLS(config *tls.Config) Option {
+	return func(c *Client) {
+		c.scheme = "https"
+		c.httpClient.Transport = &http.Transport{TLSClientConfig: config}
+	}
+}
+
+// WithRetries sets the attempts of a request, the first one included
+func WithRetries(retries int) Option {
+	return func(c *Client) {
+		if retries > 0 {
+			c.retries = retries
+		}
+	}
+}
+
+// WithIdBits sets the number of bits of the ring's identifier space, must match the nodes' -m
+func WithIdBits(m int) Option {
+	return func(c *Client) {
+		if m > 0 {
+			c.m = m
+		}
+	}
+}
+
+// WithHasher sets the hash function of the ring, must match the nodes' -hash
+func WithHasher(h dht.Hasher) Option {
+	return func(c *Client) {
+		if h != nil {
+			c.hasher = h
+		}
+	}
+}
+
+// New returns a client sending requests to the entry nodes, given as host:port
+func New(nodes []string, opts ...Option) (*Client, error) {
+
+	if len(nodes) == 0 {
+		return nil, errors.New("no entry nodes given")
+	}
+
+	c := &Client{
+		nodes:      nodes,
+		scheme:     "http",
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		retries:    DefaultRetries,
+		m:          dht.M,
+		hasher:     dht.DefaultHasher,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// url returns the url of the path on the node at the address, a vnode is addressed with "?vnode="
+func (c *Client) url(addr string, path string) string {
+	host, vnode := dht.SplitVnode(addr)
+	if vnode == 0 {
+		return c.scheme + "://" + host + path
+	}
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return c.scheme + "://" + host + path + separator + "vnode=" + strconv.Itoa(vnode)
+}
+
+// do sends the request to the given node, or to the entry nodes in turn if addr is empty,
+// and returns the body of a 2xx response. Other responses are returned as a *StatusError.
+func (c *Client) do(method string, addr string, path string, body []byte) ([]byte, error) {
+	respBody, _, err := c.doNode(method, addr, path, body)
+	return respBody, err
+}
+
+// doNode is do that also returns the node that answered
+func (c *Client) doNode(method string, addr string, path string, body []byte) ([]byte, string, error) {
+
+	var err error
+	backoff := retryBackoff
+
+	for attempt := 0; attempt < c.retries; attempt++ {
+
+		target := addr
+		if target == "" {
+			target = c.nodes[c.next.Load()%uint64(len(c.nodes))]
+		}
+
+		var respBody []byte
+		respBody, err = c.send(method, target, path, body)
+		if err == nil || !retryable(err) {
+			return respBody, target, err
+		}
+
+		// Move on to the next entry node, a crashed one is skipped without waiting
+		if addr == "" {
+			c.next.Add(1)
+			var statusErr *StatusError
+			if errors.As(err, &statusErr) && statusErr.Reason == reasonCrashed {
+				continue
+			}
+		}
+		if attempt < c.retries-1 {
+			time.Sleep(jitter(backoff))
+			backoff = min(backoff*2, maxRetryBackoff)
+		}
+	}
+
+	return nil, "", err
+}
+
+// jitter returns a random duration in [d/2, d), so clients that failed together don't retry in lockstep
+func jitter(d time.Duration) time.Duration {
+	return d/2 + rand.N(d/2)
+}
+
+// send performs a single attempt of the request
+func (c *Client) send(method string, addr string, path string, body []byte) ([]byte, error) {
+
+	// A bytes.Reader lets the http client replay the body on a redirect
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, c.url(addr, path), reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to %s failed: %w", addr, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %w", addr, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &StatusError{
+			Node:       addr,
+			StatusCode: resp.StatusCode,
+			Reason:     resp.Header.Get("X-DHT-Unavailable-Reason"),
+			Message:    strings.TrimSpace(string(respBody)),
+		}
+	}
+
+	return respBody, nil
+}
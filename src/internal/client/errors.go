@@ -0,0 +1,51 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Errors a StatusError unwraps to, match them with errors.Is
+var (
+	ErrNotFound    = errors.New("key not found")
+	ErrUnavailable = errors.New("node unavailable")
+	ErrLoop        = errors.New("routing loop detected")
+)
+
+// StatusError is a non-2xx response of a node
+type StatusError struct {
+	Node       string // node that answered
+	StatusCode int
+	Reason     string // X-DHT-Unavailable-Reason of a 503, e.g. "crashed" or "overloaded"
+	Message    string // response body
+}
+
+func (e *StatusError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("%s: %d %s (%s): %s", e.Node, e.StatusCode, http.StatusText(e.StatusCode), e.Reason, e.Message)
+	}
+	return fmt.Sprintf("%s: %d %s: %s", e.Node, e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Unwrap maps the status code to ErrNotFound, ErrUnavailable or ErrLoop
+func (e *StatusError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusServiceUnavailable:
+		return ErrUnavailable
+	case http.StatusLoopDetected:
+		return ErrLoop
+	}
+	return nil
+}
+
+// retryable reports whether another attempt, possibly on another node, may succeed
+func retryable(err error) bool {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return true // the node could not be reached
+	}
+	return statusErr.StatusCode == http.StatusServiceUnavailable
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"assignment/internal/dht"
+)
+
+// NodeInfo is the response of "/node-info"
+type NodeInfo struct {
+	NodeHash    string   `json:"node_hash"`
+	Successor   string   `json:"successor"`
+	Predecessor string   `json:"predecessor"`
+	Others      []string `json:"others"` // finger table
+	Epoch       uint64   `json:"epoch"`
+	KeyCount    int      `json:"key_count"`
+}
+
+// Get returns the value of the key, ErrNotFound if the ring does not store it
+func (c *Client) Get(key string) (string, error) {
+	body, err := c.do(http.MethodGet, "", "/storage/"+url.PathEscape(key), nil)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// GetConsistent returns the value of the key agreed on by the copies the level requires,
+// ErrNotFound if they agree the ring does not store it
+func (c *Client) GetConsistent(key string, level dht.Consistency) (string, error) {
+	body, err := c.do(http.MethodGet, "", "/storage/"+url.PathEscape(key)+"?consistency="+level.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// Exists reports whether the ring stores the key, without transferring its value
+func (c *Client) Exists(key string) (bool, error) {
+	_, err := c.do(http.MethodHead, "", "/storage/"+url.PathEscape(key), nil)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Put stores the value under the key
+func (c *Client) Put(key string, value string) error {
+	_, err := c.do(http.MethodPut, "", "/storage/"+url.PathEscape(key), []byte(value))
+	return err
+}
+
+// Delete deletes the key, ErrNotFound if the ring does not store it
+func (c *Client) Delete(key string) error {
+	_, err := c.do(http.MethodDelete, "", "/storage/"+url.PathEscape(key), nil)
+	return err
+}
+
+// NodeInfo returns the id, links and finger table of the node at the address
+func (c *Client) NodeInfo(addr string) (NodeInfo, error) {
+	var info NodeInfo
+	body, err := c.do(http.MethodGet, addr, "/node-info", nil)
+	if err != nil {
+		return info, err
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return info, fmt.Errorf("failed to decode node info from %s: %w", addr, err)
+	}
+	return info, nil
+}
+
+// Network returns the nodes of the ring in ring order, starting at the node at the address
+func (c *Client) Network(addr string) ([]string, error) {
+	var nodes []string
+	body, err := c.do(http.MethodGet, addr, "/network", nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, &nodes); err != nil {
+		return nil, fmt.Errorf("failed to decode network from %s: %w", addr, err)
+	}
+	return nodes, nil
+}
+
+// FindOwner returns the address of the node responsible for the key.
+// Walks the ring like an iterative lookup over "/closest-preceding", an rpc endpoint, so the
+// entry nodes must be rpc addresses on nodes started with -client-addr.
+func (c *Client) FindOwner(key string) (string, error) {
+
+	keyId := dht.HashToRingId(c.hasher, key, 1<<c.m)
+
+	type step struct {
+		Closest   string `json:"closest"`
+		Successor string `json:"successor"`
+	}
+	path := "/closest-preceding?key=" + strconv.Itoa(keyId)
+
+	// An entry node that is down is retried on the next one, later steps must reach their node
+	current := ""
+	for range 2 * c.m {
+
+		body, node, err := c.doNode(http.MethodGet, current, path, nil)
+		if err != nil {
+			return "", err
+		}
+		var s step
+		if err := json.Unmarshal(body, &s); err != nil {
+			return "", fmt.Errorf("failed to decode lookup step from %s: %w", node, err)
+		}
+		current = node
+
+		if dht.InIntervalRightInclusive(keyId, dht.HashToRingId(c.hasher, current, 1<<c.m), dht.HashToRingId(c.hasher, s.Successor, 1<<c.m)) {
+			return s.Successor, nil
+		}
+
+		// No closer node known, the successor is the best answer
+		next := s.Closest
+		if next == current || next == "" {
+			next = s.Successor
+		}
+		current = next
+	}
+
+	return "", fmt.Errorf("lookup of key %q did not converge after %d steps", key, 2*c.m)
+}
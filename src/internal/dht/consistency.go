@@ -0,0 +1,112 @@
+package dht
+
+import (
+	"fmt"
+)
+
+// Consistency is the number of copies of a key a read must agree on, see GetConsistent
+type Consistency int
+
+const (
+	ConsistencyOne    Consistency = iota // the owner's value, the default
+	ConsistencyQuorum                    // a majority of the replicationFactor copies
+	ConsistencyAll                       // every one of the replicationFactor copies
+)
+
+// ParseConsistency parses a read consistency level, "one", "quorum" or "all", empty is "one"
+func ParseConsistency(s string) (Consistency, error) {
+	switch s {
+	case "", "one":
+		return ConsistencyOne, nil
+	case "quorum":
+		return ConsistencyQuorum, nil
+	case "all":
+		return ConsistencyAll, nil
+	}
+	return ConsistencyOne, fmt.Errorf("unknown consistency level %q, expected one, quorum or all", s)
+}
+
+// String returns the name of the level as accepted by ParseConsistency
+func (c Consistency) String() string {
+	switch c {
+	case ConsistencyQuorum:
+		return "quorum"
+	case ConsistencyAll:
+		return "all"
+	}
+	return "one"
+}
+
+// required returns the number of copies out of the replication factor that must agree
+func (c Consistency) required(replicationFactor int) int {
+	switch c {
+	case ConsistencyQuorum:
+		return replicationFactor/2 + 1
+	case ConsistencyAll:
+		return replicationFactor
+	}
+	return 1
+}
+
+// GetConsistent gets a value from the ring like Get, the owner votes with its own copy and the
+// replicas' and returns the most common one if at least as many copies as the level requires
+// agree on it, copies agree if they have the same value and version. A missing copy votes for the
+// key being absent. Replicas that can't be reached don't vote, ErrInconsistent is returned if too
+// few copies agree.
+func (n *Node) GetConsistent(key string, level Consistency) (value Versioned, nextAddress string, err error) {
+
+	if level == ConsistencyOne {
+		return n.Get(key)
+	}
+
+	keyId := n.ringId(key)
+	if !n.owns(keyId) {
+		_, closestPreceedingAddr := n.closestPrecedingNode(keyId)
+		return Versioned{}, closestPreceedingAddr, nil
+	}
+	n.access.record(key, false)
+
+	// A vote of a copy, found is false for a copy missing the key. The value is held as a string
+	// so votes can be counted in a map.
+	type vote struct {
+		value   string
+		version uint64
+		found   bool
+	}
+	votes := make(map[vote]int)
+
+	ownValue, ownFound := n.loadVersioned(&n.data, key)
+	own := vote{string(ownValue.Value), ownValue.Version, ownFound}
+	votes[own]++
+	for _, addr := range n.replicaSet() {
+		v, found, err := n.transport.GetReplica(addr, key)
+		if err != nil {
+			n.logger.Error("GetConsistent", "failed to get replica", "key", key, "replica", addr, "err", err)
+			continue
+		}
+		votes[vote{string(v.Value), v.Version, found}]++
+	}
+
+	// The owner's copy wins a tie, it is the only writer of the key
+	best, count := own, votes[own]
+	for v, c := range votes {
+		if c > count {
+			best, count = v, c
+		}
+	}
+
+	required := level.required(n.replicationFactor)
+	if count < required {
+		n.logger.Warn("GetConsistent", "too few copies agree", "key", key, "level", level.String(), "agree", count, "required", required, "versions", len(votes))
+		return Versioned{}, "", fmt.Errorf("%w: %d of %d copies agree at consistency %s, %d required", ErrInconsistent, count, n.replicationFactor, level, required)
+	}
+
+	n.logger.Info("GetConsistent", "read key", "key", key, "key_id", keyId, "level", level.String(), "agree", count, "found", best.found)
+	if ownFound && best == own {
+		n.repairReplicas(key, ownValue)
+	}
+	if !best.found {
+		return Versioned{}, "", ErrKeyNotFound
+	}
+	return Versioned{Value: []byte(best.value), Version: best.version}, "", nil
+}
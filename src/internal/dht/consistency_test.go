@@ -0,0 +1,116 @@
+package dht
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// replicatedKey builds a ring of three nodes keeping three copies of every key and puts "value"
+// on a key owned by the first node, the other two hold its replicas
+func replicatedKey(t *testing.T) ([]*Node, string) {
+	t.Helper()
+	nodes := BuildRing(3, WithReplicationFactor(3), WithSuccessorListSize(2))
+
+	// Fill the successor lists, which place the replicas
+	for range 3 {
+		for _, node := range nodes {
+			node.Stabilize(context.Background())
+		}
+	}
+
+	key := ""
+	for i := 0; key == ""; i++ {
+		if candidate := fmt.Sprintf("key-%d", i); ownerNode(nodes, candidate) == nodes[0] {
+			key = candidate
+		}
+	}
+	if _, _, err := nodes[0].Put(key, []byte("value"), 0); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	return nodes, key
+}
+
+func TestParseConsistency(t *testing.T) {
+	for s, want := range map[string]Consistency{"": ConsistencyOne, "one": ConsistencyOne, "quorum": ConsistencyQuorum, "all": ConsistencyAll} {
+		if got, err := ParseConsistency(s); err != nil || got != want {
+			t.Errorf("ParseConsistency(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+	if _, err := ParseConsistency("most"); err == nil {
+		t.Error("ParseConsistency(\"most\") succeeded")
+	}
+}
+
+func TestConsistentReadOfDivergentCopies(t *testing.T) {
+	tests := []struct {
+		name    string
+		diverge func(owner *Node, replicas []*Node, key string)
+		one     string // value of a read at consistency one, the owner's copy
+	}{
+		{
+			name: "stale replica",
+			diverge: func(owner *Node, replicas []*Node, key string) {
+				replicas[1].AcceptReplicas(map[string]Versioned{key: {Value: []byte("stale"), Version: 7}})
+			},
+			one: "value",
+		},
+		{
+			name: "diverged owner",
+			diverge: func(owner *Node, replicas []*Node, key string) {
+				owner.data.Store(key, &storedValue{value: []byte("diverged"), version: 1})
+			},
+			one: "diverged",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, key := replicatedKey(t)
+			owner, replicas := nodes[0], nodes[1:]
+			tt.diverge(owner, replicas, key)
+
+			// Two of the three copies agree, too few for all. All is read first, a successful
+			// read repairs the replicas in the background.
+			if _, _, err := owner.GetConsistent(key, ConsistencyAll); !errors.Is(err, ErrInconsistent) {
+				t.Errorf("GetConsistent at all = %v, want ErrInconsistent", err)
+			}
+			if value, _, err := owner.GetConsistent(key, ConsistencyQuorum); err != nil || string(value.Value) != "value" || value.Version != 1 {
+				t.Errorf("GetConsistent at quorum = %q at version %d, %v, want the majority \"value\" at version 1", value.Value, value.Version, err)
+			}
+			if value, _, err := owner.GetConsistent(key, ConsistencyOne); err != nil || string(value.Value) != tt.one {
+				t.Errorf("GetConsistent at one = %q, %v, want the owner's %q", value.Value, err, tt.one)
+			}
+
+			// A node not owning the key points at the next hop like Get
+			if _, next, err := replicas[0].GetConsistent(key, ConsistencyQuorum); err != nil || next == "" {
+				t.Errorf("GetConsistent on a non-owner = %q, %v, want a next hop", next, err)
+			}
+		})
+	}
+}
+
+func TestQuorumReadRepairsStaleReplica(t *testing.T) {
+	nodes, key := replicatedKey(t)
+	owner, stale := nodes[0], nodes[2]
+	stale.AcceptReplicas(map[string]Versioned{key: {Value: []byte("stale"), Version: 7}})
+
+	if _, _, err := owner.GetConsistent(key, ConsistencyQuorum); err != nil {
+		t.Fatalf("GetConsistent at quorum: %v", err)
+	}
+
+	// The owner's value won, the stale replica converges on it and every copy agrees again
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if replica, ok := stale.Replica(key); ok && string(replica.Value) == "value" && replica.Version == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stale replica was not repaired by the quorum read")
+		}
+	}
+	if value, _, err := owner.GetConsistent(key, ConsistencyAll); err != nil || string(value.Value) != "value" {
+		t.Errorf("GetConsistent at all after the repair = %q, %v, want \"value\"", value.Value, err)
+	}
+}
@@ -0,0 +1,63 @@
+package dht
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+)
+
+// Marker prefixed to encrypted values, values without it are plaintext,
+// e.g. stored before encryption was enabled
+const encryptedMarker = "enc:v1:"
+
+// valueCipher encrypts values at rest with AES-256-GCM
+type valueCipher struct {
+	aead cipher.AEAD
+}
+
+// newValueCipher derives the AES-256 key from the SHA-256 of the passphrase
+func newValueCipher(passphrase string) (*valueCipher, error) {
+	key := sha256.Sum256([]byte(passphrase))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gcm: %w", err)
+	}
+	return &valueCipher{aead: aead}, nil
+}
+
+// encrypt returns the marked ciphertext of the value, the random nonce is prepended to the sealed value
+func (c *valueCipher) encrypt(value []byte) []byte {
+	nonce := make([]byte, c.aead.NonceSize())
+	_, _ = rand.Read(nonce) // never fails
+	sealed := c.aead.Seal(nonce, nonce, value, nil)
+	return []byte(encryptedMarker + base64.StdEncoding.EncodeToString(sealed))
+}
+
+// decrypt returns the plaintext of a stored value, unmarked values are returned as is
+func (c *valueCipher) decrypt(stored []byte) ([]byte, error) {
+	encoded, ok := bytes.CutPrefix(stored, []byte(encryptedMarker))
+	if !ok {
+		return stored, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode encrypted value: %w", err)
+	}
+	if len(sealed) < c.aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted value too short")
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,95 @@
+package dht
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	plaintext := []byte("secret value \x00\xff")
+	node := Create("node-0", WithLogger(quietLogger()), WithEncryptionKey("passphrase"))
+	if _, _, err := node.Put("key", plaintext, 0); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	value, _, err := node.Get("key")
+	if err != nil || !bytes.Equal(value.Value, plaintext) {
+		t.Fatalf("Get = %q, %v, want %q", value.Value, err, plaintext)
+	}
+	if dump := node.Dump(true); !bytes.Equal(dump["key"], plaintext) {
+		t.Errorf("Dump = %q, want the plaintext %q", dump["key"], plaintext)
+	}
+
+	// The store only holds the ciphertext, a fresh nonce per write
+	v, _ := node.data.Load("key")
+	stored := v.(*storedValue).value
+	if !bytes.HasPrefix(stored, []byte(encryptedMarker)) || bytes.Contains(stored, []byte("secret")) {
+		t.Errorf("stored value %q is not encrypted", stored)
+	}
+	if again := node.cipher.encrypt(plaintext); bytes.Equal(again, stored) {
+		t.Error("encrypting the same value twice gave the same ciphertext")
+	}
+}
+
+func TestEncryptionOnDisk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	node := Create("127.0.0.1:8000", WithLogger(quietLogger()), WithDataFile(path), WithEncryptionKey("passphrase"))
+	if _, _, err := node.Put("key", []byte("secret value"), 0); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := node.compact(); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	node.persist.log.Close()
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read the snapshot: %v", err)
+	}
+	var snapshot persistSnapshot
+	if err := json.Unmarshal(raw, &snapshot); err != nil || len(snapshot.Data) != 1 {
+		t.Fatalf("snapshot = %s, %v, want a single key", raw, err)
+	}
+	if data := snapshot.Data[0].Data; !bytes.HasPrefix(data, []byte(encryptedMarker)) || bytes.Contains(data, []byte("secret")) {
+		t.Errorf("snapshot holds %q, want the ciphertext", data)
+	}
+
+	// The same key reads the value after a restart
+	node = Create("127.0.0.1:8000", WithLogger(quietLogger()), WithDataFile(path), WithEncryptionKey("passphrase"))
+	defer node.persist.log.Close()
+	if value, _, err := node.Get("key"); err != nil || string(value.Value) != "secret value" {
+		t.Errorf("Get after the restart = %q, %v, want \"secret value\"", value.Value, err)
+	}
+}
+
+func TestDecryptWithWrongKey(t *testing.T) {
+	right, err := newValueCipher("passphrase")
+	if err != nil {
+		t.Fatalf("newValueCipher: %v", err)
+	}
+	wrong, err := newValueCipher("another passphrase")
+	if err != nil {
+		t.Fatalf("newValueCipher: %v", err)
+	}
+
+	sealed := right.encrypt([]byte("secret value"))
+	if _, err := wrong.decrypt(sealed); err == nil {
+		t.Error("decrypt with the wrong key succeeded")
+	}
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-2] ^= 1
+	if _, err := right.decrypt(tampered); err == nil {
+		t.Error("decrypt of a tampered value succeeded")
+	}
+
+	// A node with the wrong key treats the value as absent instead of returning the ciphertext
+	node := Create("node-0", WithLogger(quietLogger()), WithEncryptionKey("another passphrase"))
+	node.data.Store("key", &storedValue{value: sealed, version: 1})
+	if _, _, err := node.Get("key"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get with the wrong key = %v, want ErrKeyNotFound", err)
+	}
+}
@@ -0,0 +1,28 @@
+package dht
+
+import "errors"
+
+var (
+	// ErrKeyMoving is returned for writes to a key that is being moved to its owner, the write should be retried
+	ErrKeyMoving = errors.New("key is being moved")
+
+	// ErrKeyNotFound is returned by the owner of a key that it does not store, a node that is not
+	// the owner returns the next address instead so the request can be forwarded
+	ErrKeyNotFound = errors.New("key not found")
+
+	// ErrStorageFull is returned for a write of a new key to an owner at capacity that rejects writes when full
+	ErrStorageFull = errors.New("storage full")
+
+	// ErrInconsistent is returned for a read whose consistency level too few copies of the key agree on
+	ErrInconsistent = errors.New("replicas do not agree")
+
+	// ErrPreconditionFailed is returned for a conditional write of a key whose current value is not the expected one
+	ErrPreconditionFailed = errors.New("precondition failed")
+
+	// ErrIdCollision is returned by Join if the successor has the node's ring id under another address,
+	// the two nodes can't both own the id. The node has to be started with another address or -m.
+	ErrIdCollision = errors.New("ring id collides with another node")
+
+	// ErrInvalidNetwork is returned by SetNetwork for a list of ring addresses it refuses to apply
+	ErrInvalidNetwork = errors.New("invalid network")
+)
@@ -0,0 +1,79 @@
+package dht
+
+import (
+	"sync"
+	"time"
+)
+
+// Types of the membership events published by a node
+const (
+	EventSuccessor   = "successor"   // the successor changed
+	EventPredecessor = "predecessor" // the predecessor changed or was cleared
+	EventNodeFailed  = "node_failed" // a peer was detected as dead and unlinked
+)
+
+// Event is a change of the node's ring membership, published on its EventBus
+type Event struct {
+	Type     string    `json:"type"`
+	Node     string    `json:"node"`               // address of the publishing node
+	Peer     string    `json:"peer"`               // new successor or predecessor, or the failed node
+	Previous string    `json:"previous,omitempty"` // successor or predecessor before the change
+	Source   string    `json:"source,omitempty"`   // check that detected a failed node, e.g. "check_predecessor"
+	At       time.Time `json:"at"`
+}
+
+// EventBus fans the events of a node out to its subscribers. Publishing never blocks, an event
+// is dropped for a subscriber whose channel is full.
+type EventBus struct {
+	mu          sync.Mutex
+	subscribers map[chan<- Event]struct{}
+}
+
+// NewEventBus returns a bus without subscribers
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[chan<- Event]struct{})}
+}
+
+// Subscribe delivers the events published from now on to the channel until unsubscribe is called.
+// A channel may be subscribed to the buses of several nodes.
+func (b *EventBus) Subscribe(ch chan<- Event) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subscribers[ch] = struct{}{}
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers, ch)
+	}
+}
+
+// Publish delivers the event to every subscriber with room in its channel
+func (b *EventBus) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Events returns the bus the node publishes its membership changes to
+func (n *Node) Events() *EventBus {
+	return n.events
+}
+
+// publish publishes an event of the node, safe to call with n.mu held
+func (n *Node) publish(eventType string, peer string, previous string, source string) {
+	n.events.Publish(Event{
+		Type:     eventType,
+		Node:     n.address,
+		Peer:     peer,
+		Previous: previous,
+		Source:   source,
+		At:       time.Now(),
+	})
+}
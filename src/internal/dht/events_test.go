@@ -0,0 +1,35 @@
+package dht
+
+import "testing"
+
+func TestEventBusFansOutWithoutBlocking(t *testing.T) {
+	bus := NewEventBus()
+	first, second := make(chan Event, 1), make(chan Event, 1)
+	unsubscribeFirst := bus.Subscribe(first)
+	bus.Subscribe(second)
+
+	bus.Publish(Event{Type: EventSuccessor, Peer: "a"})
+	for _, ch := range []chan Event{first, second} {
+		if e := <-ch; e.Peer != "a" {
+			t.Errorf("delivered %+v, want the successor event", e)
+		}
+	}
+
+	// A full subscriber doesn't hold the publisher up, the event is dropped for it
+	bus.Publish(Event{Type: EventSuccessor, Peer: "b"})
+	bus.Publish(Event{Type: EventSuccessor, Peer: "c"})
+	if e := <-second; e.Peer != "b" || len(second) != 0 {
+		t.Errorf("full subscriber got %+v with %d more, want only the event that fit", e, len(second))
+	}
+
+	// An unsubscribed channel gets nothing more
+	<-first
+	unsubscribeFirst()
+	bus.Publish(Event{Type: EventSuccessor, Peer: "d"})
+	if len(first) != 0 {
+		t.Errorf("unsubscribed channel got %+v", <-first)
+	}
+	if e := <-second; e.Peer != "d" {
+		t.Errorf("remaining subscriber got %+v, want the event after the unsubscribe", e)
+	}
+}
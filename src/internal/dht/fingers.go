@@ -0,0 +1,137 @@
+package dht
+
+import (
+	"context"
+	"sync"
+
+	"assignment/internal/metrics"
+)
+
+// Number of finger lookups FixAllFingers runs at once
+const fixAllFingersWorkers = 4
+
+// FixAllFingers rebuilds the whole finger table in one pass instead of one entry per tick.
+// The lookups run on a bounded pool of workers without holding the node's lock, the entries
+// are then updated under a single lock. Entries resolving to this node or failing are left as
+// they were, like in FixFinger. Returns the number of entries changed and of failed lookups, a
+// cancelled ctx fails the lookups not done yet.
+func (n *Node) FixAllFingers(ctx context.Context) (updated int, failed int) {
+
+	_, resolved, _ := n.resolveFingers(ctx, "FixAllFingers")
+
+	// A stale lookup can return a dead node, check each distinct one once
+	dead := make(map[string]bool)
+	if n.verifyFingers {
+		for _, addr := range resolved {
+			if addr == "" || addr == n.Address() {
+				continue
+			}
+			if _, checked := dead[addr]; checked {
+				continue
+			}
+			alive, err := n.transport.CheckAliveExpecting(ctx, addr, n.ringId(addr))
+			dead[addr] = !alive || err != nil
+			if dead[addr] {
+				metrics.FailedRPCs.WithLabelValues("CheckAlive").Inc()
+				n.logger.Warn("FixAllFingers", "resolved node is not reachable, skipping its entries", "node", addr, "err", err)
+			}
+		}
+	}
+
+	n.mu.Lock()
+	for i, addr := range resolved {
+		switch {
+		case addr == "":
+			failed++
+			metrics.FingerFixes.WithLabelValues("false").Inc()
+		case addr == n.address || addr == n.finger[i].node.address || dead[addr]:
+			metrics.FingerFixes.WithLabelValues("false").Inc()
+		default:
+			n.finger[i].node = node{
+				id:      n.ringId(addr),
+				address: addr,
+			}
+			updated++
+			metrics.FingerFixes.WithLabelValues("true").Inc()
+		}
+	}
+	if updated > 0 {
+		n.epoch++
+	}
+	n.mu.Unlock()
+
+	n.logger.Info("FixAllFingers", "finger table rebuilt", "updated", updated, "failed", failed)
+	return updated, failed
+}
+
+// resolveFingers looks up the successor of every finger start on a bounded pool of workers, without
+// holding the node's lock. A failed lookup leaves an empty address and its error at the index.
+func (n *Node) resolveFingers(ctx context.Context, op string) (starts []int, resolved []string, errs []error) {
+
+	n.mu.RLock()
+	starts = make([]int, len(n.finger))
+	for i, f := range n.finger {
+		starts[i] = f.start
+	}
+	n.mu.RUnlock()
+
+	// Resolve every entry, indices are handed out to the workers over a channel
+	resolved = make([]string, len(starts))
+	errs = make([]error, len(starts))
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for range fixAllFingersWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				successorAddr, err := n.findSuccessor(ctx, starts[i], false)
+				if err != nil {
+					n.logger.Error(op, "failed to find successor", "index", i, "start", starts[i], "err", err)
+					errs[i] = err
+					continue
+				}
+				resolved[i] = successorAddr
+			}
+		}()
+	}
+	for i := range starts {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	return starts, resolved, errs
+}
+
+// FingerMismatch is a finger entry that differs from the successor of its start in the live ring.
+// Expected is empty and Error set if the lookup failed.
+type FingerMismatch struct {
+	Index    int    `json:"index"`
+	Start    int    `json:"start"`
+	Finger   string `json:"finger"`
+	Expected string `json:"expected,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// VerifyFingers looks up the successor of every finger start like FixAllFingers and returns the
+// entries that differ from it, without changing the finger table. The entries are compared with
+// the table as it was before the lookups, so an entry fixed meanwhile is still reported.
+func (n *Node) VerifyFingers(ctx context.Context) []FingerMismatch {
+
+	fingers := n.FingerTable()
+	starts, resolved, errs := n.resolveFingers(ctx, "VerifyFingers")
+
+	mismatches := []FingerMismatch{}
+	for i, addr := range resolved {
+		switch {
+		case errs[i] != nil:
+			mismatches = append(mismatches, FingerMismatch{Index: i, Start: starts[i], Finger: fingers[i], Error: errs[i].Error()})
+		case addr != fingers[i]:
+			mismatches = append(mismatches, FingerMismatch{Index: i, Start: starts[i], Finger: fingers[i], Expected: addr})
+		}
+	}
+
+	n.logger.Info("VerifyFingers", "finger table verified", "fingers", len(fingers), "mismatches", len(mismatches))
+	return mismatches
+}
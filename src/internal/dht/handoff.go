@@ -0,0 +1,217 @@
+package dht
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Maximum number of keys moved per handoff batch
+const handoffBatchSize = 100
+
+// HandoffBatch returns up to limit locally stored keys with ids in (fromId, toId], ordered by key,
+// starting after the given key, with their versions and expiry. more is true if there are keys left after the batch.
+func (n *Node) HandoffBatch(fromId int, toId int, after string, limit int) (pairs map[string]Versioned, more bool) {
+
+	// Collect the matching keys so batches have a stable order
+	var keys []string
+	n.RangeKeys(func(key string, value []byte) bool {
+		if key > after && InIntervalRightInclusive(n.ringId(key), fromId, toId) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	sort.Strings(keys)
+
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+		more = true
+	}
+
+	pairs = make(map[string]Versioned, len(keys))
+	for _, key := range keys {
+		if value, ok := n.loadVersioned(&n.data, key); ok {
+			pairs[key] = value
+		}
+	}
+	return pairs, more
+}
+
+// ConfirmHandoff deletes the handed off keys once the new owner has stored them.
+// A key is only deleted if its value and version are unchanged, so a write that raced the handoff is kept.
+func (n *Node) ConfirmHandoff(pairs map[string]Versioned) (deleted int) {
+
+	n.dataMu.RLock()
+	defer n.dataMu.RUnlock()
+
+	for key, value := range pairs {
+		if n.compareAndDelete(key, value) {
+			deleted++
+		}
+	}
+
+	n.logger.Info("ConfirmHandoff", "deleted handed off keys", "deleted", deleted, "total", len(pairs))
+	return deleted
+}
+
+// Join links this node in front of the successor found through a ring node. The successor's
+// predecessor becomes this node's, the keys in (predecessor, self] are pulled from the successor
+// and a stabilize round notifies the successor right away instead of on the next maintenance tick.
+// Returns an error if the successor can't be asked for its predecessor, maintenance then
+// completes the join from the successor link.
+func (n *Node) Join(successorAddr string) error {
+
+	// The successor of a node's own id only has the same id if the two collide, joining in front
+	// of it would leave it owning the whole ring
+	if successorAddr != n.Address() && n.ringId(successorAddr) == n.Id() {
+		n.logger.Error("Join", "ring id collides with the successor, not joining", "id", n.Id(), "successor", successorAddr)
+		return fmt.Errorf("%w: %s and %s both have id %d", ErrIdCollision, n.Address(), successorAddr, n.Id())
+	}
+
+	n.SetSuccessor(successorAddr)
+	if successorAddr == n.Address() {
+		return nil
+	}
+
+	predecessorAddr, err := n.transport.GetPredecessor(context.Background(), successorAddr)
+	if err != nil {
+		return fmt.Errorf("failed to get predecessor of successor %s: %w", successorAddr, err)
+	}
+	if predecessorAddr != "" && predecessorAddr != n.Address() {
+		n.SetPredecessor(predecessorAddr, false)
+	}
+
+	// Pull the keys before the successor is notified and stops owning them
+	n.acquireKeys()
+	n.Stabilize(context.Background())
+
+	n.logger.Info("Join", "joined ring", "successor", successorAddr, "predecessor", predecessorAddr, "keys", n.KeyCount())
+	return nil
+}
+
+// acquireKeys pulls the keys in (predecessor, self] from the successor in batches.
+// Runs after the predecessor changes, e.g. when this node has just joined in front of the successor.
+func (n *Node) acquireKeys() {
+
+	// Only one acquisition at a time, a later predecessor change is picked up by the next run
+	n.handoffMu.Lock()
+	defer n.handoffMu.Unlock()
+
+	predecessorId, predecessorAddr := n.Predecessor()
+	_, successorAddr := n.Successor()
+
+	if predecessorAddr == "" || successorAddr == n.Address() {
+		// Range unknown or alone in the ring, nothing to acquire
+		return
+	}
+
+	after := ""
+	total := 0
+	for {
+		pairs, more, err := n.transport.GetHandoffBatch(successorAddr, predecessorId, n.Id(), after, handoffBatchSize)
+		if err != nil {
+			n.logger.Error("AcquireKeys", "failed to get handoff batch", "successor", successorAddr, "err", err)
+			return
+		}
+
+		// Store before confirming, a write that already reached this node wins over the handed off
+		// value. The version is kept, so the next write of the key continues from it.
+		n.dataMu.RLock()
+		for key, value := range pairs {
+			n.adoptData(key, n.storedVersioned(value))
+			if key > after {
+				after = key
+			}
+		}
+		n.dataMu.RUnlock()
+
+		// The old owner only deletes the keys after this confirmation
+		if len(pairs) == 0 {
+			break
+		}
+		if err := n.transport.ConfirmHandoff(successorAddr, pairs); err != nil {
+			n.logger.Error("AcquireKeys", "failed to confirm handoff", "keys", len(pairs), "successor", successorAddr, "err", err)
+			return
+		}
+
+		total += len(pairs)
+		if !more {
+			break
+		}
+	}
+
+	if total > 0 {
+		n.logger.Info("AcquireKeys", "acquired keys from successor", "keys", total, "from_id", predecessorId, "to_id", n.Id(), "successor", successorAddr)
+	}
+}
+
+// AcceptHandoff stores key-value pairs pushed by a leaving predecessor at their versions and
+// expiry, regardless of the current ownership
+func (n *Node) AcceptHandoff(pairs map[string]Versioned) {
+
+	n.dataMu.RLock()
+	defer n.dataMu.RUnlock()
+
+	for key, value := range pairs {
+		n.storeData(key, n.storedVersioned(value))
+	}
+
+	n.logger.Info("AcceptHandoff", "stored handed off keys", "keys", len(pairs))
+}
+
+// handOffData pushes every locally stored key to the successor in batches. The keys are only
+// deleted locally once every batch was stored, if a batch fails the batches already pushed are
+// taken back from the successor and all keys stay here, so an aborted leave leaves the node
+// owning all of its keys.
+func (n *Node) handOffData(successorAddr string) error {
+
+	// Consistent copy, concurrent writes are blocked while copying
+	data := n.snapshotVersioned()
+	if len(data) == 0 {
+		return nil
+	}
+
+	// Sort for deterministic batches
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pushed []map[string]Versioned
+	for start := 0; start < len(keys); start += handoffBatchSize {
+		end := min(start+handoffBatchSize, len(keys))
+
+		batch := make(map[string]Versioned, end-start)
+		for _, key := range keys[start:end] {
+			batch[key] = data[key]
+		}
+
+		if err := n.transport.PushHandoff(successorAddr, batch); err != nil {
+			n.logger.Error("Leave", "failed to hand off batch, taking back the pushed keys", "keys", len(batch), "pushed_batches", len(pushed), "successor", successorAddr, "err", err)
+			n.recallHandoff(successorAddr, pushed)
+			return fmt.Errorf("failed to hand off %d of %d keys to %s: %w", len(keys)-start, len(keys), successorAddr, err)
+		}
+		pushed = append(pushed, batch)
+	}
+
+	// Handed off, the successor is now responsible for them
+	for _, batch := range pushed {
+		n.ConfirmHandoff(batch)
+	}
+
+	n.logger.Info("Leave", "handed off keys to successor", "keys", len(keys), "successor", successorAddr)
+	return nil
+}
+
+// recallHandoff takes the pushed batches back from the successor after a failed handoff. The
+// successor deletes its copies like an old owner whose keys were handed off, keeping the keys
+// written since they were pushed. Failures are logged, the copies left on the successor are
+// moved back to this node by the next /repair.
+func (n *Node) recallHandoff(successorAddr string, pushed []map[string]Versioned) {
+	for _, batch := range pushed {
+		if err := n.transport.ConfirmHandoff(successorAddr, batch); err != nil {
+			n.logger.Error("Leave", "failed to take back handed off keys", "keys", len(batch), "successor", successorAddr, "err", err)
+		}
+	}
+}
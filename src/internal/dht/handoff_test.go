@@ -0,0 +1,193 @@
+package dht
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"assignment/internal/logging"
+)
+
+// quietLogger returns a logger discarding everything, like the nodes of BuildRing
+func quietLogger() logging.Logger {
+	return logging.NewText(log.New(io.Discard, "", 0))
+}
+
+// ringPut puts the pair through the ring from the first node, following the next hops like a
+// forwarded PUT, and returns the version stored on the owner
+func ringPut(t *testing.T, nodes []*Node, key string, value []byte, ttl time.Duration) uint64 {
+	t.Helper()
+	byAddr := make(map[string]*Node, len(nodes))
+	for _, node := range nodes {
+		byAddr[node.Address()] = node
+	}
+	node := nodes[0]
+	for range memoryMaxHops {
+		version, next, err := node.Put(key, value, ttl)
+		if err != nil {
+			t.Fatalf("Put(%q) on %s: %v", key, node.Address(), err)
+		}
+		if next == "" {
+			return version
+		}
+		node = byAddr[next]
+	}
+	t.Fatalf("Put(%q) did not reach the owner", key)
+	return 0
+}
+
+// ownerNode returns the node of the ring that owns the key
+func ownerNode(nodes []*Node, key string) *Node {
+	for _, node := range nodes {
+		if node.owns(node.ringId(key)) {
+			return node
+		}
+	}
+	return nil
+}
+
+func TestLeaveKeepsVersions(t *testing.T) {
+	nodes := BuildRing(3)
+	leaving, successor := nodes[1], nodes[2]
+
+	var keys []string
+	for i := 0; len(keys) < 10; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if leaving.owns(leaving.ringId(key)) {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range keys {
+		for i := range 3 {
+			ringPut(t, nodes, key, []byte(fmt.Sprintf("v%d", i)), time.Hour)
+		}
+	}
+	before, _ := leaving.loadVersioned(&leaving.data, keys[0])
+
+	if _, err := leaving.Leave(); err != nil {
+		t.Fatalf("Leave: %v", err)
+	}
+
+	for _, key := range keys {
+		v, ok := successor.loadVersioned(&successor.data, key)
+		if !ok {
+			t.Fatalf("key %q missing on the successor after the leave", key)
+		}
+		if v.Version != 3 || string(v.Value) != "v2" {
+			t.Errorf("key %q on the successor = %q at version %d, want \"v2\" at version 3", key, v.Value, v.Version)
+		}
+	}
+	if v, _ := successor.loadVersioned(&successor.data, keys[0]); !v.ExpiresAt.Equal(before.ExpiresAt) {
+		t.Errorf("expiry of %q = %v after the leave, want %v", keys[0], v.ExpiresAt, before.ExpiresAt)
+	}
+
+	// The next write continues from the handed off version
+	if version := ringPut(t, []*Node{nodes[0], successor}, keys[0], []byte("v3"), 0); version != 4 {
+		t.Errorf("version of the first write after the leave = %d, want 4", version)
+	}
+}
+
+func TestJoinKeepsVersions(t *testing.T) {
+	net := NewMemoryNetwork()
+	nodes := net.BuildRing(2)
+	ctx := context.Background()
+
+	for i := range 200 {
+		key := fmt.Sprintf("key-%d", i)
+		for j := range 3 {
+			ringPut(t, nodes, key, []byte(fmt.Sprintf("v%d", j)), 0)
+		}
+	}
+
+	joining := Create("node-joining", WithLogger(quietLogger()))
+	net.Add(joining)
+	successorAddr, err := nodes[0].FindSuccessor(ctx, joining.Id())
+	if err != nil {
+		t.Fatalf("FindSuccessor: %v", err)
+	}
+	if err := joining.Join(successorAddr); err != nil {
+		t.Fatalf("Join: %v", err)
+	}
+	ring := append(nodes, joining)
+	for range 3 {
+		for _, node := range ring {
+			node.Stabilize(ctx)
+		}
+	}
+
+	moved := 0
+	for i := range 200 {
+		key := fmt.Sprintf("key-%d", i)
+		if ownerNode(ring, key) != joining {
+			continue
+		}
+		moved++
+		v, ok := joining.loadVersioned(&joining.data, key)
+		if !ok {
+			t.Fatalf("key %q missing on the joined node", key)
+		}
+		if v.Version != 3 {
+			t.Errorf("key %q on the joined node at version %d, want 3", key, v.Version)
+		}
+		for _, node := range nodes {
+			if _, ok := node.load(key); ok {
+				t.Errorf("key %q still stored on %s after the handoff", key, node.Address())
+			}
+		}
+	}
+	if moved == 0 {
+		t.Fatal("no key moved to the joined node")
+	}
+}
+
+// failingPushTransport fails the handoff pushes from the given push on, counted from 1
+type failingPushTransport struct {
+	Transport
+	failFrom int
+	pushes   int
+}
+
+func (t *failingPushTransport) PushHandoff(targetAddr string, pairs map[string]Versioned) error {
+	t.pushes++
+	if t.pushes >= t.failFrom {
+		return fmt.Errorf("TIMEOUT: push %d to %s", t.pushes, targetAddr)
+	}
+	return t.Transport.PushHandoff(targetAddr, pairs)
+}
+
+func TestFailedHandoffKeepsAllKeys(t *testing.T) {
+	nodes := BuildRing(3)
+	leaving, successor := nodes[1], nodes[2]
+
+	// Enough keys for three batches
+	var keys []string
+	for i := 0; len(keys) < 2*handoffBatchSize+1; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if leaving.owns(leaving.ringId(key)) {
+			keys = append(keys, key)
+			ringPut(t, nodes, key, []byte("value"), 0)
+		}
+	}
+	leaving.transport = &failingPushTransport{Transport: leaving.transport, failFrom: 2}
+
+	if _, err := leaving.Leave(); err == nil {
+		t.Fatal("Leave succeeded with a failing second batch")
+	}
+	if _, succAddr := leaving.Successor(); succAddr != successor.Address() {
+		t.Fatalf("successor after the aborted leave = %s, want %s", succAddr, successor.Address())
+	}
+	for _, key := range keys {
+		if _, ok := leaving.load(key); !ok {
+			t.Errorf("key %q deleted from the node after the aborted leave", key)
+		}
+		if _, ok := successor.load(key); ok {
+			t.Errorf("key %q left on the successor after the aborted leave", key)
+		}
+		if value, next, err := leaving.Get(key); err != nil || next != "" || string(value.Value) != "value" {
+			t.Errorf("Get(%q) after the aborted leave = %q, %q, %v, want \"value\" from the node", key, value.Value, next, err)
+		}
+	}
+}
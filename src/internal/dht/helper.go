@@ -2,20 +2,76 @@ package dht
 
 import (
 	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"hash/fnv"
 	"math/big"
+	"sort"
+	"strconv"
+	"strings"
 )
 
-// KeyToRingId hashes the input string and returns an int in the range 0..(mod-1)
+// Hasher hashes keys and node addresses onto the ring, every node of a ring must use the same one
+type Hasher interface {
+	Name() string          // name of the hash function, as accepted by HasherByName
+	Sum(key string) []byte // digest of the key
+}
+
+// sha1Hasher is Chord's SHA-1, the default
+type sha1Hasher struct{}
+
+func (sha1Hasher) Name() string { return "sha1" }
+
+func (sha1Hasher) Sum(key string) []byte {
+	sum := sha1.Sum([]byte(key))
+	return sum[:]
+}
+
+// sha256Hasher is SHA-256
+type sha256Hasher struct{}
+
+func (sha256Hasher) Name() string { return "sha256" }
+
+func (sha256Hasher) Sum(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:]
+}
+
+// fnvHasher is the non-cryptographic 64-bit FNV-1a, faster for test rings
+type fnvHasher struct{}
+
+func (fnvHasher) Name() string { return "fnv" }
+
+func (fnvHasher) Sum(key string) []byte {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum(nil)
+}
+
+// DefaultHasher is the hash function of a node created without WithHasher, SHA-1
+var DefaultHasher Hasher = sha1Hasher{}
+
+// HasherByName returns the hash function of the name, "sha1", "sha256" or "fnv"
+func HasherByName(name string) (Hasher, error) {
+	for _, h := range []Hasher{sha1Hasher{}, sha256Hasher{}, fnvHasher{}} {
+		if h.Name() == name {
+			return h, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown hash function %q, expected sha1, sha256 or fnv", name)
+}
+
+// KeyToRingId hashes the input string with SHA-1 and returns an int in the range 0..(mod-1)
 // mod is the number of nodes in the ring (id space size)
 func KeyToRingId(key string, mod int) int {
+	return HashToRingId(DefaultHasher, key, mod)
+}
 
-	// Compute SHA-1 hash of key
-	h := sha1.New()
-	h.Write([]byte(key))
-	hashBytes := h.Sum(nil)
+// HashToRingId hashes the input string with the hash function and returns an int in the range 0..(mod-1)
+func HashToRingId(h Hasher, key string, mod int) int {
 
 	// Convert hash bytes to a big integer
-	hashInt := new(big.Int).SetBytes(hashBytes)
+	hashInt := new(big.Int).SetBytes(h.Sum(key))
 
 	// Map hash to ring using modulo M
 	modInt := new(big.Int).Mod(hashInt, big.NewInt(int64(mod)))
@@ -55,3 +111,50 @@ func InIntervalRightInclusive(x, a, b int) bool {
 	}
 	return x > a || x <= b // wrap-around
 }
+
+// Clockwise distance from a to b on a ring of size mod
+// Used to keep node lists in ring order
+func ClockwiseDistance(a, b, mod int) int {
+	return ((b-a)%mod + mod) % mod
+}
+
+// Separator of the vnode index in the ring address of a virtual node, "host:port#i"
+const vnodeSeparator = "#"
+
+// VnodeAddress returns the ring address of the i-th virtual node of the process at the address.
+// The first one keeps the plain address, so a process without vnodes keeps its id.
+func VnodeAddress(address string, i int) string {
+	if i <= 0 {
+		return address
+	}
+	return address + vnodeSeparator + strconv.Itoa(i)
+}
+
+// SplitVnode returns the process address and the vnode index of a ring address, 0 for a plain address
+func SplitVnode(addr string) (address string, vnode int) {
+	address, index, found := strings.Cut(addr, vnodeSeparator)
+	if !found {
+		return addr, 0
+	}
+	vnode, err := strconv.Atoi(index)
+	if err != nil || vnode < 0 {
+		return addr, 0
+	}
+	return address, vnode
+}
+
+// TruncateSorted keeps the limit first keys of the pairs in sorted order, all of them if limit is not positive
+func TruncateSorted[V any](pairs map[string]V, limit int) map[string]V {
+	if limit <= 0 || len(pairs) <= limit {
+		return pairs
+	}
+	keys := make([]string, 0, len(pairs))
+	for key := range pairs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys[limit:] {
+		delete(pairs, key)
+	}
+	return pairs
+}
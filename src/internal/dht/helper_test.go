@@ -0,0 +1,73 @@
+package dht
+
+import (
+	"fmt"
+	"testing"
+)
+
+// identityHasher places a key at the number its bytes spell, a hash function outside the package
+type identityHasher struct{}
+
+func (identityHasher) Name() string { return "identity" }
+
+func (identityHasher) Sum(key string) []byte { return []byte(key) }
+
+func TestHasherByName(t *testing.T) {
+	for _, name := range []string{"sha1", "sha256", "fnv"} {
+		if h, err := HasherByName(name); err != nil || h.Name() != name {
+			t.Errorf("HasherByName(%q) = %v, %v", name, h, err)
+		}
+	}
+	if _, err := HasherByName("md5"); err == nil {
+		t.Error("HasherByName(\"md5\") succeeded")
+	}
+	if got, want := HashToRingId(DefaultHasher, "key", ID_SPACE_SIZE), KeyToRingId("key", ID_SPACE_SIZE); got != want || DefaultHasher.Name() != "sha1" {
+		t.Errorf("default %s hasher places the key at %d, KeyToRingId at %d", DefaultHasher.Name(), got, want)
+	}
+}
+
+func TestHasherPlacesKeys(t *testing.T) {
+	fnv, _ := HasherByName("fnv")
+	hashers := []Hasher{DefaultHasher, fnv, identityHasher{}}
+
+	// A key the hash functions place on different ids
+	key := ""
+	for i := 0; key == ""; i++ {
+		candidate := fmt.Sprintf("key-%d", i)
+		ids := make(map[int]bool)
+		for _, h := range hashers {
+			ids[HashToRingId(h, candidate, ID_SPACE_SIZE)] = true
+		}
+		if len(ids) == len(hashers) {
+			key = candidate
+		}
+	}
+
+	for _, h := range hashers {
+		t.Run(h.Name(), func(t *testing.T) {
+			nodes := BuildRing(4, WithHasher(h))
+			for _, node := range nodes {
+				if want := HashToRingId(h, node.Address(), ID_SPACE_SIZE); node.Id() != want {
+					t.Errorf("%s is at %d, want %d", node.Address(), node.Id(), want)
+				}
+				// The links learnt through the joins and notifies are hashed the same way
+				if id, addr := node.Successor(); id != HashToRingId(h, addr, ID_SPACE_SIZE) {
+					t.Errorf("successor %s of %s is at %d, want %d", addr, node.Address(), id, HashToRingId(h, addr, ID_SPACE_SIZE))
+				}
+				if id, addr := node.Predecessor(); id != HashToRingId(h, addr, ID_SPACE_SIZE) {
+					t.Errorf("predecessor %s of %s is at %d, want %d", addr, node.Address(), id, HashToRingId(h, addr, ID_SPACE_SIZE))
+				}
+			}
+
+			// The key is stored on the successor of its id under the ring's hash function
+			keyId := HashToRingId(h, key, ID_SPACE_SIZE)
+			ringPut(t, nodes, key, []byte("value"), 0)
+			owner := ownerOf(nodes, keyId)
+			for _, node := range nodes {
+				if _, ok := node.load(key); ok != (node.Address() == owner) {
+					t.Errorf("key at %d stored on %s: %v, want it only on the owner %s", keyId, node.Address(), ok, owner)
+				}
+			}
+		})
+	}
+}
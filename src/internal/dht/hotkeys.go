@@ -0,0 +1,106 @@
+package dht
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Default number of keys whose accesses are counted, override with WithHotKeyCapacity
+const DefaultHotKeyCapacity = 1024
+
+// KeyAccess is the access count of a key on its owner, see HotKeys
+type KeyAccess struct {
+	Key   string `json:"key"`
+	Gets  uint64 `json:"gets"`
+	Puts  uint64 `json:"puts"`
+	Error uint64 `json:"error,omitempty"` // count inherited from an evicted key, the key had at most this many accesses more
+}
+
+// total returns the estimated accesses of the key, ordering the hot keys
+func (a *KeyAccess) total() uint64 {
+	return a.Gets + a.Puts + a.Error
+}
+
+// accessCounter counts the gets and puts of the keys owned by a node in at most capacity entries
+// with the space-saving algorithm: a key that is not counted yet replaces the least accessed one
+// and inherits its estimate as the error. Every key accessed more often than 1/capacity of all
+// accesses is guaranteed to be counted, so the top keys are exact up to their error.
+type accessCounter struct {
+	mu       sync.Mutex
+	keys     map[string]*KeyAccess
+	capacity int // 0 disables counting
+}
+
+// record counts an access of the key, a get or a put
+func (c *accessCounter) record(key string, put bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.capacity <= 0 {
+		return
+	}
+	if c.keys == nil {
+		c.keys = make(map[string]*KeyAccess)
+	}
+
+	a, ok := c.keys[key]
+	if !ok {
+		a = &KeyAccess{Key: key}
+		if len(c.keys) >= c.capacity {
+			// Linear in the capacity, only paid by keys that are not counted yet
+			var least *KeyAccess
+			for _, e := range c.keys {
+				if least == nil || e.total() < least.total() {
+					least = e
+				}
+			}
+			delete(c.keys, least.Key)
+			a.Error = least.total()
+		}
+		c.keys[key] = a
+	}
+
+	if put {
+		a.Puts++
+	} else {
+		a.Gets++
+	}
+}
+
+// top returns copies of the counts of the keys accepted by keep, most accessed first and ties by
+// key, the top ones if top is positive
+func (c *accessCounter) top(top int, keep func(key string) bool) []KeyAccess {
+	c.mu.Lock()
+	counts := make([]KeyAccess, 0, len(c.keys))
+	for _, a := range c.keys {
+		counts = append(counts, *a)
+	}
+	c.mu.Unlock()
+
+	counts = slices.DeleteFunc(counts, func(a KeyAccess) bool { return !keep(a.Key) })
+	slices.SortFunc(counts, func(a, b KeyAccess) int {
+		if a.total() != b.total() {
+			if a.total() > b.total() {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+	if top > 0 && len(counts) > top {
+		counts = counts[:top]
+	}
+	return counts
+}
+
+// HotKeys returns the access counts of the keys this node owns, most accessed first, the top ones
+// if top is positive. Gets and stored puts are counted on the owner, a forwarded request once, a
+// put refused as moving, full or failing its precondition is not counted. Gets and Puts are exact
+// since the key was last counted, a key that replaced an evicted one is ordered as if it had
+// Error more accesses.
+func (n *Node) HotKeys(top int) []KeyAccess {
+	return n.access.top(top, func(key string) bool {
+		return n.owns(n.ringId(key))
+	})
+}
@@ -0,0 +1,32 @@
+package dht
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHotKeysCountOnlyStoredPuts(t *testing.T) {
+	node := Create("node-0", WithLogger(quietLogger()), WithMaxKeys(1, true))
+
+	if _, _, err := node.Put("stored", []byte("v1"), 0); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	// Refused as full, failing the precondition and while the key is moving
+	if _, _, err := node.Put("full", []byte("v"), 0); !errors.Is(err, ErrStorageFull) {
+		t.Fatalf("Put at capacity: %v, want ErrStorageFull", err)
+	}
+	if _, _, err := node.CompareVersionAndPut("stored", 7, []byte("v2"), 0); !errors.Is(err, ErrPreconditionFailed) {
+		t.Fatalf("swap at a stale version: %v, want ErrPreconditionFailed", err)
+	}
+	node.moving["stored"] = true
+	if _, _, err := node.Put("stored", []byte("v2"), 0); !errors.Is(err, ErrKeyMoving) {
+		t.Fatalf("Put while moving: %v, want ErrKeyMoving", err)
+	}
+	delete(node.moving, "stored")
+
+	hot := node.HotKeys(0)
+	if len(hot) != 1 || hot[0].Key != "stored" || hot[0].Puts != 1 {
+		t.Errorf("hot keys = %+v, want only \"stored\" with 1 put", hot)
+	}
+}
@@ -0,0 +1,128 @@
+package dht
+
+import (
+	"context"
+	"fmt"
+)
+
+// Lookup modes of FindSuccessor, set with WithLookupMode
+const (
+	// LookupRecursive forwards the lookup to the closest preceding node, which continues it
+	LookupRecursive = "recursive"
+
+	// LookupIterative walks the ring from this node, asking each node for its closest preceding node
+	LookupIterative = "iterative"
+)
+
+// Route is the routing decision of a node for a key, see Route
+type Route struct {
+	Node    string `json:"node"` // address of the deciding node
+	NodeId  int    `json:"node_id"`
+	Key     string `json:"key"`
+	KeyId   int    `json:"key_id"`
+	Owned   bool   `json:"owned"`              // whether a storage request is served by this node
+	NextHop string `json:"next_hop,omitempty"` // node a storage request is forwarded to if not owned
+	Owner   string `json:"owner"`              // node storing the key, resolved with FindSuccessor
+}
+
+// Route returns where a storage request for the key would go from this node, without storing
+// anything or changing any link. The owner of a key not owned here is looked up with FindSuccessor.
+func (n *Node) Route(ctx context.Context, key string) (Route, error) {
+
+	keyId := n.ringId(key)
+	route := Route{Node: n.Address(), NodeId: n.Id(), Key: key, KeyId: keyId, Owned: n.owns(keyId)}
+	if route.Owned {
+		route.Owner = route.Node
+		return route, nil
+	}
+
+	route.NextHop = n.NextHop(key)
+	owner, err := n.FindSuccessor(ctx, keyId)
+	if err != nil {
+		return route, fmt.Errorf("failed to find owner of key %d: %w", keyId, err)
+	}
+	route.Owner = owner
+	return route, nil
+}
+
+// ClosestPreceding returns the closest node preceding the key in the finger table and the successor of this node
+// Answers one step of an iterative lookup.
+func (n *Node) ClosestPreceding(keyId int) (closest string, successor string) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	_, closest = n.closestPrecedingNodeLocked(keyId)
+	return closest, n.successor.address
+}
+
+// findSuccessorIterative finds the successor of the key by walking the ring from this node.
+// Each step asks the current node for its closest preceding node until the key is in (node, successor],
+// so the lookup is bounded by the number of steps and never builds a call chain across nodes.
+func (n *Node) findSuccessorIterative(keyId int) (string, error) {
+
+	current := n.Address()
+	currentId := n.Id()
+	closest, successor := n.ClosestPreceding(keyId)
+
+	// Every step at least halves the distance to the key with correct fingers, allow for stale ones
+	maxSteps := 2 * n.m
+	for step := 0; step < maxSteps; step++ {
+
+		successorId := n.ringId(successor)
+		if InIntervalRightInclusive(keyId, currentId, successorId) {
+			return successor, nil
+		}
+
+		// No closer node known, the successor is the best answer
+		next := closest
+		if next == current || next == "" {
+			next = successor
+		}
+
+		var err error
+		closest, successor, err = n.transport.ClosestPreceding(next, keyId)
+		if err != nil {
+			return "", fmt.Errorf("iterative lookup failed at %s: %w", next, err)
+		}
+		current = next
+		currentId = n.ringId(next)
+	}
+
+	return "", fmt.Errorf("iterative lookup of key %d did not converge after %d steps", keyId, maxSteps)
+}
+
+// localSuccessor returns the successor of the key from the local tables without a remote hop, if the key
+// falls in a range they cover: [start, node] of a finger, whose node is the first node from start, or
+// between two consecutive nodes of the successor list. Only exact while the tables are fresh.
+// Fingers pointing at this node and fingers not fixed since a join, whose start lies past their
+// node, cover no range: the first would span the whole ring and answer this node for every key.
+func (n *Node) localSuccessor(keyId int) (string, bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	for _, f := range n.finger {
+		if f.node.address == "" || f.node.address == n.address || f.node.id == n.id {
+			continue
+		}
+		if !InIntervalRightInclusive(f.start, n.id, f.node.id) {
+			continue
+		}
+		// (start-1, node] is [start, node] on the ring
+		if InIntervalRightInclusive(keyId, (f.start-1+n.idSpaceSize)%n.idSpaceSize, f.node.id) {
+			return f.node.address, true
+		}
+	}
+
+	// The list starts with the successor, two equal nodes span no range rather than the whole ring
+	prev := n.successor
+	for _, s := range n.successorList {
+		if s.address == "" || s.id == prev.id {
+			continue
+		}
+		if InIntervalRightInclusive(keyId, prev.id, s.id) {
+			return s.address, true
+		}
+		prev = s
+	}
+	return "", false
+}
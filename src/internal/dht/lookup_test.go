@@ -0,0 +1,70 @@
+package dht
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestPreferLocalAfterJoinForwards(t *testing.T) {
+	net := NewMemoryNetwork()
+	nodes := net.BuildRing(4)
+	ctx := context.Background()
+
+	joining := Create("node-joining", WithLogger(quietLogger()), WithPreferLocalFingers(true))
+	net.Add(joining)
+	successorAddr, err := nodes[0].FindSuccessor(ctx, joining.Id())
+	if err != nil {
+		t.Fatalf("FindSuccessor: %v", err)
+	}
+	if err := joining.Join(successorAddr); err != nil {
+		t.Fatalf("Join: %v", err)
+	}
+
+	// Settle the successor links only, the joined node's fingers stay as they were after the join
+	ring := append(slices.Clone(nodes), joining)
+	for range 3 {
+		for _, node := range ring {
+			node.Stabilize(ctx)
+		}
+	}
+	slices.SortFunc(ring, func(a, b *Node) int { return cmp.Compare(a.Id(), b.Id()) })
+
+	for i := range 200 {
+		keyId := joining.ringId(fmt.Sprintf("key-%d", i))
+		got, err := joining.FindSuccessor(ctx, keyId)
+		if err != nil {
+			t.Fatalf("FindSuccessor(%d): %v", keyId, err)
+		}
+		if want := ownerOf(ring, keyId); got != want {
+			t.Errorf("FindSuccessor(%d) on the joined node = %s, want %s", keyId, got, want)
+		}
+	}
+
+	// Once fixed, the fingers answer lookups locally again
+	joining.FixAllFingers(ctx)
+	local := 0
+	for i := range 200 {
+		keyId := joining.ringId(fmt.Sprintf("key-%d", i))
+		if got, ok := joining.localSuccessor(keyId); ok {
+			local++
+			if want := ownerOf(ring, keyId); got != want {
+				t.Errorf("localSuccessor(%d) with fixed fingers = %s, want %s", keyId, got, want)
+			}
+		}
+	}
+	if local == 0 {
+		t.Error("no lookup answered locally with fixed fingers")
+	}
+}
+
+func TestLocalSuccessorSkipsSelfFingers(t *testing.T) {
+	node := Create("node-0", WithLogger(quietLogger()), WithPreferLocalFingers(true))
+	for _, keyId := range []int{node.Id(), (node.Id() + 1) % node.idSpaceSize, (node.Id() + node.idSpaceSize/2) % node.idSpaceSize} {
+		if addr, ok := node.localSuccessor(keyId); ok {
+			t.Errorf("localSuccessor(%d) = %s from a table of self fingers, want no answer", keyId, addr)
+		}
+	}
+}
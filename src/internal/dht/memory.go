@@ -0,0 +1,366 @@
+package dht
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"assignment/internal/logging"
+)
+
+// Hops a StoreKey is routed over in a MemoryNetwork before it is given up as a routing loop
+const memoryMaxHops = 64
+
+// errMemoryUnreachable is returned by a MemoryTransport RPC to a node that is failed or not registered
+var errMemoryUnreachable = errors.New("node unreachable")
+
+// MemoryNetwork routes the RPCs of its nodes to each other in process, without sockets.
+// Latency and failed nodes can be injected, so rings of many nodes can be built and
+// exercised deterministically, see BuildRing.
+type MemoryNetwork struct {
+	mu      sync.RWMutex
+	nodes   map[string]*Node
+	failed  map[string]bool
+	latency time.Duration
+
+	// Extra latency of the RPCs to a node, see SetNodeLatency
+	nodeLatency map[string]time.Duration
+}
+
+// NewMemoryNetwork returns a network without nodes
+func NewMemoryNetwork() *MemoryNetwork {
+	return &MemoryNetwork{
+		nodes:       make(map[string]*Node),
+		failed:      make(map[string]bool),
+		nodeLatency: make(map[string]time.Duration),
+	}
+}
+
+// Add registers the node and sets its transport to the network
+func (net *MemoryNetwork) Add(n *Node) {
+	net.mu.Lock()
+	net.nodes[n.Address()] = n
+	net.mu.Unlock()
+
+	n.SetTransport(&MemoryTransport{net: net, address: n.Address()})
+}
+
+// Fail makes the node at the address unreachable, its RPCs to others fail as well
+func (net *MemoryNetwork) Fail(addr string) {
+	net.mu.Lock()
+	defer net.mu.Unlock()
+	net.failed[addr] = true
+}
+
+// Recover makes a failed node reachable again
+func (net *MemoryNetwork) Recover(addr string) {
+	net.mu.Lock()
+	defer net.mu.Unlock()
+	delete(net.failed, addr)
+}
+
+// SetLatency delays every RPC by d, 0 delivers them right away
+func (net *MemoryNetwork) SetLatency(d time.Duration) {
+	net.mu.Lock()
+	defer net.mu.Unlock()
+	net.latency = d
+}
+
+// SetNodeLatency delays every RPC to the node at the address by d on top of SetLatency, e.g. a
+// replica farther away than the others
+func (net *MemoryNetwork) SetNodeLatency(addr string, d time.Duration) {
+	net.mu.Lock()
+	defer net.mu.Unlock()
+	net.nodeLatency[addr] = d
+}
+
+// reach returns the node an RPC from the address to the target is delivered to, after the latency
+func (net *MemoryNetwork) reach(ctx context.Context, from string, targetAddr string) (*Node, error) {
+
+	net.mu.RLock()
+	latency := net.latency + net.nodeLatency[targetAddr]
+	net.mu.RUnlock()
+
+	if latency > 0 && !sleepContext(ctx, latency) {
+		return nil, ctx.Err()
+	}
+
+	net.mu.RLock()
+	defer net.mu.RUnlock()
+
+	if net.failed[from] {
+		return nil, fmt.Errorf("%w: %s is failed itself", errMemoryUnreachable, from)
+	}
+	target, ok := net.nodes[targetAddr]
+	if !ok || net.failed[targetAddr] {
+		return nil, fmt.Errorf("%w: %s", errMemoryUnreachable, targetAddr)
+	}
+	return target, nil
+}
+
+// MemoryTransport is the Transport of a node in a MemoryNetwork, calling the methods of the
+// target node directly like the HTTP handlers of the target would
+type MemoryTransport struct {
+	net       *MemoryNetwork
+	address   string
+	latencies PeerLatencies
+}
+
+// reach delivers an RPC to the target like MemoryNetwork.reach and records its latency
+func (t *MemoryTransport) reach(ctx context.Context, targetAddr string) (*Node, error) {
+	start := time.Now()
+	target, err := t.net.reach(ctx, t.address, targetAddr)
+	if err == nil {
+		t.latencies.Observe(targetAddr, time.Since(start))
+	}
+	return target, err
+}
+
+func (t *MemoryTransport) CheckAlive(ctx context.Context, targetAddr string) (bool, error) {
+	if _, err := t.reach(ctx, targetAddr); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (t *MemoryTransport) CheckAliveExpecting(ctx context.Context, targetAddr string, expectedId int) (bool, error) {
+	target, err := t.reach(ctx, targetAddr)
+	if err != nil {
+		return false, err
+	}
+	if id := target.Id(); id != expectedId {
+		return false, fmt.Errorf("%s answered as node %d, expected node %d", targetAddr, id, expectedId)
+	}
+	return true, nil
+}
+
+func (t *MemoryTransport) GetPredecessor(ctx context.Context, targetAddr string) (string, error) {
+	target, err := t.reach(ctx, targetAddr)
+	if err != nil {
+		return "", err
+	}
+	_, predecessorAddr := target.Predecessor()
+	return predecessorAddr, nil
+}
+
+func (t *MemoryTransport) Notify(ctx context.Context, targetAddr string, predecessor string) error {
+	target, err := t.reach(ctx, targetAddr)
+	if err != nil {
+		return err
+	}
+	target.Notify(predecessor)
+	return nil
+}
+
+func (t *MemoryTransport) SetPredecessor(ctx context.Context, targetAddr string, predecessor string) error {
+	target, err := t.reach(ctx, targetAddr)
+	if err != nil {
+		return err
+	}
+	target.SetPredecessor(predecessor, true)
+	return nil
+}
+
+func (t *MemoryTransport) SetSuccessor(ctx context.Context, targetAddr string, successor string) error {
+	target, err := t.reach(ctx, targetAddr)
+	if err != nil {
+		return err
+	}
+	target.SetSuccessor(successor)
+	return nil
+}
+
+func (t *MemoryTransport) FindSuccessor(ctx context.Context, targetAddr string, keyId int) (string, error) {
+	target, err := t.reach(ctx, targetAddr)
+	if err != nil {
+		return "", err
+	}
+	return target.FindSuccessor(ctx, keyId)
+}
+
+func (t *MemoryTransport) GetSuccessorList(targetAddr string) ([]string, error) {
+	target, err := t.reach(context.Background(), targetAddr)
+	if err != nil {
+		return nil, err
+	}
+	return target.SuccessorList(), nil
+}
+
+func (t *MemoryTransport) GetHandoffBatch(targetAddr string, fromId int, toId int, after string, limit int) (map[string]Versioned, bool, error) {
+	target, err := t.reach(context.Background(), targetAddr)
+	if err != nil {
+		return nil, false, err
+	}
+	pairs, more := target.HandoffBatch(fromId, toId, after, limit)
+	return pairs, more, nil
+}
+
+// StoreKey stores the pair on its owner, routed from the target like a forwarded PUT
+func (t *MemoryTransport) StoreKey(targetAddr string, key string, value []byte) error {
+	addr := targetAddr
+	for range memoryMaxHops {
+		target, err := t.reach(context.Background(), addr)
+		if err != nil {
+			return fmt.Errorf("failed to store key on %s: %w", addr, err)
+		}
+		_, next, err := target.Put(key, value, 0)
+		if err != nil || next == "" {
+			return err
+		}
+		addr = next
+	}
+	return fmt.Errorf("store of key %q from %s did not reach its owner after %d hops", key, targetAddr, memoryMaxHops)
+}
+
+func (t *MemoryTransport) PushHandoff(targetAddr string, pairs map[string]Versioned) error {
+	target, err := t.reach(context.Background(), targetAddr)
+	if err != nil {
+		return err
+	}
+	target.AcceptHandoff(pairs)
+	return nil
+}
+
+func (t *MemoryTransport) ConfirmHandoff(targetAddr string, pairs map[string]Versioned) error {
+	target, err := t.reach(context.Background(), targetAddr)
+	if err != nil {
+		return err
+	}
+	target.ConfirmHandoff(pairs)
+	return nil
+}
+
+func (t *MemoryTransport) ClosestPreceding(targetAddr string, keyId int) (string, string, error) {
+	target, err := t.reach(context.Background(), targetAddr)
+	if err != nil {
+		return "", "", err
+	}
+	closest, successor := target.ClosestPreceding(keyId)
+	return closest, successor, nil
+}
+
+func (t *MemoryTransport) PushReplicas(targetAddr string, pairs map[string]Versioned) error {
+	target, err := t.reach(context.Background(), targetAddr)
+	if err != nil {
+		return err
+	}
+	target.AcceptReplicas(pairs)
+	return nil
+}
+
+func (t *MemoryTransport) GetReplica(targetAddr string, key string) (Versioned, bool, error) {
+	target, err := t.reach(context.Background(), targetAddr)
+	if err != nil {
+		return Versioned{}, false, err
+	}
+	value, found := target.Replica(key)
+	return value, found, nil
+}
+
+func (t *MemoryTransport) DeleteReplicas(targetAddr string, keys []string) error {
+	target, err := t.reach(context.Background(), targetAddr)
+	if err != nil {
+		return err
+	}
+	target.DropReplicas(keys)
+	return nil
+}
+
+// Latency returns the recent latency of the RPCs to the node
+func (t *MemoryTransport) Latency(targetAddr string) (time.Duration, bool) {
+	return t.latencies.Latency(targetAddr)
+}
+
+// IsInactive reports whether the node of the transport is failed
+func (t *MemoryTransport) IsInactive() bool {
+	t.net.mu.RLock()
+	defer t.net.mu.RUnlock()
+	return t.net.failed[t.address]
+}
+
+// BuildRing builds a ring of n nodes in a new MemoryNetwork, see MemoryNetwork.BuildRing
+func BuildRing(n int, opts ...Option) []*Node {
+	return NewMemoryNetwork().BuildRing(n, opts...)
+}
+
+// BuildRing creates n nodes named "node-<i>" in the network, joins them one after the other and
+// runs maintenance rounds until every successor link is correct, then fills the finger tables.
+// The nodes are returned in ring order. Addresses whose id collides with an earlier node are
+// skipped. Nodes log nothing unless a WithLogger option is given.
+func (net *MemoryNetwork) BuildRing(n int, opts ...Option) []*Node {
+	var addresses []string
+	ids := make(map[int]bool)
+	probe := Create("", append([]Option{WithLogger(logging.NewText(log.New(io.Discard, "", 0)))}, opts...)...)
+	for i := 0; len(addresses) < n; i++ {
+		addr := fmt.Sprintf("node-%d", i)
+		if id := probe.ringId(addr); !ids[id] {
+			ids[id] = true
+			addresses = append(addresses, addr)
+		}
+	}
+	return net.BuildRingOf(addresses, opts...)
+}
+
+// BuildRingOf builds a ring like BuildRing of nodes at the given addresses, e.g. the vnode
+// addresses of several processes. An address whose id collides with an earlier one is skipped.
+func (net *MemoryNetwork) BuildRingOf(addresses []string, opts ...Option) []*Node {
+
+	quiet := logging.NewText(log.New(io.Discard, "", 0))
+	opts = append([]Option{WithLogger(quiet)}, opts...)
+	ctx := context.Background()
+	n := len(addresses)
+
+	nodes := make([]*Node, 0, n)
+	ids := make(map[int]bool)
+	for _, addr := range addresses {
+		node := Create(addr, opts...)
+		if ids[node.Id()] {
+			continue
+		}
+		ids[node.Id()] = true
+		net.Add(node)
+
+		// The first node is a ring of its own, the others join through it
+		if len(nodes) > 0 {
+			successorAddr, err := nodes[0].FindSuccessor(ctx, node.Id())
+			if err != nil {
+				successorAddr = nodes[0].Address()
+			}
+			_ = node.Join(successorAddr)
+		}
+		nodes = append(nodes, node)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Id() < nodes[j].Id() })
+
+	// A join is completed by the stabilize rounds of its neighbours, every round settles at
+	// least one more link
+	for round := 0; round < 2*n && !ringSettled(nodes); round++ {
+		for _, node := range nodes {
+			node.Stabilize(ctx)
+		}
+	}
+	for _, node := range nodes {
+		node.FixAllFingers(ctx)
+	}
+	return nodes
+}
+
+// ringSettled reports whether the successor and predecessor of every node, given in ring order,
+// are its neighbours
+func ringSettled(nodes []*Node) bool {
+	for i, node := range nodes {
+		next := nodes[(i+1)%len(nodes)]
+		_, successorAddr := node.Successor()
+		_, predecessorAddr := next.Predecessor()
+		if successorAddr != next.Address() || (len(nodes) > 1 && predecessorAddr != node.Address()) {
+			return false
+		}
+	}
+	return true
+}
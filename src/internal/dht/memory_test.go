@@ -0,0 +1,232 @@
+package dht
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// ownerOf returns the address of the first node of the ring, given in ring order, whose id is
+// at or after the key id
+func ownerOf(nodes []*Node, keyId int) string {
+	for _, node := range nodes {
+		if node.Id() >= keyId {
+			return node.Address()
+		}
+	}
+	return nodes[0].Address()
+}
+
+func TestBuildRingLinksNeighbours(t *testing.T) {
+	nodes := BuildRing(50)
+	if len(nodes) != 50 {
+		t.Fatalf("BuildRing(50) returned %d nodes", len(nodes))
+	}
+	if !ringSettled(nodes) {
+		t.Fatal("successor and predecessor links are not settled")
+	}
+}
+
+func TestFindSuccessor(t *testing.T) {
+	nodes := BuildRing(50)
+	ctx := context.Background()
+
+	for keyId := 0; keyId < ID_SPACE_SIZE; keyId += ID_SPACE_SIZE / 500 {
+		want := ownerOf(nodes, keyId)
+		for _, from := range []*Node{nodes[0], nodes[17], nodes[49]} {
+			got, err := from.FindSuccessor(ctx, keyId)
+			if err != nil {
+				t.Fatalf("FindSuccessor(%d) from %s: %v", keyId, from.Address(), err)
+			}
+			if got != want {
+				t.Errorf("FindSuccessor(%d) from %s = %s, want %s", keyId, from.Address(), got, want)
+			}
+		}
+	}
+}
+
+func TestFindSuccessorAroundFailedNode(t *testing.T) {
+	net := NewMemoryNetwork()
+	nodes := net.BuildRing(10)
+	ctx := context.Background()
+
+	failed := nodes[4]
+	net.Fail(failed.Address())
+	for range 3 {
+		for _, node := range nodes {
+			if node != failed {
+				node.Stabilize(ctx)
+			}
+		}
+	}
+
+	// The keys of the failed node now belong to its successor
+	live := append(append([]*Node{}, nodes[:4]...), nodes[5:]...)
+	want := nodes[5].Address()
+	for _, from := range live {
+		got, err := from.FindSuccessor(ctx, failed.Id())
+		if err != nil {
+			t.Fatalf("FindSuccessor(%d) from %s: %v", failed.Id(), from.Address(), err)
+		}
+		if got != want {
+			t.Errorf("FindSuccessor(%d) from %s = %s, want %s", failed.Id(), from.Address(), got, want)
+		}
+	}
+}
+
+func TestStoreKeyReachesOwner(t *testing.T) {
+	nodes := BuildRing(20)
+
+	for i := range 100 {
+		key := fmt.Sprintf("key-%d", i)
+		if err := nodes[i%len(nodes)].transport.StoreKey(nodes[i%len(nodes)].Address(), key, []byte("v")); err != nil {
+			t.Fatalf("StoreKey(%q): %v", key, err)
+		}
+		owner := ownerOf(nodes, nodes[0].ringId(key))
+		for _, node := range nodes {
+			_, stored := node.load(key)
+			if stored != (node.Address() == owner) {
+				t.Errorf("key %q stored on %s: %v, owner is %s", key, node.Address(), stored, owner)
+			}
+		}
+	}
+}
+
+// processLoads builds a ring of the given processes with vnodes vnodes each, stores keys keys
+// through it and returns the number of keys each process holds
+func processLoads(t *testing.T, processes int, vnodes int, keys int) []int {
+	t.Helper()
+	var addresses []string
+	for p := range processes {
+		for v := range vnodes {
+			addresses = append(addresses, VnodeAddress(fmt.Sprintf("process-%d", p), v))
+		}
+	}
+	nodes := NewMemoryNetwork().BuildRingOf(addresses)
+
+	for i := range keys {
+		node := nodes[i%len(nodes)]
+		if err := node.transport.StoreKey(node.Address(), fmt.Sprintf("key-%d", i), []byte("v")); err != nil {
+			t.Fatalf("StoreKey: %v", err)
+		}
+	}
+	byProcess := make(map[string]int)
+	for _, node := range nodes {
+		process, _ := SplitVnode(node.Address())
+		byProcess[process] += node.KeyCount()
+	}
+	loads := make([]int, 0, processes)
+	for p := range processes {
+		loads = append(loads, byProcess[fmt.Sprintf("process-%d", p)])
+	}
+	return loads
+}
+
+// variance returns the population variance of the loads
+func variance(loads []int) float64 {
+	var sum float64
+	for _, load := range loads {
+		sum += float64(load)
+	}
+	mean := sum / float64(len(loads))
+	var squares float64
+	for _, load := range loads {
+		squares += (float64(load) - mean) * (float64(load) - mean)
+	}
+	return squares / float64(len(loads))
+}
+
+func TestVnodesEvenOutLoad(t *testing.T) {
+	const processes, keys = 8, 4000
+
+	// The variance of the keys per process shrinks like 1/V, the steps are wide enough for the
+	// placement of a few vnodes not to reverse the order
+	last := -1.0
+	for _, v := range []int{1, 4, 16, 32} {
+		loads := processLoads(t, processes, v, keys)
+		got := variance(loads)
+		t.Logf("%d vnodes per process: keys %v, variance %.0f", v, loads, got)
+		if last >= 0 && got >= last {
+			t.Errorf("variance with %d vnodes per process = %.0f, want below %.0f of the fewer vnodes before", v, got, last)
+		}
+		last = got
+	}
+}
+
+func TestStabilizeRecoversFromPhantomSuccessor(t *testing.T) {
+	nodes := BuildRing(8)
+	node := nodes[0]
+	ctx := context.Background()
+	const phantom = "never-existed:1"
+
+	node.SetSuccessor(phantom)
+	for round := 1; ; round++ {
+		if round > phantomSuccessorThreshold {
+			t.Fatalf("successor still %q after %d stabilize rounds", phantom, round-1)
+		}
+		for _, n := range nodes {
+			n.Stabilize(ctx)
+		}
+		if _, successor := node.Successor(); successor != phantom {
+			break
+		}
+	}
+
+	if _, successor := node.Successor(); successor != nodes[1].Address() {
+		t.Errorf("successor after the phantom = %q, want %s", successor, nodes[1].Address())
+	}
+	node.FixAllFingers(ctx)
+	if slices.Contains(node.SuccessorList(), phantom) || slices.Contains(node.FingerTable(), phantom) {
+		t.Errorf("phantom %q still known, successor list %v, fingers %v", phantom, node.SuccessorList(), node.FingerTable())
+	}
+	if !ringSettled(nodes) {
+		t.Error("successor and predecessor links are not settled after the phantom was removed")
+	}
+}
+
+func TestNextHopNeverForwardsToSelf(t *testing.T) {
+	for _, size := range []int{2, 3, 5} {
+		t.Run(fmt.Sprintf("%d nodes", size), func(t *testing.T) {
+			nodes := BuildRing(size)
+			byAddress := make(map[string]*Node)
+			for _, node := range nodes {
+				byAddress[node.Address()] = node
+			}
+
+			for i := range 2000 {
+				key := fmt.Sprintf("key-%d", i)
+				for _, from := range nodes {
+					current, hops := from, 0
+					for next := current.NextHop(key); next != ""; next = current.NextHop(key) {
+						if next == current.Address() {
+							t.Fatalf("%s forwards key %q to itself", next, key)
+						}
+						if hops++; hops > size {
+							t.Fatalf("key %q from %s not owned after %d hops", key, from.Address(), hops-1)
+						}
+						current = byAddress[next]
+					}
+					if want := ownerOf(nodes, from.ringId(key)); current.Address() != want {
+						t.Fatalf("key %q from %s reached %s, want the owner %s", key, from.Address(), current.Address(), want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestClosestPrecedingNodeShortCircuitsToSuccessor(t *testing.T) {
+	nodes := BuildRing(8)
+	node := nodes[0]
+	successorId, successorAddr := node.Successor()
+
+	// Every key in (node, successor] goes to the successor without walking the fingers
+	gap := ClockwiseDistance(node.Id(), successorId, node.idSpaceSize)
+	for d := 1; d <= gap; d += max(1, gap/100) {
+		keyId := (node.Id() + d) % node.idSpaceSize
+		if _, got := node.closestPrecedingNode(keyId); got != successorAddr {
+			t.Fatalf("closestPrecedingNode(%d) = %s, want the successor %s", keyId, got, successorAddr)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package dht
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+)
+
+// SetNetwork links the node into the ring of the given addresses without a join, e.g. to start a
+// known set of nodes at once. The successor, predecessor, successor list and fingers are computed
+// from the list. The list is validated first and the node's state is only changed once it
+// passed, a list that is empty, holds a malformed address or misses this node's address is
+// refused with ErrInvalidNetwork and the node keeps its links and fingers.
+func (n *Node) SetNetwork(addresses []string) error {
+
+	ring, err := n.validateNetwork(addresses)
+	if err != nil {
+		n.logger.Warn("SetNetwork", "network refused, node unchanged", "nodes", len(addresses), "err", err)
+		return err
+	}
+
+	// ring is sorted by id, this node's successor is the next entry and its predecessor the previous one
+	self := slices.IndexFunc(ring, func(other node) bool { return other.address == n.Address() })
+	successor := ring[(self+1)%len(ring)]
+	predecessor := ring[(self-1+len(ring))%len(ring)]
+
+	var successors []string
+	for i := 1; i < len(ring); i++ {
+		successors = append(successors, ring[(self+i)%len(ring)].address)
+	}
+	n.setSuccessorList(successors)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if previous := n.successor.address; previous != successor.address {
+		n.successor = successor
+		n.persistSuccessor(successor.address)
+		n.publish(EventSuccessor, successor.address, previous, "")
+	}
+	if previous := n.predecessor.address; previous != predecessor.address {
+		n.predecessor = predecessor
+		n.publish(EventPredecessor, predecessor.address, previous, "")
+	}
+
+	// The first node at or after the start of every finger, wrapping around the ring
+	for i := range n.finger {
+		owner := ring[0]
+		for _, other := range ring {
+			if other.id >= n.finger[i].start {
+				owner = other
+				break
+			}
+		}
+		n.finger[i].node = owner
+	}
+	n.epoch++
+
+	n.logger.Info("SetNetwork", "links and fingers set from the network", "nodes", len(ring), "successor", successor.address, "predecessor", predecessor.address)
+	return nil
+}
+
+// validateNetwork returns the nodes of the list sorted by ring id, without changing any state.
+// Repeated addresses are kept once, two addresses with the same ring id are refused like a join
+// colliding with its successor.
+func (n *Node) validateNetwork(addresses []string) ([]node, error) {
+
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("%w: the list is empty", ErrInvalidNetwork)
+	}
+
+	self := n.Address()
+	containsSelf := false
+	ids := make(map[int]string, len(addresses))
+	ring := make([]node, 0, len(addresses))
+	for _, addr := range addresses {
+		if !validAddress(addr) {
+			return nil, fmt.Errorf("%w: malformed address %q, want host:port", ErrInvalidNetwork, addr)
+		}
+		id := n.ringId(addr)
+		if other, ok := ids[id]; ok {
+			if other == addr {
+				continue
+			}
+			return nil, fmt.Errorf("%w: %s and %s both have id %d", ErrInvalidNetwork, other, addr, id)
+		}
+		ids[id] = addr
+		ring = append(ring, node{id: id, address: addr})
+		containsSelf = containsSelf || addr == self
+	}
+	if !containsSelf {
+		return nil, fmt.Errorf("%w: the list does not contain this node's address %s", ErrInvalidNetwork, self)
+	}
+
+	slices.SortFunc(ring, func(a, b node) int { return a.id - b.id })
+	return ring, nil
+}
+
+// validAddress reports whether the address is a ring address, host:port with a port number,
+// optionally followed by a vnode index
+func validAddress(addr string) bool {
+	process, _ := SplitVnode(addr)
+	host, port, err := net.SplitHostPort(process)
+	if err != nil || host == "" {
+		return false
+	}
+	number, err := strconv.Atoi(port)
+	return err == nil && number > 0 && number <= 65535
+}
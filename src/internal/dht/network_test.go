@@ -0,0 +1,85 @@
+package dht
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// linkState is everything SetNetwork may change
+type linkState struct {
+	successor   string
+	predecessor string
+	successors  []string
+	fingers     []string
+	epoch       uint64
+}
+
+func linksOf(n *Node) linkState {
+	_, successor := n.Successor()
+	_, predecessor := n.Predecessor()
+	return linkState{successor, predecessor, n.SuccessorList(), n.FingerTable(), n.Epoch()}
+}
+
+func (s linkState) equal(other linkState) bool {
+	return s.successor == other.successor && s.predecessor == other.predecessor && s.epoch == other.epoch &&
+		slices.Equal(s.successors, other.successors) && slices.Equal(s.fingers, other.fingers)
+}
+
+func TestSetNetworkRefusesInvalidLists(t *testing.T) {
+	const self = "127.0.0.1:8000"
+	tests := []struct {
+		name      string
+		addresses []string
+	}{
+		{"empty", nil},
+		{"missing self", []string{"127.0.0.1:8001", "127.0.0.1:8002"}},
+		{"no port", []string{self, "127.0.0.1"}},
+		{"port out of range", []string{self, "127.0.0.1:70000"}},
+		{"no host", []string{self, ":8001"}},
+		{"empty address", []string{self, ""}},
+		{"url", []string{self, "http://127.0.0.1:8001"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := Create(self, WithLogger(quietLogger()))
+			node.SetLinks("127.0.0.1:8005", "127.0.0.1:8006")
+			before := linksOf(node)
+
+			if err := node.SetNetwork(tt.addresses); !errors.Is(err, ErrInvalidNetwork) {
+				t.Fatalf("SetNetwork(%q) = %v, want ErrInvalidNetwork", tt.addresses, err)
+			}
+			if after := linksOf(node); !after.equal(before) {
+				t.Errorf("links after a refused network = %+v, want them untouched %+v", after, before)
+			}
+		})
+	}
+}
+
+func TestSetNetworkLinksTheRing(t *testing.T) {
+	addresses := []string{"127.0.0.1:8000", "127.0.0.1:8001", "127.0.0.1:8002", "127.0.0.1:8003"}
+	var nodes []*Node
+	for _, addr := range addresses {
+		nodes = append(nodes, Create(addr, WithLogger(quietLogger())))
+	}
+	for _, node := range nodes {
+		if err := node.SetNetwork(addresses); err != nil {
+			t.Fatalf("SetNetwork on %s: %v", node.Address(), err)
+		}
+	}
+	sorted := slices.Clone(nodes)
+	slices.SortFunc(sorted, func(a, b *Node) int { return a.Id() - b.Id() })
+	if !ringSettled(sorted) {
+		t.Fatal("successor and predecessor links don't form the ring")
+	}
+
+	// Every finger holds the first node at or after its start
+	for _, node := range nodes {
+		for i, addr := range node.FingerTable() {
+			if want := ownerOf(sorted, node.finger[i].start); addr != want {
+				t.Errorf("finger %d of %s = %s, want %s", i, node.Address(), addr, want)
+			}
+		}
+	}
+}
@@ -1,17 +1,43 @@
 package dht
 
 import (
+	"assignment/internal/logging"
+	"assignment/internal/metrics"
+	"bytes"
 	"context"
 	"fmt"
-	"log"
 	"math/rand"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
+	// Default identifier space, override with WithM
 	M             = 16
 	ID_SPACE_SIZE = 1 << M
+
+	// Number of consecutive stabilize rounds an unreachable successor is tolerated before it is treated as a phantom
+	phantomSuccessorThreshold = 3
+
+	// A successor that already knows this node as its predecessor is re-notified every notifyHeartbeatRounds stabilize rounds
+	notifyHeartbeatRounds = 5
+
+	// Default attempts per transport call in maintenance and lookups, override with WithMaxRetries
+	DefaultMaxRetries = 2
+
+	// Backoff before the second attempt of a retried transport call, doubled for every further attempt
+	retryBackoff = 25 * time.Millisecond
+
+	// Largest backoff between two attempts of retry, retryContext takes its own
+	maxRetryBackoff = 2 * time.Second
+
+	// Default number of consecutive failed predecessor checks before the predecessor is cleared,
+	// override with WithPredecessorFailureThreshold
+	DefaultPredecessorFailureThreshold = 3
 )
 
 type Node struct {
@@ -20,8 +46,70 @@ type Node struct {
 	successor   node
 	finger      []fingerEntry
 	data        sync.Map
+	replicas    sync.Map     // replicas of the keys of the predecessors in the replica set
+	dataMu      sync.RWMutex // held shared by writers to data, exclusively by consistent dumps
+	handoffMu   sync.Mutex   // serializes key acquisition from the successor
+	movingMu    sync.Mutex   // guards moving, held by writers across the check and the store
+	moving      map[string]bool
 	transport   Transport
 	mu          sync.RWMutex
+
+	// Backup successors in ring order, refreshed from the successor in Stabilize
+	successorList []node
+
+	// Consecutive stabilize rounds the successor was unreachable
+	successorFailures int
+
+	// Consecutive failed checks of the current predecessor
+	predecessorFailures int
+
+	// Successor of the last successful notify and the stabilize rounds since
+	lastNotified      string
+	roundsSinceNotify int
+
+	// Topology epoch, incremented on every successor/predecessor/finger change
+	epoch uint64
+
+	// Config
+	m                           int // number of bits in the identifier space, also the finger table size
+	idSpaceSize                 int // 2^m
+	hasher                      Hasher
+	successorListSize           int
+	maxRetries                  int
+	predecessorFailureThreshold int
+	quietMaintenance            bool
+	verifyFingers               bool
+	preferLocal                 bool
+	lookupMode                  string
+	replicationFactor           int
+	maxKeys                     int
+	maxBytes                    int
+	rejectWhenFull              bool
+	maxTTL                      time.Duration // 0 if keys may live forever
+	readStrategy                string
+	readTurn                    atomic.Uint64 // reads spread by ReadRoundRobin so far
+	dataBytes                   atomic.Int64  // total length of the values in data as held, i.e. encrypted if enabled
+	dataKeys                    atomic.Int64  // number of keys in data, expired ones until they are swept
+
+	// Maintenance runs every tickInterval plus up to 50ms jitter, override with WithTiming
+	tickInterval time.Duration
+
+	// Stretches the maintenance interval while peers answer 503 or time out
+	throttle maintenanceThrottle
+
+	// Gets and puts of the owned keys, see HotKeys
+	access accessCounter
+
+	// Writes the data to disk, nil if persistence is disabled
+	persist *persister
+
+	// Encrypts values at rest, nil if disabled
+	cipher *valueCipher
+
+	// Membership changes are published here, see "/events"
+	events *EventBus
+
+	logger logging.Logger
 }
 
 type node struct {
@@ -34,35 +122,64 @@ type fingerEntry struct {
 	node  node
 }
 
-func Create(address string) *Node {
+func Create(address string, opts ...Option) *Node {
+
+	n := &Node{
+		m:                           M,
+		idSpaceSize:                 ID_SPACE_SIZE,
+		hasher:                      DefaultHasher,
+		successorListSize:           DefaultSuccessorListSize,
+		maxRetries:                  DefaultMaxRetries,
+		predecessorFailureThreshold: DefaultPredecessorFailureThreshold,
+		lookupMode:                  LookupRecursive,
+		readStrategy:                ReadPrimary,
+		replicationFactor:           DefaultReplicationFactor,
+		tickInterval:                DefaultTiming().MaintenanceInterval,
+		throttle:                    maintenanceThrottle{maxBackoff: DefaultMaxMaintenanceBackoff},
+		access:                      accessCounter{capacity: DefaultHotKeyCapacity},
+		moving:                      make(map[string]bool),
+		events:                      NewEventBus(),
+		logger:                      logging.NewText(nil),
+	}
+
+	for _, opt := range opts {
+		opt(n)
+	}
 
 	// self
 	self := node{
-		id:      KeyToRingId(address, ID_SPACE_SIZE),
+		id:      n.ringId(address),
 		address: address,
 	}
 
-	finger := make([]fingerEntry, M)
+	finger := make([]fingerEntry, n.m)
 
 	// Initialize finger table with self
-	for i := 0; i < M; i++ {
-		fingerKey := (self.id + (1 << i)) % ID_SPACE_SIZE
+	for i := 0; i < n.m; i++ {
+		fingerKey := (self.id + (1 << i)) % n.idSpaceSize
 		finger[i] = fingerEntry{
 			start: fingerKey,
 			node:  self,
 		}
 	}
 
-	node := &Node{
-		node:        self,
-		successor:   self,
-		predecessor: node{},
-		finger:      finger,
-	}
+	n.node = self
+	n.successor = self
+	n.predecessor = node{}
+	n.finger = finger
 
-	log.Printf("Node created with keyId: %d", node.Id())
+	n.logger = n.logger.WithNode(n.id, n.address)
+	n.logger.Info("Create", "node created", "id", n.Id(), "m", n.m, "hash", n.hasher.Name())
 
-	return node
+	// Reload the data of the previous run, persistence is disabled if that fails
+	if n.persist != nil {
+		if err := n.restore(); err != nil {
+			n.persist.err = err
+			n.logger.Error("Create", "failed to restore data, persistence disabled", "path", n.persist.path, "err", err)
+		}
+	}
+
+	return n
 }
 
 // SetTransport sets the transport of the node
@@ -74,7 +191,7 @@ func (n *Node) SetTransport(transport Transport) {
 
 // RunMaintenance runs the maintenance goroutines for the node at regular intervals.
 func (n *Node) RunMaintenance(ctx context.Context) {
-	maintenanceInterval := 200*time.Millisecond + time.Duration(rand.Intn(50))*time.Millisecond
+	maintenanceInterval := n.tickInterval + time.Duration(rand.Intn(50))*time.Millisecond
 	maintenanceTicker := time.NewTicker(maintenanceInterval)
 
 	defer func() {
@@ -82,6 +199,8 @@ func (n *Node) RunMaintenance(ctx context.Context) {
 	}()
 
 	nextFingerIndex := 0
+	nextSweepIndex := 0
+	ticks := 0
 
 	for {
 		select {
@@ -91,18 +210,44 @@ func (n *Node) RunMaintenance(ctx context.Context) {
 		case <-maintenanceTicker.C:
 			if !n.transport.IsInactive() {
 				// Check if predecessor is alive, set to empty if not
-				go n.CheckPredecessor()
+				go n.CheckPredecessor(ctx)
 			}
 
 			if !n.transport.IsInactive() {
 				// Verify and update successor/predecessor links; detect node joins.
-				n.Stabilize()
+				n.Stabilize(ctx)
 			}
 
 			if !n.transport.IsInactive() {
 				// Fix finger table entries
-				n.FixFinger(nextFingerIndex)
-				nextFingerIndex = (nextFingerIndex + 1) % M
+				n.FixFinger(ctx, nextFingerIndex)
+				nextFingerIndex = (nextFingerIndex + 1) % n.m
+			}
+
+			if !n.transport.IsInactive() {
+				// Ping one distinct finger per tick, replacing it right away if it is dead
+				nextSweepIndex = n.SweepFinger(ctx, nextSweepIndex)
+			}
+
+			// Evict expired keys, also while inactive so they don't outlive a crash
+			ticks++
+			if ticks%expirySweepTicks == 0 {
+				n.sweepExpired()
+			}
+
+			// Fold the write-ahead log into the snapshot once it has grown
+			if ticks%compactCheckTicks == 0 {
+				n.maybeCompact()
+			}
+
+			if !n.transport.IsInactive() && ticks%replicaSyncTicks == 0 {
+				// Refresh the replicas of the owned keys and take over the replicas now owned
+				n.syncReplicas()
+			}
+
+			// Back off while peers are overloaded, e.g. during a mass join
+			if interval, changed := n.throttledInterval(maintenanceInterval); changed {
+				maintenanceTicker.Reset(interval)
 			}
 		}
 	}
@@ -111,38 +256,83 @@ func (n *Node) RunMaintenance(ctx context.Context) {
 // Stabilize stabilizes the node by updating the successor of the node
 // Verify and update successor/predecessor links; detect node joins.
 // New node runs stabilize which will inform others about its existence.
-func (n *Node) Stabilize() {
+// The RPCs of the round are cancelled with ctx.
+func (n *Node) Stabilize(ctx context.Context) {
+
+	metrics.StabilizeRounds.Inc()
 
 	currSuccId, currSuccAddr := n.Successor()
 
+	// Live candidate that reported this node as its predecessor this round
+	acknowledgedBy := ""
+
 	// If successor is self, we already know predecessor locally
 	if currSuccAddr == n.Address() {
 		_, predAddr := n.Predecessor()
 		if predAddr != "" && predAddr != n.Address() {
-			predId := KeyToRingId(predAddr, ID_SPACE_SIZE)
+			predId := n.ringId(predAddr)
 			if InIntervalOpen(predId, n.Id(), currSuccId) {
-				log.Printf("Stabilize: successor is self, own predecessor is in interval, successor updated to '%s' (id: '%d')", predAddr, predId)
+				n.logger.Info("Stabilize", "successor is self, own predecessor is in interval, successor updated", "successor", predAddr, "successor_id", predId)
 				n.SetSuccessor(predAddr)
 			}
 		}
+
+		// Without a predecessor, e.g. cleared after failed checks, the ring is found again through
+		// the other nodes this node still knows
+		if _, succAddr := n.Successor(); succAddr == n.Address() {
+			if candidate := n.closestLiveKnownNode(ctx); candidate != "" {
+				n.logger.Info("Stabilize", "successor is self, a known node answered, successor updated", "successor", candidate, "successor_id", n.ringId(candidate))
+				n.SetSuccessor(candidate)
+			}
+		}
 	} else {
 		// successor is another node — fetch its predecessor over transport
 		candidates := n.closestSuccessorNodes()
 		liveCandidateExists := false
 
+		// Track whether the successor is a phantom, i.e. unreachable and unknown to the live nodes
+		successorUnreachable := false
+		unreachable := make(map[string]bool)
+		successorSeenAsPredecessor := false
+		firstLiveCandidate := ""
+
 		// candidates is a list of closest successor nodes to the key, deduplicated
 		for _, candidate := range candidates {
 
-			predAddr, err := n.transport.GetPredecessor(candidate)
+			predAddr, err := n.retryRPC(ctx, func() (string, error) {
+				return n.transport.GetPredecessor(ctx, candidate)
+			})
+			if ctx.Err() != nil {
+				// Cancelled, e.g. on shutdown, an unanswered call says nothing about the candidate
+				return
+			}
 			if err != nil {
-				log.Printf("Stabilize WARNING: failed to get predecessor from candidate '%s': %v", candidate, err)
-				log.Println("Stabilize: candidates list", candidates)
+				metrics.FailedRPCs.WithLabelValues("GetPredecessor").Inc()
+				n.logger.Warn("Stabilize", "failed to get predecessor from candidate", "candidate", candidate, "err", err)
+				n.maintenanceInfo("Stabilize", "candidates list", "candidates", candidates)
+				unreachable[candidate] = true
+				if candidate == currSuccAddr {
+					successorUnreachable = true
+				}
 				continue
 			}
 
+			if firstLiveCandidate == "" {
+				firstLiveCandidate = candidate
+
+				// Successor is dead, promote the first live backup from the successor list
+				if successorUnreachable && n.inSuccessorList(candidate) {
+					n.logger.Info("Stabilize", "successor unreachable, falling back to successor list entry", "successor", currSuccAddr, "candidate", candidate)
+					n.SetSuccessor(candidate)
+				}
+			}
+			if predAddr == currSuccAddr {
+				successorSeenAsPredecessor = true
+			}
+
 			if predAddr == "" {
 				// candidate alive but predecessor unknown, keep it
-				log.Printf("Stabilize: candidate '%s' has no predecessor, setting as successor", candidate)
+				n.maintenanceInfo("Stabilize", "candidate has no predecessor, setting as successor", "candidate", candidate)
 				n.SetSuccessor(candidate)
 				liveCandidateExists = true
 				break
@@ -150,45 +340,123 @@ func (n *Node) Stabilize() {
 
 			if predAddr == n.Address() {
 				// successor’s predecessor is self — stable
+				acknowledgedBy = candidate
 				liveCandidateExists = true
 				break
 			}
 
 			liveCandidateExists = true
 
-			predId := KeyToRingId(predAddr, ID_SPACE_SIZE)
+			if unreachable[predAddr] {
+				// Candidate still points at a node that didn't answer this round, e.g. the dead
+				// successor or a dead backup in front of it, never adopt it
+				break
+			}
+
+			predId := n.ringId(predAddr)
 			currSuccId, _ = n.Successor()
 			if InIntervalOpen(predId, n.Id(), currSuccId) {
-				log.Printf("Stabilize: successor's (id: '%d') predecessor '%s' (id: '%d') is in interval, updating successor to '%s' (id: '%d')", currSuccId, predAddr, predId, predAddr, predId)
+				n.logger.Info("Stabilize", "successor's predecessor is in interval, updating successor", "successor_id", currSuccId, "predecessor", predAddr, "predecessor_id", predId)
 				n.SetSuccessor(predAddr)
 				break
 			}
 
-			log.Printf("Stabilize: successor's predecessor '%s' (id: '%d') not in interval (predId: '%d', currSuccId: '%d')", predAddr, predId, n.Id(), currSuccId)
+			n.maintenanceInfo("Stabilize", "successor's predecessor not in interval", "predecessor", predAddr, "predecessor_id", predId, "successor_id", currSuccId)
 		}
 
 		if !liveCandidateExists {
 			// No successor found, set ourselves as the successor
-			log.Printf("Stabilize: no live successor found, setting ourselves as the successor")
+			n.logger.Info("Stabilize", "no live successor found, setting ourselves as the successor")
 			n.SetSuccessor(n.Address())
+		} else {
+			n.checkPhantomSuccessor(currSuccAddr, successorUnreachable && !successorSeenAsPredecessor, firstLiveCandidate)
 		}
 	}
 
 	// Update the "current successor" if new successor is set
 	_, currSuccAddr = n.Successor()
 
+	// Refresh the backup successors from the successor
+	n.updateSuccessorList(currSuccAddr)
+
 	if currSuccAddr == n.Address() {
 		// Skip notify if successor is self
 		return
 	}
 
+	// Skip the notify while the successor already knows us, apart from a heartbeat
+	if !n.shouldNotify(currSuccAddr, acknowledgedBy == currSuccAddr) {
+		return
+	}
+
 	// Notify successor
-	if err := n.transport.Notify(currSuccAddr, n.Address()); err != nil {
-		log.Printf("Stabilize: FAILED to notify successor '%s': %v", currSuccAddr, err)
+	_, err := n.retryRPC(ctx, func() (string, error) {
+		return "", n.transport.Notify(ctx, currSuccAddr, n.Address())
+	})
+	if err != nil {
+		metrics.FailedRPCs.WithLabelValues("Notify").Inc()
+		n.logger.Error("Stabilize", "failed to notify successor", "successor", currSuccAddr, "err", err)
 	}
+	n.notified(currSuccAddr, err == nil)
 }
 
-func (n *Node) FixFinger(index int) {
+// shouldNotify reports whether Stabilize has to notify the successor this round.
+// A new successor, or one that does not report this node as its predecessor, is notified immediately.
+// Otherwise the notify is only sent every notifyHeartbeatRounds rounds.
+func (n *Node) shouldNotify(successorAddr string, acknowledged bool) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if successorAddr != n.lastNotified || !acknowledged {
+		return true
+	}
+
+	n.roundsSinceNotify++
+	return n.roundsSinceNotify >= notifyHeartbeatRounds
+}
+
+// notified records the outcome of a notify, a failed notify is retried the next round
+func (n *Node) notified(successorAddr string, ok bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.roundsSinceNotify = 0
+	n.lastNotified = ""
+	if ok {
+		n.lastNotified = successorAddr
+	}
+}
+
+// checkPhantomSuccessor replaces the successor with the first live candidate if it has been
+// unreachable and unknown to the live nodes for several consecutive stabilize rounds.
+func (n *Node) checkPhantomSuccessor(successorAddr string, suspect bool, liveCandidate string) {
+
+	n.mu.Lock()
+	if !suspect || n.successor.address != successorAddr {
+		// Successor reachable or already replaced during this round
+		n.successorFailures = 0
+		n.mu.Unlock()
+		return
+	}
+	n.successorFailures++
+	failures := n.successorFailures
+	n.mu.Unlock()
+
+	if failures < phantomSuccessorThreshold {
+		return
+	}
+
+	n.logger.Info("Stabilize", "successor unreachable and not known by any live node, removing phantom successor", "successor", successorAddr, "rounds", failures, "replacement", liveCandidate)
+
+	n.removeFailedFinger(successorAddr)
+	n.SetSuccessor(liveCandidate)
+
+	n.mu.Lock()
+	n.successorFailures = 0
+	n.mu.Unlock()
+}
+
+func (n *Node) FixFinger(ctx context.Context, index int) {
 
 	// Work directly with the original finger table
 	n.mu.RLock()
@@ -196,45 +464,66 @@ func (n *Node) FixFinger(index int) {
 	currentFingerAddr := n.finger[index].node.address
 	n.mu.RUnlock()
 
-	// Find the closest successor to the entry id
-	successorAddr, err := n.FindSuccessor(start)
+	// Find the closest successor to the entry id, never from the entry itself
+	successorAddr, err := n.findSuccessor(ctx, start, false)
+	if ctx.Err() != nil {
+		return
+	}
 	if err != nil {
-		log.Printf("FixFinger: ERROR, failed to find successor to keyId %d", start)
+		metrics.FingerFixes.WithLabelValues("false").Inc()
+		n.logger.Error("FixFinger", "failed to find successor", "start", start, "err", err)
 		n.removeFailedFinger(currentFingerAddr)
 		return
 	}
 
 	if successorAddr == n.Address() {
 		// Skip update if successor is self
+		metrics.FingerFixes.WithLabelValues("false").Inc()
 		return
 	}
 
 	if successorAddr == currentFingerAddr {
 		// Skip update if no change in current finger entry
+		metrics.FingerFixes.WithLabelValues("false").Inc()
 		return
 	}
 
+	// A stale lookup can return a dead node, don't let it into the finger table
+	if n.verifyFingers {
+		if alive, err := n.transport.CheckAliveExpecting(ctx, successorAddr, n.ringId(successorAddr)); !alive || err != nil {
+			metrics.FingerFixes.WithLabelValues("false").Inc()
+			metrics.FailedRPCs.WithLabelValues("CheckAlive").Inc()
+			n.logger.Warn("FixFinger", "resolved node is not reachable, skipping update", "index", index, "node", successorAddr, "err", err)
+			return
+		}
+	}
+	metrics.FingerFixes.WithLabelValues("true").Inc()
+
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
 	// Update the finger
-	successorId := KeyToRingId(successorAddr, ID_SPACE_SIZE)
+	successorId := n.ringId(successorAddr)
 	n.finger[index].node = node{
 		id:      successorId,
 		address: successorAddr,
 	}
+	n.epoch++
 
-	log.Printf("FixFinger: entry at index %d set to '%s' (id: '%d')", index, successorAddr, successorId)
+	n.logger.Info("FixFinger", "entry updated", "index", index, "node", successorAddr, "node_id", successorId)
 
-	if index == M-1 {
-		log.Println("\n", n.String())
+	if index == n.m-1 && !n.quietMaintenance {
+		n.logger.Info("FixFinger", "finger table", "node", n.String())
 	}
 }
 
 // CheckPredecessor detects failed or disconnected predecessors.
-func (n *Node) CheckPredecessor() {
+// The predecessor is only cleared after predecessorFailureThreshold consecutive failed checks, one
+// per round, so a single timeout under load does not unlink a healthy predecessor. A successful
+// check or a new predecessor resets the count. A cancelled ctx ends the check without touching the predecessor.
+func (n *Node) CheckPredecessor(ctx context.Context) {
 
-	_, predAddr := n.Predecessor()
+	predId, predAddr := n.Predecessor()
 
 	// If the predecessor is the same as the node ("empty"), do nothing
 	if predAddr == "" {
@@ -242,51 +531,98 @@ func (n *Node) CheckPredecessor() {
 		return
 	}
 
-	// Retry logic
-	maxRetries := 3
-	var err error
-	alive := false
-	for i := 0; i < maxRetries; i++ {
-		alive, err = n.transport.CheckAlive(predAddr)
-		if alive && err == nil {
-			if i > 1 {
-				log.Printf("CheckPredecessor: SUCCESS on %v attempt, predecessor '%s' is alive", i+1, predAddr)
-			}
-			break
+	alive, err := n.transport.CheckAliveExpecting(ctx, predAddr, predId)
+	if ctx.Err() != nil {
+		return
+	}
+
+	n.mu.Lock()
+	if n.predecessor.address != predAddr || (alive && err == nil) {
+		// Predecessor alive or replaced during the check
+		if n.predecessorFailures > 0 && n.predecessor.address == predAddr {
+			n.logger.Info("CheckPredecessor", "predecessor is alive again", "predecessor", predAddr, "failed_checks", n.predecessorFailures)
 		}
-		timeOutDuration := time.Duration(i*i) * 20 * time.Millisecond // quadratic backoffs
-		log.Printf("CheckPredecessor: ERROR '%s': %v, waiting %v before next attempt %v/%v", predAddr, err, timeOutDuration, i+1, maxRetries)
-		time.Sleep(timeOutDuration)
+		n.predecessorFailures = 0
+		n.mu.Unlock()
+		return
+	}
+	n.predecessorFailures++
+	failures := n.predecessorFailures
+	dead := failures >= n.predecessorFailureThreshold
+	if dead {
+		n.predecessorFailures = 0
 	}
+	n.mu.Unlock()
 
-	if !alive || err != nil {
-		log.Printf("CheckPredecessor: WARNING, '%s' is NOT alive, setting own predecessor to empty", predAddr)
-		n.SetPredecessor("")
+	metrics.FailedRPCs.WithLabelValues("CheckAlive").Inc()
+	if !dead {
+		n.logger.Error("CheckPredecessor", "predecessor check failed, tolerating until the threshold", "predecessor", predAddr, "err", err, "failed_checks", failures, "threshold", n.predecessorFailureThreshold)
+		return
 	}
+
+	n.logger.Warn("CheckPredecessor", "predecessor is NOT alive, setting own predecessor to empty", "predecessor", predAddr, "failed_checks", failures)
+	n.publish(EventNodeFailed, predAddr, "", "check_predecessor")
+	n.SetPredecessor("", false)
+}
+
+// LeaveResult reports whether the neighbours of a leaving node were told to link around it
+type LeaveResult struct {
+	Successor           string `json:"successor"`
+	Predecessor         string `json:"predecessor"`
+	SuccessorNotified   bool   `json:"successor_notified"`
+	PredecessorNotified bool   `json:"predecessor_notified"`
 }
 
-func (n *Node) Leave() error {
+// Complete reports whether both neighbours were notified, a neighbour that is unset or self counts as notified
+func (r LeaveResult) Complete() bool {
+	return r.SuccessorNotified && r.PredecessorNotified
+}
+
+// Leave hands off the data to the successor, links the predecessor and successor around this node
+// and returns to starting state. The result reports which neighbours could not be notified, the
+// ring then relies on stabilization to close the gap. Returns an error if the handoff failed, the
+// node is then still part of the ring.
+func (n *Node) Leave() (LeaveResult, error) {
 	// Notify the successor that the node is leaving, and update the successor to the predecessor
 	successorId, successorAddr := n.Successor()
 	predecessorId, predecessorAddr := n.Predecessor()
 
-	log.Printf("Leaving ring, connecting predecessor '%s' (id: '%d') to successor '%s' (id: '%d')", predecessorAddr, predecessorId, successorAddr, successorId)
+	n.logger.Info("Leave", "leaving ring, connecting predecessor to successor", "predecessor", predecessorAddr, "predecessor_id", predecessorId, "successor", successorAddr, "successor_id", successorId)
 
-	if successorAddr != "" {
-		if err := n.transport.SetPredecessor(successorAddr, predecessorAddr); err != nil {
-			log.Printf("Leave:failed to notify successor of predecessor '%s': %v", successorAddr, err)
+	result := LeaveResult{
+		Successor:           successorAddr,
+		Predecessor:         predecessorAddr,
+		SuccessorNotified:   true,
+		PredecessorNotified: true,
+	}
+
+	// Hand off the data before rewiring, abort the leave if any key would be lost
+	if successorAddr != "" && successorAddr != n.Address() {
+		if err := n.handOffData(successorAddr); err != nil {
+			return result, fmt.Errorf("leave aborted: %w", err)
 		}
 	}
 
-	if predecessorAddr != "" {
-		if err := n.transport.SetSuccessor(predecessorAddr, successorAddr); err != nil {
-			log.Printf("Leave:failed to notify predecessor of successor '%s': %v", predecessorAddr, err)
+	if successorAddr != "" && successorAddr != n.Address() {
+		if err := n.transport.SetPredecessor(context.Background(), successorAddr, predecessorAddr); err != nil {
+			n.logger.Error("Leave", "failed to notify successor of predecessor", "successor", successorAddr, "err", err)
+			result.SuccessorNotified = false
 		}
 	}
 
+	if predecessorAddr != "" && predecessorAddr != n.Address() {
+		if err := n.transport.SetSuccessor(context.Background(), predecessorAddr, successorAddr); err != nil {
+			n.logger.Error("Leave", "failed to notify predecessor of successor", "predecessor", predecessorAddr, "err", err)
+			result.PredecessorNotified = false
+		}
+	}
+
+	// Replicas held for the old neighbours are stale once out of the ring
+	n.replicas.Clear()
+
 	// Reset to starting state
 	n.resetToStartingState()
-	return nil
+	return result, nil
 }
 
 // Id returns the id of the node
@@ -318,47 +654,148 @@ func (n *Node) Predecessor() (id int, address string) {
 	return n.predecessor.id, n.predecessor.address
 }
 
-// Notify notifies the node that it might have a new predecessor
-func (n *Node) Notify(suggestedPredecessorAddr string) {
+// OwnedRange returns the key-id interval (from, to] this node stores the keys of, from the predecessor's
+// id to its own. The interval covers the whole ring if from == to.
+func (n *Node) OwnedRange() (from int, to int) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.ownedRangeLocked()
+}
+
+// ownedRangeLocked returns OwnedRange, n.mu must be held. A node alone in the ring has no
+// predecessor and owns the whole ring, not just (0, id] from the id of the empty predecessor.
+func (n *Node) ownedRangeLocked() (from int, to int) {
+	if n.predecessor.address == "" && n.successor.address == n.address {
+		return n.id, n.id
+	}
+	return n.predecessor.id, n.id
+}
+
+// owns reports whether the key id is in the range this node owns
+func (n *Node) owns(keyId int) bool {
+	from, to := n.OwnedRange()
+	return InIntervalRightInclusive(keyId, from, to)
+}
+
+// Epoch returns the topology epoch of the node, incremented on every successor/predecessor/finger change
+func (n *Node) Epoch() uint64 {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.epoch
+}
 
-	_, currentPredecessorAddr := n.Predecessor()
+// Notify notifies the node that it might have a new predecessor, SetPredecessor accepts it if it
+// is closer than the current one
+func (n *Node) Notify(suggestedPredecessorAddr string) {
 
 	if suggestedPredecessorAddr == "" || suggestedPredecessorAddr == n.Address() {
 		return
 	}
 
-	suggestedPredecessorId := KeyToRingId(suggestedPredecessorAddr, ID_SPACE_SIZE)
-
-	// Accept if predecessor is empty OR in (predecessor, self]
-	if currentPredecessorAddr == "" || InIntervalRightInclusive(suggestedPredecessorId, n.predecessor.id, n.id) {
-		log.Printf("Notify: accepted suggested predecessor '%s' (id: '%d')", suggestedPredecessorAddr, suggestedPredecessorId)
-		n.SetPredecessor(suggestedPredecessorAddr)
-	}
+	n.SetPredecessor(suggestedPredecessorAddr, false)
 }
 
-// SetPredecessor updates the predecessor if the suggested node is closer.
-func (n *Node) SetPredecessor(predecessorAddr string) {
+// SetPredecessor updates the predecessor if the suggested node is closer, see closerPredecessor.
+// A leave forces the update, the leaving node hands its successor its own predecessor, which is
+// further away than the leaving node itself. An empty address clears the predecessor.
+func (n *Node) SetPredecessor(predecessorAddr string, force bool) {
 
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
 	if predecessorAddr == "" {
+		if n.predecessor.address != "" {
+			n.epoch++
+			n.publish(EventPredecessor, "", n.predecessor.address, "")
+		}
 		n.predecessor = node{}
-		log.Printf("SetPredecessor to empty")
+		n.logger.Info("SetPredecessor", "predecessor set to empty")
 		return
 	}
 
-	potentialPredecessorId := KeyToRingId(predecessorAddr, ID_SPACE_SIZE)
+	potentialPredecessorId := n.ringId(predecessorAddr)
 
 	// Accept if predecessor is empty OR not the same as the node
-	if n.predecessor.address == "" || potentialPredecessorId != n.id {
+	// Ids can collide for distinct addresses, so a colliding id is only self if the address matches
+	isSelf := potentialPredecessorId == n.id && predecessorAddr == n.address
+	if n.predecessor.address != "" && isSelf {
+		return
+	}
 
-		n.predecessor = node{
-			id:      potentialPredecessorId,
-			address: predecessorAddr,
+	// Only a closer node replaces a known predecessor unless forced. The closer check and the update
+	// are done under the lock, so of concurrent notifies the closest one is installed last.
+	if !force && n.predecessor.address != "" && !n.closerPredecessor(potentialPredecessorId, predecessorAddr) {
+		if predecessorAddr == n.predecessor.address {
+			return
 		}
-		log.Printf("SetPredecessor to '%s' (id: '%d')", n.predecessor.address, n.predecessor.id)
+		n.logger.Info("SetPredecessor", "rejected predecessor, not closer than the current one", "suggested", predecessorAddr, "suggested_id", potentialPredecessorId, "predecessor", n.predecessor.address, "predecessor_id", n.predecessor.id)
+		return
+	}
+
+	changed := n.predecessor.address != predecessorAddr
+	if changed {
+		n.epoch++
+		n.publish(EventPredecessor, predecessorAddr, n.predecessor.address, "")
+	}
+	n.predecessor = node{
+		id:      potentialPredecessorId,
+		address: predecessorAddr,
+	}
+	n.logger.Info("SetPredecessor", "predecessor updated", "predecessor", n.predecessor.address, "predecessor_id", n.predecessor.id, "forced", force)
+
+	// Our range changed, pull the keys we now own from the successor
+	if changed && n.transport != nil {
+		go n.acquireKeys()
+	}
+}
+
+// closerPredecessor reports whether the node is closer than the current predecessor, i.e. in
+// (predecessor, self]. An id colliding with this node's own is as close as a node can be, nothing
+// is closer than a predecessor with a colliding id unless it is the node itself, alone in the ring.
+// Callers hold n.mu.
+func (n *Node) closerPredecessor(id int, address string) bool {
+	switch {
+	case address == n.predecessor.address:
+		return false
+	case n.predecessor.address == n.address:
+		return true
+	case n.predecessor.id == n.id:
+		return false
 	}
+	return InIntervalRightInclusive(id, n.predecessor.id, n.id)
+}
+
+// SetLinks sets the successor and the predecessor together under the node's lock, without the
+// ownership checks of SetPredecessor and without pulling the keys of a changed range. Lets a test
+// harness wedge the node into a given topology for maintenance to recover from. An empty
+// predecessor clears it.
+func (n *Node) SetLinks(successorAddr string, predecessorAddr string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if previous := n.successor.address; previous != successorAddr {
+		n.successor = node{
+			id:      n.ringId(successorAddr),
+			address: successorAddr,
+		}
+		n.epoch++
+		n.persistSuccessor(successorAddr)
+		n.publish(EventSuccessor, successorAddr, previous, "")
+	}
+
+	if previous := n.predecessor.address; previous != predecessorAddr {
+		n.predecessor = node{}
+		if predecessorAddr != "" {
+			n.predecessor = node{
+				id:      n.ringId(predecessorAddr),
+				address: predecessorAddr,
+			}
+		}
+		n.epoch++
+		n.publish(EventPredecessor, predecessorAddr, previous, "")
+	}
+
+	n.logger.Warn("SetLinks", "links forced", "successor", n.successor.address, "predecessor", n.predecessor.address)
 }
 
 // SetSuccessor sets the successor of the node
@@ -366,27 +803,102 @@ func (n *Node) SetSuccessor(successorAddr string) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
+	unchanged := n.successor.address == successorAddr
+	previous := n.successor.address
 	n.successor = node{
-		id:      KeyToRingId(successorAddr, ID_SPACE_SIZE),
+		id:      n.ringId(successorAddr),
 		address: successorAddr,
 	}
-	log.Printf("SetSuccessor to '%s' (id: '%d')", n.successor.address, n.successor.id)
+	if !unchanged {
+		n.epoch++
+		n.persistSuccessor(successorAddr)
+		n.publish(EventSuccessor, successorAddr, previous, "")
+	}
+
+	if unchanged && n.quietMaintenance {
+		return
+	}
+	n.logger.Info("SetSuccessor", "successor updated", "successor", n.successor.address, "successor_id", n.successor.id)
+}
+
+// Put puts a key-value pair into the ring, expiring after ttl if ttl is positive
+// The owner returns the version of the write, one more than the version of the value it replaced.
+// Returns ErrKeyMoving if the key is owned here but currently being moved, the write should be retried,
+// and ErrStorageFull if the node rejects writes when full and the write exceeds its key or byte capacity.
+func (n *Node) Put(key string, value []byte, ttl time.Duration) (version uint64, nextNodeAddress string, err error) {
+	return n.put(key, value, ttl, nil)
+}
+
+// CompareAndPut puts the pair like Put only if the value the owner stores for the key is expected.
+// The compare and the store are done under the lock serializing the owner's writes, so of
+// concurrent swaps from the same value exactly one succeeds. Returns ErrPreconditionFailed if
+// the key is not stored or holds another value.
+func (n *Node) CompareAndPut(key string, expected []byte, value []byte, ttl time.Duration) (version uint64, nextNodeAddress string, err error) {
+	return n.put(key, value, ttl, func(current Versioned, exists bool) bool {
+		return exists && bytes.Equal(current.Value, expected)
+	})
+}
+
+// CompareVersionAndPut puts the pair like CompareAndPut, the owner's value must be at the expected version
+func (n *Node) CompareVersionAndPut(key string, expected uint64, value []byte, ttl time.Duration) (version uint64, nextNodeAddress string, err error) {
+	return n.put(key, value, ttl, func(current Versioned, exists bool) bool {
+		return exists && current.Version == expected
+	})
 }
 
-// Put puts a key-value pair into the ring
-func (n *Node) Put(key string, value string) (nextNodeAddress string) {
+// put implements Put and the conditional puts, the write is only stored if match is nil or
+// accepts the current value of the key
+func (n *Node) put(key string, value []byte, ttl time.Duration, match func(current Versioned, exists bool) bool) (version uint64, nextNodeAddress string, err error) {
 
 	// Hash the input key
-	keyId := KeyToRingId(key, ID_SPACE_SIZE)
+	keyId := n.ringId(key)
 
 	// Each key is stored in the successor of key
 	// Successor of k = the first node whose ID is greater than or equal to k
-	if InIntervalRightInclusive(keyId, n.predecessor.id, n.id) {
+	if n.owns(keyId) {
+
+		// Reject the write while the key is read-only during a move
+		n.movingMu.Lock()
+		if n.moving[key] {
+			n.movingMu.Unlock()
+			return 0, "", ErrKeyMoving
+		}
+
+		// No other write of the key can land between the compare and the store below
+		current, exists := n.loadVersioned(&n.data, key)
+		if match != nil && !match(current, exists) {
+			n.movingMu.Unlock()
+			n.logger.Info("Put", "precondition failed, current value differs", "key", key, "exists", exists, "version", current.Version)
+			return 0, "", ErrPreconditionFailed
+		}
+
+		// A new or expired key starts at version 1
+		version = current.Version + 1
+		stored := n.newStoredValue(value, version, n.clampTTL(ttl))
+
+		// At capacity only updates of stored keys are accepted, and no write may grow the values past
+		// maxBytes. Owner writes are serialized by movingMu, the bytes are reserved against the other writers.
+		reserved, err := n.checkCapacity(key, exists, stored)
+		if err != nil {
+			n.movingMu.Unlock()
+			n.logger.Warn("Put", "storage full, rejecting write", "key", key, "err", err)
+			return 0, "", err
+		}
+
 		// Thread-safe store using sync.Map
-		n.data.Store(key, value)
+		n.dataMu.RLock()
+		n.storeReserved(key, stored, reserved)
+		n.dataMu.RUnlock()
+		n.movingMu.Unlock()
 
-		log.Printf("Node '%d' stored key '%s' (id: '%d') and value length '%d'", n.id, key, keyId, len(value))
-		return ""
+		// Only writes that were stored count, a refused one did not touch the key
+		n.access.record(key, true)
+
+		n.logger.Info("Put", "stored key", "key", key, "key_id", keyId, "value_length", len(value), "version", version)
+
+		// Write through to the replicas outside the lock
+		n.replicate(key, Versioned{Value: value, Version: version, ExpiresAt: stored.expiresAt})
+		return version, "", nil
 	}
 
 	// Lookup the finger table and return the closest preceeding node address
@@ -394,37 +906,235 @@ func (n *Node) Put(key string, value string) (nextNodeAddress string) {
 	//log.Printf("Put(): Key '%s' (id: %d) not found, check address '%s' (id: %d)", key, keyId, closestPreceedingAddr, closestPreceedingId)
 
 	// Lookup the finger table and return the closest preceeding node address
+	return 0, closestPreceedingAddr, nil
+}
+
+// NextHop returns the address a storage request for the key is forwarded to, empty if this node owns it.
+// Lets a request be routed before its value is read, e.g. to stream a large PUT through the node.
+func (n *Node) NextHop(key string) string {
+	keyId := n.ringId(key)
+	if n.owns(keyId) {
+		return ""
+	}
+	_, closestPreceedingAddr := n.closestPrecedingNode(keyId)
 	return closestPreceedingAddr
 }
 
-// Get gets a value from the ring
-func (n *Node) Get(key string) (value string, nextAddress string, err error) {
+// Get gets a value from the ring with its version
+// The owner of the key returns the value or ErrKeyNotFound, any other node returns the address to forward to.
+func (n *Node) Get(key string) (value Versioned, nextAddress string, err error) {
 
 	// Hash the input key
-	keyId := KeyToRingId(key, ID_SPACE_SIZE)
+	keyId := n.ringId(key)
 
 	// Check if the key is in the interval from the preceeding to self
 	// If the key id == node id, this node takes ownership
-	if InIntervalRightInclusive(keyId, n.predecessor.id, n.id) {
+	if n.owns(keyId) {
+		n.access.record(key, false)
+
+		// Thread-safe load, expired keys are absent
+		if value, exists := n.loadVersioned(&n.data, key); exists {
+			n.logger.Info("Get", "retrieved key", "key", key, "key_id", keyId, "value_length", len(value.Value), "version", value.Version)
+			n.repairReplicas(key, value)
+			return value, "", nil
+		}
 
-		// Thread-safe load
-		if value, exists := n.data.Load(key); exists {
-			if strValue, ok := value.(string); ok {
-				log.Printf("Node '%d' retrieved key '%s' (id: '%d') and value length '%d'", n.id, key, keyId, len(strValue))
-				return strValue, "", nil
-			}
+		// Missing on the owner, e.g. it took over the keys of a crashed predecessor
+		if value, repaired := n.readRepair(key); repaired {
+			n.repairReplicas(key, value)
+			return value, "", nil
 		}
-		return "", "", fmt.Errorf("key not found")
+		return Versioned{}, "", ErrKeyNotFound
+	}
+
+	// The predecessor of the owner may serve the read from a replica, see WithReadStrategy
+	if value, ok := n.readReplica(key, keyId); ok {
+		return value, "", nil
 	}
 	_, closestPreceedingAddr := n.closestPrecedingNode(keyId)
 	//log.Printf("Get(): Key '%s' (id: %d) not found, check address '%s' (id: %d)", key, keyId, closestPreceedingAddr, closestPreceedingId)
 
 	// Lookup the finger table and return the closest preceeding node address
-	return "", closestPreceedingAddr, nil
+	return Versioned{}, closestPreceedingAddr, nil
+}
+
+// Delete deletes a key from the ring
+// The owner of the key returns ErrKeyNotFound if it does not store it, any other node returns the address to forward to.
+func (n *Node) Delete(key string) (nextAddress string, err error) {
+
+	// Hash the input key
+	keyId := n.ringId(key)
+
+	// Same ownership check as Put
+	if n.owns(keyId) {
+
+		// Reject the write while the key is read-only during a move
+		n.movingMu.Lock()
+		if n.moving[key] {
+			n.movingMu.Unlock()
+			return "", ErrKeyMoving
+		}
+
+		// Thread-safe delete
+		n.dataMu.RLock()
+		value, exists := n.data.LoadAndDelete(key)
+		if exists {
+			n.dataKeys.Add(-1)
+			n.dataBytes.Add(-storedLen(value))
+			n.persistDelete(key)
+		}
+		n.dataMu.RUnlock()
+		n.movingMu.Unlock()
+
+		// Delete the replicas too so a read doesn't repair the key, the owner may only have had it as a replica
+		_, replicated := n.Replica(key)
+		n.replicas.Delete(key)
+		n.unreplicate(key)
+
+		if stored, ok := value.(*storedValue); (!exists || !ok || stored.expired(time.Now())) && !replicated {
+			return "", ErrKeyNotFound
+		}
+
+		n.logger.Info("Delete", "deleted key", "key", key, "key_id", keyId)
+		return "", nil
+	}
+
+	// Lookup the finger table and return the closest preceeding node address
+	_, closestPreceedingAddr := n.closestPrecedingNode(keyId)
+	return closestPreceedingAddr, nil
 }
 
-// FindSuccessor finds the successor of the input
-func (n *Node) FindSuccessor(keyId int) (string, error) {
+// Dump returns a copy of the locally stored key-value pairs.
+// Ranging the sync.Map under concurrent writes gives a weakly consistent view where a key
+// written during the dump may or may not appear. With consistent set, the copy is a Snapshot.
+func (n *Node) Dump(consistent bool) map[string][]byte {
+	if consistent {
+		return n.Snapshot()
+	}
+
+	out := make(map[string][]byte)
+	n.RangeKeys(func(key string, value []byte) bool {
+		out[key] = value
+		return true
+	})
+	return out
+}
+
+// Snapshot returns a copy of the locally stored key-value pairs at a single point in time.
+// Writes to the data map are blocked while it is copied, the values are copied too so the
+// caller may keep and modify them.
+func (n *Node) Snapshot() map[string][]byte {
+	data := n.snapshotVersioned()
+	out := make(map[string][]byte, len(data))
+	for key, v := range data {
+		out[key] = v.Value
+	}
+	return out
+}
+
+// snapshotVersioned is Snapshot with the versions and expiry of the values, as handed off by Leave
+func (n *Node) snapshotVersioned() map[string]Versioned {
+	n.dataMu.Lock()
+	defer n.dataMu.Unlock()
+
+	out := make(map[string]Versioned)
+	n.rangeVersioned(&n.data, func(key string, v Versioned) bool {
+		v.Value = bytes.Clone(v.Value)
+		out[key] = v
+		return true
+	})
+	return out
+}
+
+// KeyCount returns the number of keys stored locally on this node
+func (n *Node) KeyCount() int {
+	count := 0
+	n.RangeKeys(func(key string, value []byte) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// DataSize returns the number of keys stored locally on this node and the total length of their values
+func (n *Node) DataSize() (keys int, bytes int) {
+	n.RangeKeys(func(key string, value []byte) bool {
+		keys++
+		bytes += len(value)
+		return true
+	})
+	return keys, bytes
+}
+
+// OwnedFraction returns the share of the identifier space this node owns, the gap from its
+// predecessor's id to its own. known is false while the predecessor is unknown.
+func (n *Node) OwnedFraction() (fraction float64, known bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if n.predecessor.address == "" && n.successor.address != n.address {
+		return 0, false
+	}
+	from, to := n.ownedRangeLocked()
+	gap := ClockwiseDistance(from, to, n.idSpaceSize)
+	if gap == 0 {
+		gap = n.idSpaceSize // the only node owns the whole ring
+	}
+	return float64(gap) / float64(n.idSpaceSize), true
+}
+
+// LocalKeys returns the keys stored locally on this node, sorted
+func (n *Node) LocalKeys() []string {
+	keys := []string{}
+	n.RangeKeys(func(key string, value []byte) bool {
+		keys = append(keys, key)
+		return true
+	})
+	sort.Strings(keys)
+	return keys
+}
+
+// PrefixScan returns the locally stored key-value pairs whose key starts with the prefix.
+// With a positive limit only the limit first keys in sorted order are returned, so merging the
+// scans of every node and truncating again yields the first keys of the whole ring.
+func (n *Node) PrefixScan(prefix string, limit int) map[string][]byte {
+	matches := make(map[string][]byte)
+	n.RangeKeys(func(key string, value []byte) bool {
+		if strings.HasPrefix(key, prefix) {
+			matches[key] = value
+		}
+		return true
+	})
+	return TruncateSorted(matches, limit)
+}
+
+// FindSuccessor finds the successor of the input, the remote hops are cancelled with ctx
+func (n *Node) FindSuccessor(ctx context.Context, keyId int) (string, error) {
+	return n.findSuccessor(ctx, keyId, n.preferLocal)
+}
+
+// findSuccessor finds the successor of the input, answering from the local tables first if preferLocal
+func (n *Node) findSuccessor(ctx context.Context, keyId int, preferLocal bool) (string, error) {
+
+	start := time.Now()
+	defer func() {
+		metrics.FindSuccessorSeconds.Observe(time.Since(start).Seconds())
+	}()
+
+	// Answer from the finger table or successor list if they cover the key, saving the remote hops
+	if preferLocal {
+		if successorAddr, ok := n.localSuccessor(keyId); ok {
+			return successorAddr, nil
+		}
+	}
+
+	if n.lookupMode == LookupIterative {
+		successorAddr, err := n.findSuccessorIterative(keyId)
+		if err == nil {
+			return successorAddr, nil
+		}
+		// Fall back to the recursive lookup, it routes around failed nodes
+		n.logger.Warn("FindSuccessor", "iterative lookup failed, falling back to recursive", "key_id", keyId, "err", err)
+	}
 
 	ownSuccessorId, ownSuccessorAddr := n.Successor()
 
@@ -436,40 +1146,74 @@ func (n *Node) FindSuccessor(keyId int) (string, error) {
 
 	// Search the finger table for the closest preceeding nodes
 	candidates := n.closestPrecedingNodes(keyId)
+	failed := make(map[string]bool)
 
 	// We now query these candidates if they have the successor of the keyId
 	for _, candidate := range candidates {
 
-		successorAddr, err := n.transport.FindSuccessor(candidate, keyId)
+		successorAddr, err := n.retryRPC(ctx, func() (string, error) {
+			return n.transport.FindSuccessor(ctx, candidate, keyId)
+		})
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
 
 		if err != nil {
-			log.Printf("FindSuccessor WARNING, failed to contact '%s' trying next candidate in list: %v, %v", candidate, candidates, err)
+			metrics.FailedRPCs.WithLabelValues("FindSuccessor").Inc()
+			n.logger.Warn("FindSuccessor", "failed to contact candidate, trying next candidate in list", "candidate", candidate, "candidates", candidates, "err", err)
+			failed[candidate] = true
 			continue
 		}
 		return successorAddr, nil
 	}
 
-	log.Printf("FindSuccessor: all closest preceding candidates failed (candidates: %v), falling back to own successor '%s'", candidates, ownSuccessorAddr)
-	return ownSuccessorAddr, nil
+	// Fall through to the first successor that did not fail rather than a known dead one
+	fallbackAddr := ownSuccessorAddr
+	for _, addr := range append([]string{ownSuccessorAddr}, n.SuccessorList()...) {
+		if !failed[addr] {
+			fallbackAddr = addr
+			break
+		}
+	}
+
+	n.logger.Warn("FindSuccessor", "all closest preceding candidates failed, falling back to successor", "candidates", candidates, "successor", fallbackAddr)
+	return fallbackAddr, nil
 
 }
 
-// Return the a list of nodes that are in the interval (n.id, keyId)
+// Return the a list of nodes that are in the interval (n.id, keyId), deduplicated and closest to the key first
 func (n *Node) closestPrecedingNodes(keyId int) (candidates []string) {
 	n.mu.RLock()
 	defer n.mu.RUnlock()
 
 	// Deduplicate the list of candidates
 	seen := make(map[string]bool)
+	var nodes []node
 
 	for i := len(n.finger) - 1; i >= 0; i-- {
-		fingerId := n.finger[i].node.id
-		fingerAddr := n.finger[i].node.address
-		if InIntervalOpen(fingerId, n.id, keyId) && !seen[fingerAddr] {
-			candidates = append(candidates, fingerAddr)
-			seen[fingerAddr] = true
+		f := n.finger[i].node
+		if InIntervalOpen(f.id, n.id, keyId) && !seen[f.address] {
+			nodes = append(nodes, f)
+			seen[f.address] = true
+		}
+	}
+
+	// Backup successors preceding the key
+	for i := len(n.successorList) - 1; i >= 0; i-- {
+		s := n.successorList[i]
+		if InIntervalOpen(s.id, n.id, keyId) && !seen[s.address] {
+			nodes = append(nodes, s)
+			seen[s.address] = true
 		}
 	}
+
+	// Closest to the key first, a backup successor can be closer than the fingers
+	slices.SortStableFunc(nodes, func(a, b node) int {
+		return ClockwiseDistance(a.id, keyId, n.idSpaceSize) - ClockwiseDistance(b.id, keyId, n.idSpaceSize)
+	})
+	for _, c := range nodes {
+		candidates = append(candidates, c.address)
+	}
 	return candidates
 }
 
@@ -487,6 +1231,14 @@ func (n *Node) closestSuccessorNodes() (candidates []string) {
 		seen[n.successor.address] = true
 	}
 
+	// Then the backup successors in ring order
+	for _, s := range n.successorList {
+		if s.address != n.address && !seen[s.address] {
+			candidates = append(candidates, s.address)
+			seen[s.address] = true
+		}
+	}
+
 	for _, finger := range n.finger {
 		addr := finger.node.address
 		if addr != n.address && !seen[addr] {
@@ -498,16 +1250,37 @@ func (n *Node) closestSuccessorNodes() (candidates []string) {
 	return candidates
 }
 
+// closestPrecedingNode returns the finger closest to the key in (n, key), the next hop towards
+// its owner. The successor is returned if it owns the key or if no finger precedes the key.
 func (n *Node) closestPrecedingNode(keyId int) (id int, address string) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.closestPrecedingNodeLocked(keyId)
+}
+
+// closestPrecedingNodeLocked is closestPrecedingNode with n.mu held
+func (n *Node) closestPrecedingNodeLocked(keyId int) (id int, address string) {
 
-	// Iterate over the finger table and return the closest preceeding node address
+	// The successor owns keys in (n, successor], no finger can be closer
+	if InIntervalRightInclusive(keyId, n.id, n.successor.id) {
+		return n.successor.id, n.successor.address
+	}
+
+	// Iterate over the finger table and return the closest preceeding node address. In a sparse
+	// ring runs of fingers point at the same node, only the first entry of a run is checked, so
+	// the walk does one interval check per distinct node.
+	previous := ""
 	for i := len(n.finger) - 1; i >= 0; i-- {
 
-		fingerId := n.finger[i].node.id
+		f := n.finger[i].node
+		if f.address == previous {
+			continue
+		}
+		previous = f.address
 
-		// Open interval to fulfull the strict closest "preceeding"
-		if InIntervalOpen(fingerId, n.id, keyId) {
-			return fingerId, n.finger[i].node.address
+		// Open interval to fulfull the strict closest "preceeding", a finger at self never qualifies
+		if InIntervalOpen(f.id, n.id, keyId) {
+			return f.id, f.address
 		}
 	}
 
@@ -529,13 +1302,76 @@ func (n *Node) String() string {
 }
 
 func (n *Node) FingerTable() []string {
-	addresses := make([]string, M)
+	addresses := make([]string, n.m)
 	for i, f := range n.finger {
 		addresses[i] = f.node.address
 	}
 	return addresses
 }
 
+// SweepFinger pings the distinct finger node at the index in the background, round-robin over the
+// distinct fingers other than self, and removes it from every slot if it is dead. Returns the next index.
+// Heals fingers pointing at crashed nodes without waiting for FixFinger to reach their slots.
+func (n *Node) SweepFinger(ctx context.Context, index int) int {
+
+	self := n.Address()
+	var distinct []string
+	seen := map[string]bool{self: true, "": true}
+	for _, addr := range n.FingerTable() {
+		if !seen[addr] {
+			distinct = append(distinct, addr)
+			seen[addr] = true
+		}
+	}
+	if len(distinct) == 0 {
+		return 0
+	}
+
+	addr := distinct[index%len(distinct)]
+	go func() {
+		_, err := n.retryRPC(ctx, func() (string, error) {
+			alive, err := n.transport.CheckAliveExpecting(ctx, addr, n.ringId(addr))
+			if err == nil && !alive {
+				err = fmt.Errorf("node is not alive")
+			}
+			return "", err
+		})
+		if err != nil && ctx.Err() == nil {
+			metrics.FailedRPCs.WithLabelValues("CheckAlive").Inc()
+			n.logger.Warn("SweepFinger", "finger node is dead, removing it from the finger table", "finger", addr, "err", err)
+			n.removeFailedFinger(addr)
+		}
+	}()
+
+	return (index + 1) % len(distinct)
+}
+
+// closestLiveKnownNode returns the node of the successor list and the finger table that follows
+// this node most closely on the ring and answers a ping, empty if none does. Fingers that don't
+// answer are removed. Stabilize then walks from it to the actual successor.
+func (n *Node) closestLiveKnownNode(ctx context.Context) string {
+
+	selfId := n.Id()
+	candidates := n.closestSuccessorNodes()
+	distance := func(addr string) int {
+		return (n.ringId(addr) - selfId + n.idSpaceSize) % n.idSpaceSize
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return distance(candidates[i]) < distance(candidates[j]) })
+
+	for _, candidate := range candidates {
+		alive, err := n.transport.CheckAliveExpecting(ctx, candidate, n.ringId(candidate))
+		if ctx.Err() != nil {
+			return ""
+		}
+		if err == nil && alive {
+			return candidate
+		}
+		n.maintenanceInfo("Stabilize", "known node did not answer", "candidate", candidate, "err", err)
+		n.removeFailedFinger(candidate)
+	}
+	return ""
+}
+
 // HELPER
 
 // Helper function to remove a failed node from the finger table and replace with the subsequent node
@@ -561,14 +1397,33 @@ func (n *Node) removeFailedFinger(failedAddr string) {
 	defer n.mu.Unlock()
 
 	// Remove dead node from finger table and replace with live node
+	changed := false
 	for i, entry := range n.finger {
-		if entry.node.address == failedAddr {
+		if entry.node.address == failedAddr && failedAddr != nextSuccessorAddr {
 			n.finger[i].node = node{
-				id:      KeyToRingId(nextSuccessorAddr, ID_SPACE_SIZE),
+				id:      n.ringId(nextSuccessorAddr),
 				address: nextSuccessorAddr,
 			}
+			changed = true
 		}
 	}
+	if changed {
+		n.epoch++
+		n.publish(EventNodeFailed, failedAddr, "", "finger")
+	}
+}
+
+// ringId hashes the key into the node's identifier space
+func (n *Node) ringId(key string) int {
+	return HashToRingId(n.hasher, key, n.idSpaceSize)
+}
+
+// maintenanceInfo logs routine maintenance messages unless quiet maintenance is enabled
+func (n *Node) maintenanceInfo(op string, msg string, kv ...any) {
+	if n.quietMaintenance {
+		return
+	}
+	n.logger.Info(op, msg, kv...)
 }
 
 func (n *Node) resetToStartingState() {
@@ -581,26 +1436,84 @@ func (n *Node) resetToStartingState() {
 	}
 
 	// Set successor to self, predecessor to empty
+	if n.successor.address != self.address {
+		n.publish(EventSuccessor, self.address, n.successor.address, "")
+	}
+	if n.predecessor.address != "" {
+		n.publish(EventPredecessor, "", n.predecessor.address, "")
+	}
 	n.successor = self
 	n.predecessor = node{}
+	n.successorList = nil
 
 	// Reset all finger table entries to self
-	for i := 0; i < M; i++ {
+	for i := 0; i < n.m; i++ {
 		n.finger[i].node = self
 	}
+	n.epoch++
 
-	log.Printf("ResetToStartingState: node is now %s", n.String())
-	log.Println("====================== RESET ===========================")
-	log.Println("")
+	n.logger.Info("ResetToStartingState", "node reset", "node", n.String())
 }
 
-// TODO: wrap FindSuccessor, GetPredecessor, Notify, in this function
+// retry runs the operation up to maxRetries times with jittered exponential backoff between
+// attempts, each wait capped at maxRetryBackoff, see retryContext
 func retry(operation func() (string, error), maxRetries int) (string, error) {
+	return retryContext(context.Background(), operation, maxRetries, maxRetryBackoff)
+}
+
+// retryContext runs the operation up to maxRetries times with exponential backoff between
+// attempts, each wait capped at maxBackoff and jittered so nodes retrying the same failed peer
+// don't do so in lockstep. A cancelled ctx aborts the wait and stops retrying.
+func retryContext(ctx context.Context, operation func() (string, error), maxRetries int, maxBackoff time.Duration) (string, error) {
+	return retryBudget(ctx, operation, maxRetries, maxBackoff, 0)
+}
+
+// retryBudget is retryContext with the waits of all attempts together bounded by budget, once it
+// is spent no further attempt is made. A budget of 0 leaves the total unbounded.
+func retryBudget(ctx context.Context, operation func() (string, error), maxRetries int, maxBackoff time.Duration, budget time.Duration) (string, error) {
+	var err error
+	var result string
+	var slept time.Duration
 	for i := 0; i < maxRetries; i++ {
-		if result, err := operation(); err == nil {
+		if result, err = operation(); err == nil {
 			return result, nil
 		}
-		time.Sleep(time.Duration(i*i) * 50 * time.Millisecond) // quadratic backoff
+		if i == maxRetries-1 {
+			break
+		}
+		backoff := maxBackoff
+		if i < 32 {
+			backoff = min(retryBackoff<<i, maxBackoff)
+		}
+		backoff = backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		if budget > 0 {
+			if slept >= budget {
+				return "", fmt.Errorf("operation failed after %d attempts, retry budget %s spent: %w", i+1, budget, err)
+			}
+			backoff = min(backoff, budget-slept)
+		}
+		if !sleepContext(ctx, backoff) {
+			return "", fmt.Errorf("operation aborted after %d attempts: %w", i+1, ctx.Err())
+		}
+		slept += backoff
+	}
+	return "", fmt.Errorf("operation failed after %d retries: %w", maxRetries, err)
+}
+
+// retryRPC runs a transport call of the node with its maxRetries. Each backoff and all of them
+// together are capped at half the maintenance interval so a stuck call can't starve the loop.
+func (n *Node) retryRPC(ctx context.Context, operation func() (string, error)) (string, error) {
+	return retryBudget(ctx, operation, n.maxRetries, n.tickInterval/2, n.tickInterval/2)
+}
+
+// sleepContext sleeps for the duration, returns false if ctx is done first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
 	}
-	return "", fmt.Errorf("operation failed after %d retries", maxRetries)
 }
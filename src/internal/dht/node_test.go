@@ -0,0 +1,507 @@
+package dht
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentCompareAndPut(t *testing.T) {
+	tests := []struct {
+		name string
+		cas  func(n *Node, value []byte) error
+	}{
+		{"value", func(n *Node, value []byte) error {
+			_, _, err := n.CompareAndPut("key", []byte("initial"), value, 0)
+			return err
+		}},
+		{"version", func(n *Node, value []byte) error {
+			_, _, err := n.CompareVersionAndPut("key", 1, value, 0)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := Create("node-0", WithLogger(quietLogger()))
+			if _, _, err := node.Put("key", []byte("initial"), 0); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+
+			const attempts = 2
+			errs := make([]error, attempts)
+			var start, done sync.WaitGroup
+			start.Add(1)
+			for i := range attempts {
+				done.Add(1)
+				go func() {
+					defer done.Done()
+					start.Wait()
+					errs[i] = tt.cas(node, []byte(fmt.Sprintf("swap-%d", i)))
+				}()
+			}
+			start.Done()
+			done.Wait()
+
+			succeeded := 0
+			for i, err := range errs {
+				switch {
+				case err == nil:
+					succeeded++
+				case !errors.Is(err, ErrPreconditionFailed):
+					t.Errorf("attempt %d: %v, want nil or ErrPreconditionFailed", i, err)
+				}
+			}
+			if succeeded != 1 {
+				t.Fatalf("%d of %d concurrent swaps succeeded, want exactly 1", succeeded, attempts)
+			}
+
+			v, _ := node.loadVersioned(&node.data, "key")
+			if v.Version != 2 {
+				t.Errorf("version after the swap = %d, want 2", v.Version)
+			}
+		})
+	}
+}
+
+func TestCompareVersionAndPutAfterJoin(t *testing.T) {
+	net := NewMemoryNetwork()
+	nodes := net.BuildRing(2)
+	ctx := context.Background()
+
+	joining := Create("node-joining", WithLogger(quietLogger()))
+
+	// A key that moves to the joining node, written twice so a client may hold the ETag of version 1
+	ring := append(slices.Clone(nodes), joining)
+	slices.SortFunc(ring, func(a, b *Node) int { return a.Id() - b.Id() })
+	var key string
+	for i := 0; key == ""; i++ {
+		if candidate := fmt.Sprintf("key-%d", i); ownerOf(ring, joining.ringId(candidate)) == joining.Address() {
+			key = candidate
+		}
+	}
+	ringPut(t, nodes, key, []byte("first"), 0)
+	ringPut(t, nodes, key, []byte("second"), 0)
+
+	net.Add(joining)
+	successorAddr, err := nodes[0].FindSuccessor(ctx, joining.Id())
+	if err != nil {
+		t.Fatalf("FindSuccessor: %v", err)
+	}
+	if err := joining.Join(successorAddr); err != nil {
+		t.Fatalf("Join: %v", err)
+	}
+	if !joining.owns(joining.ringId(key)) {
+		t.Fatalf("key %q did not move to the joining node", key)
+	}
+
+	// The stale ETag must not match the handed off value
+	if _, _, err := joining.CompareVersionAndPut(key, 1, []byte("stale"), 0); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("swap at the stale version 1: %v, want ErrPreconditionFailed", err)
+	}
+	version, _, err := joining.CompareVersionAndPut(key, 2, []byte("third"), 0)
+	if err != nil {
+		t.Fatalf("swap at the current version 2: %v", err)
+	}
+	if version != 3 {
+		t.Errorf("version after the swap = %d, want 3", version)
+	}
+}
+
+func TestRetrySucceedsAfterFailures(t *testing.T) {
+	attempts := 0
+	result, err := retry(func() (string, error) {
+		attempts++
+		if attempts < 3 {
+			return "", errors.New("unreachable")
+		}
+		return "ok", nil
+	}, 3)
+	if err != nil || result != "ok" {
+		t.Fatalf("retry = %q, %v, want \"ok\"", result, err)
+	}
+	if attempts != 3 {
+		t.Errorf("%d attempts, want 3", attempts)
+	}
+}
+
+func TestRetryContextCapsEveryBackoff(t *testing.T) {
+	const maxRetries, maxBackoff, slack = 6, 20 * time.Millisecond, 15 * time.Millisecond
+	unreachable := errors.New("unreachable")
+
+	// Uncapped, the waits would grow from 25ms to 400ms
+	var attempts []time.Time
+	_, err := retryContext(context.Background(), func() (string, error) {
+		attempts = append(attempts, time.Now())
+		return "", unreachable
+	}, maxRetries, maxBackoff)
+	if !errors.Is(err, unreachable) {
+		t.Errorf("error = %v, want the error of the operation", err)
+	}
+	if len(attempts) != maxRetries {
+		t.Fatalf("%d attempts, want %d", len(attempts), maxRetries)
+	}
+	for i := 1; i < len(attempts); i++ {
+		if wait := attempts[i].Sub(attempts[i-1]); wait > maxBackoff+slack {
+			t.Errorf("wait before attempt %d = %v, want at most the cap %v", i+1, wait, maxBackoff)
+		}
+	}
+}
+
+func TestRetryContextAbortsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	attempts := 0
+	start := time.Now()
+	_, err := retryContext(ctx, func() (string, error) {
+		attempts++
+		cancel()
+		return "", errors.New("unreachable")
+	}, 5, time.Hour)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("retry took %v after the context was cancelled", elapsed)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+	if attempts != 1 {
+		t.Errorf("%d attempts, want 1", attempts)
+	}
+}
+
+func TestRetryRPCBoundsTotalBackoff(t *testing.T) {
+	timing := Timing{
+		MaintenanceInterval: 60 * time.Millisecond,
+		FastTimeout:         20 * time.Millisecond,
+		SlowTimeout:         time.Second,
+		ForwardTimeout:      time.Second,
+	}
+	node := Create("node-0", WithLogger(quietLogger()), WithTiming(timing), WithMaxRetries(20))
+	const slack = 20 * time.Millisecond
+	unreachable := errors.New("unreachable")
+
+	// With a per-step cap alone, 19 waits of up to 30ms would add up to over half a second
+	attempts := 0
+	start := time.Now()
+	_, err := node.retryRPC(context.Background(), func() (string, error) {
+		attempts++
+		return "", unreachable
+	})
+	elapsed := time.Since(start)
+	if !errors.Is(err, unreachable) {
+		t.Errorf("error = %v, want the error of the operation", err)
+	}
+	if budget := timing.MaintenanceInterval / 2; elapsed > budget+slack {
+		t.Errorf("retryRPC took %v, want at most half the maintenance interval %v", elapsed, budget)
+	}
+	if attempts < 2 || attempts >= 20 {
+		t.Errorf("%d attempts, want a retry and fewer than the 20 the budget can't fit", attempts)
+	}
+}
+
+// peersByDistance returns count addresses other than the node's, ordered from the closest
+// predecessor of the node to the farthest, i.e. by decreasing clockwise distance from node
+func peersByDistance(node *Node, count int) []string {
+	var peers []string
+	for i := 0; len(peers) < count; i++ {
+		if peer := fmt.Sprintf("peer-%d", i); node.ringId(peer) != node.Id() {
+			peers = append(peers, peer)
+		}
+	}
+	slices.SortFunc(peers, func(a, b string) int {
+		return ClockwiseDistance(node.ringId(a), node.Id(), node.idSpaceSize) - ClockwiseDistance(node.ringId(b), node.Id(), node.idSpaceSize)
+	})
+	return peers
+}
+
+func TestNotifyAcceptsOnlyCloserPredecessor(t *testing.T) {
+	node := Create("node-0", WithLogger(quietLogger()))
+	peers := peersByDistance(node, 3)
+	closest, middle, farthest := peers[0], peers[1], peers[2]
+
+	node.Notify(middle)
+	if _, got := node.Predecessor(); got != middle {
+		t.Fatalf("predecessor after the first notify = %q, want %q", got, middle)
+	}
+	node.Notify(farthest)
+	if _, got := node.Predecessor(); got != middle {
+		t.Errorf("predecessor after a farther notify = %q, want %q kept", got, middle)
+	}
+	node.Notify(closest)
+	if _, got := node.Predecessor(); got != closest {
+		t.Errorf("predecessor after a closer notify = %q, want %q", got, closest)
+	}
+
+	// A leave hands over a farther predecessor and forces it
+	node.SetPredecessor(farthest, true)
+	if _, got := node.Predecessor(); got != farthest {
+		t.Errorf("predecessor after a forced update = %q, want %q", got, farthest)
+	}
+}
+
+func TestNotifyAcceptsCollidingId(t *testing.T) {
+	node := Create("node-0", WithLogger(quietLogger()), WithM(4))
+	colliding := ""
+	for i := 0; colliding == ""; i++ {
+		if peer := fmt.Sprintf("peer-%d", i); node.ringId(peer) == node.Id() {
+			colliding = peer
+		}
+	}
+	other := peersByDistance(node, 1)[0]
+
+	node.Notify(other)
+	node.Notify(colliding)
+	if _, got := node.Predecessor(); got != colliding {
+		t.Fatalf("predecessor = %q, want the colliding %q", got, colliding)
+	}
+	node.Notify(other)
+	if _, got := node.Predecessor(); got != colliding {
+		t.Errorf("predecessor = %q after a farther notify, want the colliding %q kept", got, colliding)
+	}
+}
+
+func TestConcurrentNotifiesInstallClosest(t *testing.T) {
+	for range 20 {
+		node := Create("node-0", WithLogger(quietLogger()))
+		peers := peersByDistance(node, 16)
+
+		var start, done sync.WaitGroup
+		start.Add(1)
+		for _, peer := range slices.Backward(peers) {
+			done.Add(1)
+			go func() {
+				defer done.Done()
+				start.Wait()
+				node.Notify(peer)
+			}()
+		}
+		start.Done()
+		done.Wait()
+
+		if _, got := node.Predecessor(); got != peers[0] {
+			t.Fatalf("predecessor after concurrent notifies = %q, want the closest %q", got, peers[0])
+		}
+	}
+}
+
+// flakyTransport fails the first failures GetPredecessor and FindSuccessor calls to every target
+// and passes the later ones to the wrapped transport
+type flakyTransport struct {
+	Transport
+	failures int
+
+	mu    sync.Mutex
+	calls map[string]int // calls per target
+}
+
+func newFlakyTransport(next Transport, failures int) *flakyTransport {
+	return &flakyTransport{Transport: next, failures: failures, calls: make(map[string]int)}
+}
+
+// call counts a call to the target and reports whether it fails
+func (t *flakyTransport) call(targetAddr string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.calls[targetAddr]++
+	if t.calls[targetAddr] <= t.failures {
+		return fmt.Errorf("TIMEOUT: call %d to %s", t.calls[targetAddr], targetAddr)
+	}
+	return nil
+}
+
+func (t *flakyTransport) GetPredecessor(ctx context.Context, targetAddr string) (string, error) {
+	if err := t.call(targetAddr); err != nil {
+		return "", err
+	}
+	return t.Transport.GetPredecessor(ctx, targetAddr)
+}
+
+func (t *flakyTransport) FindSuccessor(ctx context.Context, targetAddr string, keyId int) (string, error) {
+	if err := t.call(targetAddr); err != nil {
+		return "", err
+	}
+	return t.Transport.FindSuccessor(ctx, targetAddr, keyId)
+}
+
+func TestStabilizeRetriesFlakySuccessor(t *testing.T) {
+	nodes := BuildRing(5, WithMaxRetries(3))
+	node := nodes[0]
+	_, successorAddr := node.Successor()
+
+	flaky := newFlakyTransport(node.transport, 2)
+	node.SetTransport(flaky)
+	node.Stabilize(context.Background())
+
+	// Without the retries the successor would be given up for the next entry of the successor list
+	if _, got := node.Successor(); got != successorAddr {
+		t.Errorf("successor after stabilize = %q, want %q kept", got, successorAddr)
+	}
+	if calls := flaky.calls[successorAddr]; calls != 3 {
+		t.Errorf("%d GetPredecessor calls to the successor, want 3", calls)
+	}
+}
+
+func TestFindSuccessorRetriesFlakyCandidate(t *testing.T) {
+	nodes := BuildRing(20, WithMaxRetries(3))
+	node := nodes[0]
+	ctx := context.Background()
+
+	// A key halfway around the ring, so the lookup is sent on to a finger
+	keyId := (node.Id() + node.idSpaceSize/2) % node.idSpaceSize
+	want := ownerOf(nodes, keyId)
+
+	flaky := newFlakyTransport(node.transport, 2)
+	node.SetTransport(flaky)
+	got, err := node.FindSuccessor(ctx, keyId)
+	if err != nil {
+		t.Fatalf("FindSuccessor(%d): %v", keyId, err)
+	}
+	if got != want {
+		t.Errorf("FindSuccessor(%d) = %s, want %s", keyId, got, want)
+	}
+
+	// The first candidate answered on its third attempt, no other candidate was asked
+	if len(flaky.calls) != 1 {
+		t.Fatalf("FindSuccessor calls per candidate = %v, want a single candidate", flaky.calls)
+	}
+	for candidate, calls := range flaky.calls {
+		if calls != 3 {
+			t.Errorf("%d FindSuccessor calls to %s, want 3", calls, candidate)
+		}
+	}
+}
+
+func TestTwoSoloNodesConverge(t *testing.T) {
+	const maxRounds = 3
+
+	for _, stale := range []bool{false, true} {
+		t.Run(fmt.Sprintf("stale predecessor %v", stale), func(t *testing.T) {
+			net := NewMemoryNetwork()
+			first := Create("node-a", WithLogger(quietLogger()))
+			joining := Create("node-b", WithLogger(quietLogger()))
+			net.Add(first)
+			net.Add(joining)
+			ctx := context.Background()
+
+			successorAddr, err := first.FindSuccessor(ctx, joining.Id())
+			if err != nil {
+				t.Fatalf("FindSuccessor: %v", err)
+			}
+			if err := joining.Join(successorAddr); err != nil {
+				t.Fatalf("Join: %v", err)
+			}
+
+			// The first node loses the predecessor the join's notify gave it, e.g. to a failed check
+			if stale {
+				first.SetPredecessor("", false)
+			}
+
+			ring := []*Node{first, joining}
+			slices.SortFunc(ring, func(a, b *Node) int { return a.Id() - b.Id() })
+			for round := 1; !ringSettled(ring); round++ {
+				if round > maxRounds {
+					t.Fatalf("ring not settled after %d rounds", maxRounds)
+				}
+				for _, node := range ring {
+					node.Stabilize(ctx)
+				}
+			}
+
+			if _, successor := first.Successor(); successor != joining.Address() {
+				t.Errorf("successor of %s = %q, want %s", first.Address(), successor, joining.Address())
+			}
+			if _, successor := joining.Successor(); successor != first.Address() {
+				t.Errorf("successor of %s = %q, want %s", joining.Address(), successor, first.Address())
+			}
+		})
+	}
+}
+
+func TestStabilizeFindsSuccessorThroughKnownNodes(t *testing.T) {
+	for _, size := range []int{2, 8} {
+		t.Run(fmt.Sprintf("%d nodes", size), func(t *testing.T) {
+			nodes := BuildRing(size)
+			node := nodes[0]
+
+			// Successor is self and no predecessor, only the fingers and successor list know the ring
+			node.SetLinks(node.Address(), "")
+			node.Stabilize(context.Background())
+
+			if _, successor := node.Successor(); successor != nodes[1].Address() {
+				t.Errorf("successor after one round on its own = %q, want %s", successor, nodes[1].Address())
+			}
+		})
+	}
+}
+
+func TestSingleNodeOwnsEveryKey(t *testing.T) {
+	node := Create("127.0.0.1:1", WithLogger(quietLogger()))
+
+	for _, keyId := range []int{node.id, (node.id + 1) % ID_SPACE_SIZE, (node.id - 1 + ID_SPACE_SIZE) % ID_SPACE_SIZE, 0, ID_SPACE_SIZE - 1} {
+		if !node.owns(keyId) {
+			t.Errorf("single node with id %d does not own key id %d", node.id, keyId)
+		}
+	}
+
+	// Keys on both sides of the node's id are stored and read back locally
+	var above, below string
+	for i := 0; i < 1000 && (above == "" || below == ""); i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if node.ringId(key) > node.id {
+			above = key
+		} else {
+			below = key
+		}
+	}
+	if above == "" || below == "" {
+		t.Fatalf("no keys found on both sides of id %d", node.id)
+	}
+	for _, key := range []string{above, below} {
+		if _, next, err := node.Put(key, []byte("value"), 0); err != nil || next != "" {
+			t.Fatalf("Put(%q) with id %d = %q, %v, want stored locally", key, node.ringId(key), next, err)
+		}
+		if value, next, err := node.Get(key); err != nil || next != "" || string(value.Value) != "value" {
+			t.Errorf("Get(%q) with id %d = %q, %q, %v, want \"value\"", key, node.ringId(key), value.Value, next, err)
+		}
+	}
+}
+
+func TestPredecessorClearedAfterConsecutiveFailures(t *testing.T) {
+	for _, threshold := range []int{DefaultPredecessorFailureThreshold, 1, 5} {
+		t.Run(fmt.Sprint(threshold), func(t *testing.T) {
+			net := NewMemoryNetwork()
+			nodes := net.BuildRing(3, WithPredecessorFailureThreshold(threshold))
+			predecessor, node := nodes[0], nodes[1]
+			ctx := context.Background()
+
+			// Transient failures short of the threshold, with a success in between, keep the predecessor
+			for range 2 {
+				net.Fail(predecessor.Address())
+				for range threshold - 1 {
+					node.CheckPredecessor(ctx)
+				}
+				net.Recover(predecessor.Address())
+				node.CheckPredecessor(ctx)
+				if _, got := node.Predecessor(); got != predecessor.Address() {
+					t.Fatalf("predecessor after %d failed checks = %q, want %s kept", threshold-1, got, predecessor.Address())
+				}
+			}
+
+			// The threshold of consecutive failures clears it
+			net.Fail(predecessor.Address())
+			for i := range threshold {
+				if _, got := node.Predecessor(); got != predecessor.Address() {
+					t.Fatalf("predecessor cleared after %d of %d failed checks", i, threshold)
+				}
+				node.CheckPredecessor(ctx)
+			}
+			if _, got := node.Predecessor(); got != "" {
+				t.Errorf("predecessor after %d failed checks = %q, want it cleared", threshold, got)
+			}
+		})
+	}
+}
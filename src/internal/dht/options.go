@@ -0,0 +1,239 @@
+package dht
+
+import (
+	"time"
+
+	"assignment/internal/logging"
+)
+
+// Option configures optional behaviour of a node in Create
+type Option func(*Node)
+
+// WithQuietMaintenance suppresses logging of routine no-op maintenance,
+// only actual changes and failures are logged.
+func WithQuietMaintenance(quiet bool) Option {
+	return func(n *Node) {
+		n.quietMaintenance = quiet
+	}
+}
+
+// WithM sets the number of bits in the identifier space, the ring has 2^m ids and m finger entries.
+// Values outside 1..30 are ignored and the default M is used.
+func WithM(m int) Option {
+	return func(n *Node) {
+		if m < 1 || m > 30 {
+			n.logger.Warn("WithM", "invalid M, using default", "m", m, "default", M)
+			return
+		}
+		n.m = m
+		n.idSpaceSize = 1 << m
+	}
+}
+
+// WithSuccessorListSize sets the number of backup successors kept for fault tolerance
+func WithSuccessorListSize(r int) Option {
+	return func(n *Node) {
+		if r < 1 {
+			n.logger.Warn("WithSuccessorListSize", "invalid size, using default", "size", r, "default", DefaultSuccessorListSize)
+			return
+		}
+		n.successorListSize = r
+	}
+}
+
+// WithMaxRetries sets the number of attempts per transport call in stabilization and lookups
+func WithMaxRetries(retries int) Option {
+	return func(n *Node) {
+		if retries < 1 {
+			n.logger.Warn("WithMaxRetries", "invalid retries, using default", "retries", retries, "default", DefaultMaxRetries)
+			return
+		}
+		n.maxRetries = retries
+	}
+}
+
+// WithPredecessorFailureThreshold sets the number of consecutive failed checks after which the
+// predecessor is considered dead and cleared, see CheckPredecessor
+func WithPredecessorFailureThreshold(k int) Option {
+	return func(n *Node) {
+		if k < 1 {
+			n.logger.Warn("WithPredecessorFailureThreshold", "invalid threshold, using default", "threshold", k, "default", DefaultPredecessorFailureThreshold)
+			return
+		}
+		n.predecessorFailureThreshold = k
+	}
+}
+
+// WithLogger sets the logger of the node, the default is the text logger on the standard log package
+func WithLogger(logger logging.Logger) Option {
+	return func(n *Node) {
+		n.logger = logger
+	}
+}
+
+// WithVerifyFingers checks that a resolved finger is alive before it is committed to the table,
+// at the cost of an extra RPC per updated finger.
+func WithVerifyFingers(verify bool) Option {
+	return func(n *Node) {
+		n.verifyFingers = verify
+	}
+}
+
+// WithLookupMode sets how FindSuccessor resolves keys, LookupRecursive (default) or LookupIterative.
+// Unknown modes are ignored.
+func WithLookupMode(mode string) Option {
+	return func(n *Node) {
+		if mode != LookupRecursive && mode != LookupIterative {
+			n.logger.Warn("WithLookupMode", "invalid lookup mode, using default", "mode", mode, "default", LookupRecursive)
+			return
+		}
+		n.lookupMode = mode
+	}
+}
+
+// WithReadStrategy sets how the predecessor of a key's owner spreads the reads of the key over
+// its copies: ReadPrimary (default) reads from the owner, ReadRoundRobin from the owner and the
+// replicas in turn and ReadLatencyWeighted from a copy picked at random, favouring the ones with
+// the lowest recent RPC latency. Only reads at ConsistencyOne are spread, a replica may briefly
+// lag behind the owner.
+func WithReadStrategy(strategy string) Option {
+	return func(n *Node) {
+		if strategy != ReadPrimary && strategy != ReadRoundRobin && strategy != ReadLatencyWeighted {
+			n.logger.Warn("WithReadStrategy", "invalid read strategy, using default", "strategy", strategy, "default", ReadPrimary)
+			return
+		}
+		n.readStrategy = strategy
+	}
+}
+
+// WithEncryptionKey encrypts stored values at rest with AES-GCM, the key is derived from the passphrase.
+// Plaintext values already stored stay readable. An empty passphrase leaves encryption disabled.
+func WithEncryptionKey(passphrase string) Option {
+	return func(n *Node) {
+		if passphrase == "" {
+			return
+		}
+		c, err := newValueCipher(passphrase)
+		if err != nil {
+			n.logger.Warn("WithEncryptionKey", "failed to enable encryption at rest", "err", err)
+			return
+		}
+		n.cipher = c
+	}
+}
+
+// WithReplicationFactor stores every key on its owner and the next factor-1 nodes of the successor list.
+// Values below 1 are ignored and the default DefaultReplicationFactor is used.
+func WithReplicationFactor(factor int) Option {
+	return func(n *Node) {
+		if factor < 1 {
+			n.logger.Warn("WithReplicationFactor", "invalid replication factor, using default", "factor", factor, "default", DefaultReplicationFactor)
+			return
+		}
+		n.replicationFactor = factor
+	}
+}
+
+// WithTiming sets the maintenance interval, the timeouts are used by the transport, see Timing.
+// A timing that fails Validate is ignored and the default is used.
+func WithTiming(timing Timing) Option {
+	return func(n *Node) {
+		if err := timing.Validate(); err != nil {
+			n.logger.Warn("WithTiming", "invalid timing, using default", "error", err, "default", n.tickInterval)
+			return
+		}
+		n.tickInterval = timing.MaintenanceInterval
+	}
+}
+
+// WithMaxMaintenanceBackoff sets the largest multiple of the maintenance interval rounds are
+// stretched to while peers are overloaded, see maintenanceThrottle. 1 disables the throttle,
+// values below 1 are ignored and DefaultMaxMaintenanceBackoff is used.
+func WithMaxMaintenanceBackoff(maxBackoff int) Option {
+	return func(n *Node) {
+		if maxBackoff < 1 {
+			n.logger.Warn("WithMaxMaintenanceBackoff", "invalid maximum maintenance backoff, using default", "max_backoff", maxBackoff, "default", DefaultMaxMaintenanceBackoff)
+			return
+		}
+		n.throttle.maxBackoff = maxBackoff
+	}
+}
+
+// WithDataFile persists the stored keys to a snapshot at the path and a write-ahead log next to it,
+// restored when the node is created. An empty path leaves persistence disabled.
+func WithDataFile(path string) Option {
+	return func(n *Node) {
+		if path == "" {
+			return
+		}
+		n.persist = &persister{path: path}
+	}
+}
+
+// WithHasher sets the hash function placing keys and nodes on the ring, SHA-1 by default.
+// Every node of a ring must use the same one, nil is ignored.
+func WithHasher(h Hasher) Option {
+	return func(n *Node) {
+		if h != nil {
+			n.hasher = h
+		}
+	}
+}
+
+// WithPreferLocalFingers lets FindSuccessor answer from the finger table or successor list without
+// a remote hop when they cover the key, trading accuracy during churn for lower lookup latency.
+func WithPreferLocalFingers(prefer bool) Option {
+	return func(n *Node) {
+		n.preferLocal = prefer
+	}
+}
+
+// WithMaxBytes sets the total length of the values the node stores. With WithMaxKeys' rejectWhenFull,
+// a write growing the values past it is refused with ErrStorageFull. 0 (default) leaves it unbounded.
+func WithMaxBytes(max int) Option {
+	return func(n *Node) {
+		if max < 0 {
+			n.logger.Warn("WithMaxBytes", "invalid max bytes, must not be negative", "max_bytes", max)
+			return
+		}
+		n.maxBytes = max
+	}
+}
+
+// WithHotKeyCapacity sets the number of keys whose gets and puts are counted for HotKeys, the least
+// accessed one is evicted for a new key. 0 disables counting, default DefaultHotKeyCapacity.
+func WithHotKeyCapacity(capacity int) Option {
+	return func(n *Node) {
+		if capacity < 0 {
+			n.logger.Warn("WithHotKeyCapacity", "invalid hot key capacity, using default", "capacity", capacity, "default", DefaultHotKeyCapacity)
+			return
+		}
+		n.access.capacity = capacity
+	}
+}
+
+// WithMaxTTL sets the longest a written key lives, a longer TTL is shortened to it and a key
+// written without TTL expires after it. 0 (default) lets keys live forever.
+func WithMaxTTL(max time.Duration) Option {
+	return func(n *Node) {
+		if max < 0 {
+			n.logger.Warn("WithMaxTTL", "invalid max ttl, must not be negative", "max_ttl", max)
+			return
+		}
+		n.maxTTL = max
+	}
+}
+
+// WithMaxKeys sets the number of keys the node stores, reported as fullness in the stats.
+// With rejectWhenFull, writes of new keys are refused with ErrStorageFull at capacity while
+// updates of stored keys are still accepted. 0 (default) leaves the store unbounded.
+func WithMaxKeys(max int, rejectWhenFull bool) Option {
+	return func(n *Node) {
+		if max < 0 {
+			n.logger.Warn("WithMaxKeys", "invalid max keys, must not be negative", "max_keys", max)
+			return
+		}
+		n.maxKeys = max
+		n.rejectWhenFull = rejectWhenFull
+	}
+}
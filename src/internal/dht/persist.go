@@ -0,0 +1,317 @@
+package dht
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// The write-ahead log is folded into the snapshot once it holds compactAfterRecords records,
+// checked every compactCheckTicks maintenance ticks
+const (
+	compactAfterRecords = 1000
+	compactCheckTicks   = 25
+)
+
+// Peers kept to rejoin the ring through after a restart, the most recent successors
+const maxRestoredPeers = 8
+
+// Operations of a write-ahead log record
+const (
+	recordPut       = "put"
+	recordDelete    = "delete"
+	recordSuccessor = "successor"
+)
+
+// persistRecord is a line of the write-ahead log, or a key of the snapshot.
+// The value of a key is stored as held in memory, i.e. encrypted if encryption at rest is enabled,
+// base64 in Data so binary values survive the JSON. Value holds the successor of a successor record,
+// and the value of a key in files written before values were binary.
+type persistRecord struct {
+	Op        string    `json:"op"`
+	Key       string    `json:"key,omitempty"`
+	Value     string    `json:"value,omitempty"`
+	Data      []byte    `json:"data,omitempty"`
+	Version   uint64    `json:"version,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+// persistSnapshot is the content of the snapshot file
+type persistSnapshot struct {
+	Peers []string        `json:"peers"` // successor and successor list when the snapshot was taken
+	Data  []persistRecord `json:"data"`
+}
+
+// persister writes the key-value store to a snapshot file and a write-ahead log next to it.
+// Every write to the data map appends a record to the log while dataMu is held shared, a
+// compaction holds it exclusively, so a record is either folded into the snapshot or kept in the log.
+type persister struct {
+	path    string // snapshot file, the log is path + ".log"
+	mu      sync.Mutex
+	log     *os.File
+	records int
+	peers   []string // last known successor, restored for the rejoin
+	err     error    // failure to restore or open the files, persistence is disabled then
+}
+
+// logPath returns the path of the write-ahead log
+func (p *persister) logPath() string {
+	return p.path + ".log"
+}
+
+// restore loads the snapshot and replays the log into the data map, then opens the log for
+// appending. Restored values lose to values handed off by the successor on the rejoin, the
+// successor may have taken writes while this node was down.
+func (n *Node) restore() error {
+
+	p := n.persist
+	snapshot := persistSnapshot{}
+	raw, err := os.ReadFile(p.path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	default:
+		if err := json.Unmarshal(raw, &snapshot); err != nil {
+			return fmt.Errorf("failed to decode snapshot %s: %w", p.path, err)
+		}
+	}
+
+	restored := make(map[string]*storedValue, len(snapshot.Data))
+	for _, r := range snapshot.Data {
+		restored[r.Key] = r.stored()
+	}
+	p.peers = snapshot.Peers
+
+	// Replay the log, a torn last line of a crash while appending is skipped
+	records := 0
+	if f, err := os.Open(p.logPath()); err == nil {
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1<<30)
+		for scanner.Scan() {
+			var r persistRecord
+			if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+				n.logger.Warn("Restore", "skipping unreadable log record", "log", p.logPath(), "record", records+1, "err", err)
+				continue
+			}
+			records++
+			switch r.Op {
+			case recordPut:
+				restored[r.Key] = r.stored()
+			case recordDelete:
+				delete(restored, r.Key)
+			case recordSuccessor:
+				p.peers = prependPeer(p.peers, r.Value)
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read log %s: %w", p.logPath(), err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to open log: %w", err)
+	}
+
+	now := time.Now()
+	for key, stored := range restored {
+		if !stored.expired(now) {
+			n.data.Store(key, stored)
+			n.dataKeys.Add(1)
+			n.dataBytes.Add(storedLen(stored))
+		}
+	}
+
+	log, err := os.OpenFile(p.logPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log for writing: %w", err)
+	}
+	p.log = log
+	p.records = records
+
+	n.logger.Info("Restore", "restored data from disk", "snapshot", p.path, "keys", n.KeyCount(), "log_records", records, "peers", len(p.peers))
+	return nil
+}
+
+// prependPeer puts the peer first in the list of peers, most recent first, keeping each peer once
+// and at most maxRestoredPeers of them. A successor flapping between compactions can't grow the list.
+func prependPeer(peers []string, peer string) []string {
+	list := append(make([]string, 0, len(peers)+1), peer)
+	for _, other := range peers {
+		if len(list) == maxRestoredPeers {
+			break
+		}
+		if other != peer {
+			list = append(list, other)
+		}
+	}
+	return list
+}
+
+// stored returns the restored value of a put record
+func (r persistRecord) stored() *storedValue {
+	value := r.Data
+	if value == nil && r.Value != "" {
+		value = []byte(r.Value)
+	}
+	return &storedValue{value: value, version: r.Version, expiresAt: r.ExpiresAt, restored: true}
+}
+
+// appendRecord writes the record to the log, failures are logged and the record is lost on restart
+func (n *Node) appendRecord(r persistRecord) {
+	p := n.persist
+	if p == nil || p.log == nil {
+		return
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		n.logger.Error("Persist", "failed to encode log record", "key", r.Key, "err", err)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.log.Write(append(line, '\n')); err != nil {
+		n.logger.Error("Persist", "failed to append log record", "key", r.Key, "err", err)
+		return
+	}
+	p.records++
+}
+
+// persistPut logs a write to the data map, dataMu must be held shared
+func (n *Node) persistPut(key string, stored *storedValue) {
+	n.appendRecord(persistRecord{Op: recordPut, Key: key, Data: stored.value, Version: stored.version, ExpiresAt: stored.expiresAt})
+}
+
+// persistDelete logs a delete from the data map, dataMu must be held shared
+func (n *Node) persistDelete(key string) {
+	n.appendRecord(persistRecord{Op: recordDelete, Key: key})
+}
+
+// persistSuccessor logs a new successor, a restarted node rejoins the ring through it
+func (n *Node) persistSuccessor(successorAddr string) {
+	if successorAddr != n.address {
+		n.appendRecord(persistRecord{Op: recordSuccessor, Value: successorAddr})
+	}
+}
+
+// compact writes the data map to a new snapshot, replaced atomically, and empties the log.
+// Writers are blocked while the snapshot is taken.
+func (n *Node) compact() error {
+	p := n.persist
+	if p == nil || p.log == nil {
+		return nil
+	}
+
+	peers := []string{}
+	if _, successorAddr := n.Successor(); successorAddr != n.Address() {
+		peers = append(peers, successorAddr)
+	}
+	peers = append(peers, n.SuccessorList()...)
+
+	n.dataMu.Lock()
+	defer n.dataMu.Unlock()
+
+	snapshot := persistSnapshot{Peers: peers, Data: []persistRecord{}}
+	now := time.Now()
+	n.data.Range(func(k, v any) bool {
+		key, ok := k.(string)
+		stored, valid := v.(*storedValue)
+		if ok && valid && !stored.expired(now) {
+			snapshot.Data = append(snapshot.Data, persistRecord{Op: recordPut, Key: key, Data: stored.value, Version: stored.version, ExpiresAt: stored.expiresAt})
+		}
+		return true
+	})
+
+	raw, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	tmp := p.path + ".tmp"
+	if err := writeFileSync(tmp, raw); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, p.path); err != nil {
+		return fmt.Errorf("failed to replace snapshot: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.log.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate log: %w", err)
+	}
+	folded := p.records
+	p.records = 0
+	p.peers = peers
+
+	n.logger.Info("Compact", "folded log into snapshot", "keys", len(snapshot.Data), "records", folded)
+	return nil
+}
+
+// maybeCompact compacts once the log has grown past compactAfterRecords
+func (n *Node) maybeCompact() {
+	p := n.persist
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	records := p.records
+	p.mu.Unlock()
+	if records < compactAfterRecords {
+		return
+	}
+	if err := n.compact(); err != nil {
+		n.logger.Error("Compact", "failed to compact log", "err", err)
+	}
+}
+
+// writeFileSync writes the file and flushes it to disk
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// PersistenceErr returns the error that disabled persistence when restoring the data on Create, nil if
+// persistence is disabled or works
+func (n *Node) PersistenceErr() error {
+	if n.persist == nil {
+		return nil
+	}
+	return n.persist.err
+}
+
+// RestoredPeers returns the nodes this node was linked to before a restart, most recent first,
+// to rejoin the ring through
+func (n *Node) RestoredPeers() []string {
+	if n.persist == nil {
+		return nil
+	}
+	n.persist.mu.Lock()
+	defer n.persist.mu.Unlock()
+
+	var peers []string
+	seen := map[string]bool{n.address: true}
+	for _, peer := range n.persist.peers {
+		if peer != "" && !seen[peer] {
+			peers = append(peers, peer)
+			seen[peer] = true
+		}
+	}
+	return peers
+}
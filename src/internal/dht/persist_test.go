@@ -0,0 +1,168 @@
+package dht
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// persistedNode creates a node persisting to the data file, closing its log at the end of the test
+func persistedNode(t *testing.T, path string) *Node {
+	t.Helper()
+	node := Create("127.0.0.1:8000", WithLogger(quietLogger()), WithDataFile(path))
+	if err := node.PersistenceErr(); err != nil {
+		t.Fatalf("PersistenceErr: %v", err)
+	}
+	t.Cleanup(func() { node.persist.log.Close() })
+	return node
+}
+
+// restart closes the log of the node and creates it again from its data file
+func restart(t *testing.T, node *Node) *Node {
+	t.Helper()
+	node.persist.log.Close()
+	return persistedNode(t, node.persist.path)
+}
+
+func TestRestartRestoresKeys(t *testing.T) {
+	node := persistedNode(t, filepath.Join(t.TempDir(), "data.json"))
+	for _, key := range []string{"a", "b", "a"} {
+		if _, _, err := node.Put(key, []byte("value of "+key), time.Hour); err != nil {
+			t.Fatalf("Put(%q): %v", key, err)
+		}
+	}
+	if _, _, err := node.Put("binary", []byte{0, 0xff, '\n'}, 0); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	before, _ := node.loadVersioned(&node.data, "a")
+
+	node = restart(t, node)
+
+	after, ok := node.loadVersioned(&node.data, "a")
+	if !ok || string(after.Value) != "value of a" || after.Version != 2 || !after.ExpiresAt.Equal(before.ExpiresAt) {
+		t.Errorf("restored a = %q at version %d expiring %v, want %q at version 2 expiring %v", after.Value, after.Version, after.ExpiresAt, "value of a", before.ExpiresAt)
+	}
+	if value, ok := node.load("binary"); !ok || !slices.Equal(value, []byte{0, 0xff, '\n'}) {
+		t.Errorf("restored binary = %v, want the bytes as written", value)
+	}
+	if got := node.KeyCount(); got != 3 {
+		t.Errorf("%d keys restored, want 3", got)
+	}
+	if got, want := node.StoredBytes(), int64(len("value of a")+len("value of b")+3); got != want {
+		t.Errorf("restored bytes = %d, want %d", got, want)
+	}
+}
+
+func TestRestoreSkipsTornLastRecord(t *testing.T) {
+	node := persistedNode(t, filepath.Join(t.TempDir(), "data.json"))
+	if _, _, err := node.Put("kept", []byte("value"), 0); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	// A crash while appending leaves half a record
+	if _, err := node.persist.log.WriteString(`{"op":"put","key":"torn","da`); err != nil {
+		t.Fatalf("failed to tear the log: %v", err)
+	}
+	node = restart(t, node)
+
+	if _, ok := node.load("kept"); !ok {
+		t.Error("key before the torn record was not restored")
+	}
+	if _, ok := node.load("torn"); ok {
+		t.Error("torn record was restored")
+	}
+}
+
+func TestCompactFoldsLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	node := persistedNode(t, path)
+	for i := range 20 {
+		if _, _, err := node.Put(fmt.Sprintf("key-%d", i%10), []byte(fmt.Sprintf("v%d", i)), 0); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	if err := node.compact(); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if info, err := os.Stat(path + ".log"); err != nil || info.Size() != 0 {
+		t.Fatalf("log after the compaction: %v, %v, want an empty log", info, err)
+	}
+	if node.persist.records != 0 {
+		t.Errorf("%d log records counted after the compaction, want 0", node.persist.records)
+	}
+
+	// Writes after the compaction go to the log on top of the snapshot
+	if _, _, err := node.Put("key-0", []byte("after"), 0); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	node = restart(t, node)
+
+	if got := node.KeyCount(); got != 10 {
+		t.Errorf("%d keys restored, want 10", got)
+	}
+	if value, _ := node.loadVersioned(&node.data, "key-0"); string(value.Value) != "after" || value.Version != 3 {
+		t.Errorf("restored key-0 = %q at version %d, want \"after\" at version 3", value.Value, value.Version)
+	}
+	if value, _ := node.load("key-9"); string(value) != "v19" {
+		t.Errorf("restored key-9 = %q, want \"v19\"", value)
+	}
+}
+
+func TestDeleteSurvivesRestart(t *testing.T) {
+	node := persistedNode(t, filepath.Join(t.TempDir(), "data.json"))
+	for _, key := range []string{"snapshotted", "logged"} {
+		if _, _, err := node.Put(key, []byte("value"), 0); err != nil {
+			t.Fatalf("Put(%q): %v", key, err)
+		}
+		if key == "snapshotted" {
+			if err := node.compact(); err != nil {
+				t.Fatalf("compact: %v", err)
+			}
+		}
+	}
+
+	// One key is deleted from the snapshot, the other from the log
+	for _, key := range []string{"snapshotted", "logged"} {
+		if _, err := node.Delete(key); err != nil {
+			t.Fatalf("Delete(%q): %v", key, err)
+		}
+	}
+	node = restart(t, node)
+
+	for _, key := range []string{"snapshotted", "logged"} {
+		if _, ok := node.load(key); ok {
+			t.Errorf("deleted key %q came back after the restart", key)
+		}
+	}
+	if got := node.StoredBytes(); got != 0 {
+		t.Errorf("restored bytes = %d, want 0", got)
+	}
+}
+
+func TestRestoredPeersAreCapped(t *testing.T) {
+	node := persistedNode(t, filepath.Join(t.TempDir(), "data.json"))
+
+	// A successor flapping between two peers, then more peers than are kept
+	for range 100 {
+		node.SetSuccessor("127.0.0.1:8001")
+		node.SetSuccessor("127.0.0.1:8002")
+	}
+	var latest []string
+	for i := range maxRestoredPeers + 2 {
+		addr := fmt.Sprintf("127.0.0.1:%d", 9000+i)
+		node.SetSuccessor(addr)
+		latest = append([]string{addr}, latest...)
+	}
+	node = restart(t, node)
+
+	if got := node.RestoredPeers(); !slices.Equal(got, latest[:maxRestoredPeers]) {
+		t.Errorf("restored peers = %v, want the %d latest successors %v", got, maxRestoredPeers, latest[:maxRestoredPeers])
+	}
+	if len(node.persist.peers) > maxRestoredPeers {
+		t.Errorf("%d peers kept, want at most %d", len(node.persist.peers), maxRestoredPeers)
+	}
+}
@@ -0,0 +1,147 @@
+package dht
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// ReadPrimary reads every key from its owner
+	ReadPrimary = "primary"
+
+	// ReadRoundRobin spreads the reads of a node over the copies of the key in turn
+	ReadRoundRobin = "round-robin"
+
+	// ReadLatencyWeighted picks a copy at random, weighted by the inverse of its recent RPC latency
+	ReadLatencyWeighted = "latency-weighted"
+)
+
+// Latencies below minReadLatency are weighted like it, so a copy answering in microseconds
+// doesn't take every read
+const minReadLatency = time.Millisecond
+
+// Weight of a new latency sample in the moving average of PeerLatencies
+const latencySmoothing = 0.2
+
+// PeerLatencies keeps a moving average of the RPC latency to every peer, transports record their
+// calls in it to answer Transport.Latency. The zero value is ready to use.
+type PeerLatencies struct {
+	mu        sync.Mutex
+	latencies map[string]time.Duration
+}
+
+// Observe records an RPC to the peer that took d
+func (p *PeerLatencies) Observe(addr string, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.latencies == nil {
+		p.latencies = make(map[string]time.Duration)
+	}
+	if last, ok := p.latencies[addr]; ok {
+		d = last + time.Duration(latencySmoothing*float64(d-last))
+	}
+	p.latencies[addr] = d
+}
+
+// Latency returns the average latency to the peer, known is false if no RPC was recorded
+func (p *PeerLatencies) Latency(addr string) (latency time.Duration, known bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	latency, known = p.latencies[addr]
+	return latency, known
+}
+
+// readCopies returns the nodes holding a copy of a key the successor owns, the successor first
+// and then its replica set as far as the successor list of this node shows it: the next
+// replicationFactor-1 distinct processes after the successor
+func (n *Node) readCopies() []string {
+	_, successorAddr := n.Successor()
+	copies := []string{successorAddr}
+
+	owner, _ := SplitVnode(successorAddr)
+	seen := map[string]bool{owner: true}
+	for _, addr := range n.SuccessorList() {
+		if len(copies) == n.replicationFactor {
+			break
+		}
+		host, _ := SplitVnode(addr)
+		if addr != "" && addr != n.Address() && !seen[host] {
+			copies = append(copies, addr)
+			seen[host] = true
+		}
+	}
+	return copies
+}
+
+// pickCopy returns the copy a read goes to by the read strategy of the node. A copy whose
+// latency is not known yet is weighted by the average of the known ones, all alike if none is.
+func (n *Node) pickCopy(copies []string) string {
+	switch n.readStrategy {
+	case ReadRoundRobin:
+		return copies[int(n.readTurn.Add(1)-1)%len(copies)]
+
+	case ReadLatencyWeighted:
+		latencies := make([]time.Duration, len(copies))
+		var sum time.Duration
+		known := 0
+		for i, addr := range copies {
+			if latency, ok := n.transport.Latency(addr); ok {
+				latencies[i] = max(latency, minReadLatency)
+				sum += latencies[i]
+				known++
+			}
+		}
+		weights := make([]float64, len(copies))
+		total := 0.0
+		for i := range copies {
+			latency := latencies[i]
+			if latency == 0 {
+				latency = minReadLatency
+				if known > 0 {
+					latency = sum / time.Duration(known)
+				}
+			}
+			weights[i] = 1 / latency.Seconds()
+			total += weights[i]
+		}
+		r := rand.Float64() * total
+		for i, weight := range weights {
+			if r -= weight; r < 0 {
+				return copies[i]
+			}
+		}
+		return copies[len(copies)-1]
+	}
+	return copies[0]
+}
+
+// readReplica serves a read of a key the successor owns from a copy picked by the read strategy.
+// Returns false if the read should go on to the owner: the successor does not own the key, the
+// strategy picked the owner, or the replica can't be reached or does not hold the key, e.g.
+// before the next replica sync.
+func (n *Node) readReplica(key string, keyId int) (Versioned, bool) {
+	if n.readStrategy == ReadPrimary || n.replicationFactor <= 1 {
+		return Versioned{}, false
+	}
+	if successorId, _ := n.Successor(); !InIntervalRightInclusive(keyId, n.Id(), successorId) {
+		return Versioned{}, false
+	}
+	copies := n.readCopies()
+	addr := n.pickCopy(copies)
+	if addr == copies[0] {
+		return Versioned{}, false
+	}
+
+	value, found, err := n.transport.GetReplica(addr, key)
+	if err != nil {
+		n.logger.Warn("Get", "failed to read replica, reading from the owner", "key", key, "replica", addr, "err", err)
+		return Versioned{}, false
+	}
+	if !found {
+		return Versioned{}, false
+	}
+	n.logger.Info("Get", "read key from replica", "key", key, "replica", addr, "version", value.Version)
+	return value, true
+}
@@ -0,0 +1,119 @@
+package dht
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingTransport counts the replica reads of a node and answers fixed latencies
+type countingTransport struct {
+	Transport
+	mu        sync.Mutex
+	reads     map[string]int
+	latencies map[string]time.Duration
+}
+
+func (c *countingTransport) GetReplica(targetAddr string, key string) (Versioned, bool, error) {
+	c.mu.Lock()
+	c.reads[targetAddr]++
+	c.mu.Unlock()
+	return c.Transport.GetReplica(targetAddr, key)
+}
+
+func (c *countingTransport) Latency(targetAddr string) (time.Duration, bool) {
+	latency, ok := c.latencies[targetAddr]
+	return latency, ok
+}
+
+func TestReadStrategyDistribution(t *testing.T) {
+	const reads = 7000
+	tests := []struct {
+		strategy string
+		want     []float64 // share of the reads served by the owner and its two replicas
+	}{
+		{ReadPrimary, []float64{1, 0, 0}},
+		{ReadRoundRobin, []float64{1.0 / 3, 1.0 / 3, 1.0 / 3}},
+		{ReadLatencyWeighted, []float64{4.0 / 7, 2.0 / 7, 1.0 / 7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			nodes := BuildRing(4, WithReplicationFactor(3), WithReadStrategy(tt.strategy))
+			reader, copies := nodes[0], nodes[1:]
+
+			// Fill the successor lists, which place the replicas and show them to the reader
+			for range 3 {
+				for _, node := range nodes {
+					node.Stabilize(context.Background())
+				}
+			}
+
+			var key string
+			for i := 0; key == ""; i++ {
+				if candidate := fmt.Sprintf("key-%d", i); ownerNode(nodes, candidate) == copies[0] {
+					key = candidate
+				}
+			}
+			ringPut(t, nodes, key, []byte("value"), 0)
+
+			// The owner answers twice as fast as the first replica and four times as fast as the second
+			counting := &countingTransport{
+				Transport: reader.transport,
+				reads:     make(map[string]int),
+				latencies: map[string]time.Duration{
+					copies[0].Address(): 2 * time.Millisecond,
+					copies[1].Address(): 4 * time.Millisecond,
+					copies[2].Address(): 8 * time.Millisecond,
+				},
+			}
+			reader.SetTransport(counting)
+
+			owner := 0
+			for range reads {
+				value, next, err := reader.Get(key)
+				switch {
+				case err != nil:
+					t.Fatalf("Get: %v", err)
+				case next == copies[0].Address():
+					owner++
+				case next != "":
+					t.Fatalf("Get forwarded to %s, want the owner %s", next, copies[0].Address())
+				case string(value.Value) != "value" || value.Version != 1:
+					t.Fatalf("replica read = %q at version %d, want \"value\" at version 1", value.Value, value.Version)
+				}
+			}
+
+			served := []int{owner, counting.reads[copies[1].Address()], counting.reads[copies[2].Address()]}
+			for i, want := range tt.want {
+				if got := float64(served[i]) / reads; math.Abs(got-want) > 0.03 {
+					t.Errorf("copy %d served %.3f of the reads, want %.3f (served %v)", i, got, want, served)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryTransportRecordsLatency(t *testing.T) {
+	net := NewMemoryNetwork()
+	nodes := net.BuildRing(2)
+	net.SetNodeLatency(nodes[1].Address(), 5*time.Millisecond)
+
+	if _, known := nodes[0].transport.Latency(nodes[1].Address()); !known {
+		t.Fatal("latency to the peer unknown after building the ring")
+	}
+	for range 30 {
+		if _, _, err := nodes[0].transport.GetReplica(nodes[1].Address(), "key"); err != nil {
+			t.Fatalf("GetReplica: %v", err)
+		}
+	}
+	if latency, _ := nodes[0].transport.Latency(nodes[1].Address()); latency < 4*time.Millisecond {
+		t.Errorf("latency to the delayed peer = %v, want about 5ms", latency)
+	}
+	if _, known := nodes[0].transport.Latency("node-unknown"); known {
+		t.Error("latency to a node never called is known")
+	}
+}
@@ -0,0 +1,78 @@
+package dht
+
+import "context"
+
+// Repair moves the locally stored keys this node does not own to their owner.
+// Keys can end up misplaced after churn or a failed handoff. Returns the number of
+// keys moved and the number that could not be moved (kept locally).
+func (n *Node) Repair() (moved int, failed int) {
+
+	// Collect the keys first, moving a key deletes it from the store
+	var keys []string
+	n.RangeKeys(func(key string, value []byte) bool {
+		keys = append(keys, key)
+		return true
+	})
+
+	for _, key := range keys {
+
+		keyId := n.ringId(key)
+
+		predecessorId, predecessorAddr := n.Predecessor()
+		if predecessorAddr == "" || InIntervalRightInclusive(keyId, predecessorId, n.Id()) {
+			// Owned by this node or range unknown, keep it
+			continue
+		}
+
+		owner, err := n.FindSuccessor(context.Background(), keyId)
+		if err != nil {
+			n.logger.Error("Repair", "failed to find owner of key", "key", key, "key_id", keyId, "err", err)
+			failed++
+			continue
+		}
+
+		if owner == n.Address() {
+			continue
+		}
+
+		if err := n.moveKey(key, owner); err != nil {
+			n.logger.Error("Repair", "failed to move key", "key", key, "key_id", keyId, "owner", owner, "err", err)
+			failed++
+			continue
+		}
+		moved++
+	}
+
+	n.logger.Info("Repair", "moved misplaced keys", "moved", moved, "failed", failed)
+	return moved, failed
+}
+
+// moveKey moves the key to the owner. The key is read-only for the duration of the move,
+// writes to it get ErrKeyMoving so they are retried instead of racing the move and being lost.
+func (n *Node) moveKey(key string, owner string) error {
+
+	n.movingMu.Lock()
+	n.moving[key] = true
+	n.movingMu.Unlock()
+
+	defer func() {
+		n.movingMu.Lock()
+		delete(n.moving, key)
+		n.movingMu.Unlock()
+	}()
+
+	// Read the value after marking, no write can change it until the move completes
+	value, ok := n.load(key)
+	if !ok {
+		return nil
+	}
+
+	if err := n.transport.StoreKey(owner, key, value); err != nil {
+		return err
+	}
+
+	n.dataMu.RLock()
+	n.deleteData(key)
+	n.dataMu.RUnlock()
+	return nil
+}
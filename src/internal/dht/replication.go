@@ -0,0 +1,201 @@
+package dht
+
+import (
+	"time"
+)
+
+// Owners push their keys to the replica set every replicaSyncTicks maintenance ticks
+const replicaSyncTicks = 25
+
+// A replica not refreshed by its owner within replicaLease is dropped, so replicas on nodes
+// that fell out of the replica set under churn don't drift permanently
+const replicaLease = 20 * time.Second
+
+// Default number of nodes a key is stored on, the owner only, override with WithReplicationFactor
+const DefaultReplicationFactor = 1
+
+// replicaSet returns the nodes holding replicas of this node's keys, the first
+// replicationFactor-1 distinct nodes of the successor list. Vnodes of the same process are
+// skipped, a replica there would be lost together with the owner.
+func (n *Node) replicaSet() []string {
+	if n.replicationFactor <= 1 {
+		return nil
+	}
+
+	var set []string
+	process, _ := SplitVnode(n.Address())
+	seen := map[string]bool{process: true}
+	for _, addr := range n.SuccessorList() {
+		if len(set) == n.replicationFactor-1 {
+			break
+		}
+		host, _ := SplitVnode(addr)
+		if addr != "" && !seen[host] {
+			set = append(set, addr)
+			seen[host] = true
+		}
+	}
+	return set
+}
+
+// replicate writes the versioned value of the key to the replica set, failures are logged and repaired by the next sync
+func (n *Node) replicate(key string, v Versioned) {
+	for _, addr := range n.replicaSet() {
+		if err := n.transport.PushReplicas(addr, map[string]Versioned{key: v}); err != nil {
+			n.logger.Error("Replicate", "failed to replicate key", "key", key, "replica", addr, "err", err)
+		}
+	}
+}
+
+// unreplicate deletes the key from the replica set
+func (n *Node) unreplicate(key string) {
+	for _, addr := range n.replicaSet() {
+		if err := n.transport.DeleteReplicas(addr, []string{key}); err != nil {
+			n.logger.Error("Replicate", "failed to delete replica", "key", key, "replica", addr, "err", err)
+		}
+	}
+}
+
+// readRepair looks up a key the owner is missing in the replicas, this node's own first, and
+// stores the value found back on the owner with its version. Returns false if no replica has the key.
+func (n *Node) readRepair(key string) (Versioned, bool) {
+
+	value, ok := n.Replica(key)
+	source := n.Address()
+	if !ok {
+		for _, addr := range n.replicaSet() {
+			v, found, err := n.transport.GetReplica(addr, key)
+			if err != nil {
+				n.logger.Error("ReadRepair", "failed to get replica", "key", key, "replica", addr, "err", err)
+				continue
+			}
+			if found {
+				value, ok, source = v, true, addr
+				break
+			}
+		}
+	}
+	if !ok {
+		return Versioned{}, false
+	}
+
+	// A write that reached the owner in the meantime wins over the replica. The replica carries
+	// the key's expiry, so a key written with a ttl doesn't come back without one.
+	n.dataMu.RLock()
+	n.loadOrStoreData(key, n.storedVersioned(value))
+	n.dataMu.RUnlock()
+
+	n.logger.Info("ReadRepair", "repaired key on owner from replica", "key", key, "replica", source)
+	return value, true
+}
+
+// repairReplicas compares the replicas of a key the owner just read with its own value in the
+// background and pushes the value to the replicas that miss it or hold another value or version.
+// The owner is the only writer of its keys, so its value is the latest and stale replicas converge
+// on reads without waiting for the next sync.
+func (n *Node) repairReplicas(key string, value Versioned) {
+	set := n.replicaSet()
+	if len(set) == 0 {
+		return
+	}
+
+	go func() {
+		for _, addr := range set {
+			replica, found, err := n.transport.GetReplica(addr, key)
+			if err != nil {
+				n.logger.Error("ReadRepair", "failed to get replica", "key", key, "replica", addr, "err", err)
+				continue
+			}
+			if found && replica.equal(value) {
+				continue
+			}
+			if err := n.transport.PushReplicas(addr, map[string]Versioned{key: value}); err != nil {
+				n.logger.Error("ReadRepair", "failed to repair replica", "key", key, "replica", addr, "err", err)
+				continue
+			}
+			n.logger.Info("ReadRepair", "repaired stale replica", "key", key, "replica", addr, "was_missing", !found)
+		}
+	}()
+}
+
+// syncReplicas promotes the replicas this node now owns, e.g. after its predecessor crashed,
+// then refreshes the replica set with every owned key
+func (n *Node) syncReplicas() {
+
+	if n.replicationFactor <= 1 {
+		return
+	}
+
+	from, to := n.OwnedRange()
+	owns := func(key string) bool {
+		return InIntervalRightInclusive(n.ringId(key), from, to)
+	}
+
+	// Promote owned replicas with their expiry, a value already on the owner wins
+	promoted := 0
+	n.dataMu.RLock()
+	n.rangeVersioned(&n.replicas, func(key string, value Versioned) bool {
+		if owns(key) {
+			if loaded := n.loadOrStoreData(key, n.storedVersioned(value)); !loaded {
+				promoted++
+			}
+			n.replicas.Delete(key)
+		}
+		return true
+	})
+	n.dataMu.RUnlock()
+	if promoted > 0 {
+		n.logger.Info("SyncReplicas", "promoted replicas of keys now owned", "keys", promoted)
+	}
+
+	set := n.replicaSet()
+	if len(set) == 0 {
+		return
+	}
+
+	// Push the owned keys in batches
+	batch := make(map[string]Versioned)
+	push := func() {
+		for _, addr := range set {
+			if err := n.transport.PushReplicas(addr, batch); err != nil {
+				n.logger.Error("SyncReplicas", "failed to push replicas", "keys", len(batch), "replica", addr, "err", err)
+			}
+		}
+		batch = make(map[string]Versioned)
+	}
+	n.rangeVersioned(&n.data, func(key string, value Versioned) bool {
+		if owns(key) {
+			batch[key] = value
+			if len(batch) == handoffBatchSize {
+				push()
+			}
+		}
+		return true
+	})
+	if len(batch) > 0 {
+		push()
+	}
+}
+
+// AcceptReplicas stores replicas pushed by their owner at the owner's version and expiry, each
+// held for replicaLease unless refreshed
+func (n *Node) AcceptReplicas(pairs map[string]Versioned) {
+	leaseEnds := time.Now().Add(replicaLease)
+	for key, value := range pairs {
+		stored := n.storedVersioned(value)
+		stored.leaseEnds = leaseEnds
+		n.replicas.Store(key, stored)
+	}
+}
+
+// DropReplicas deletes the replicas of keys deleted by their owner
+func (n *Node) DropReplicas(keys []string) {
+	for _, key := range keys {
+		n.replicas.Delete(key)
+	}
+}
+
+// Replica returns the locally held replica of the key with the owner's version
+func (n *Node) Replica(key string) (Versioned, bool) {
+	return n.loadVersioned(&n.replicas, key)
+}
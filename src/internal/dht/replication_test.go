@@ -0,0 +1,71 @@
+package dht
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestReplicasKeepTheKeyExpiry(t *testing.T) {
+	net := NewMemoryNetwork()
+	nodes := net.BuildRing(3, WithReplicationFactor(3), WithSuccessorListSize(2))
+	owner, successor := nodes[0], nodes[1]
+	ctx := context.Background()
+
+	// One key is repaired on a read, the other promoted by the replica sync
+	var keys []string
+	for i := 0; len(keys) < 2; i++ {
+		if key := fmt.Sprintf("key-%d", i); owner.owns(owner.ringId(key)) {
+			keys = append(keys, key)
+		}
+	}
+	expiries := make(map[string]time.Time)
+	for _, key := range keys {
+		if _, _, err := owner.Put(key, []byte("value"), time.Hour); err != nil {
+			t.Fatalf("Put(%q): %v", key, err)
+		}
+		value, _ := owner.loadVersioned(&owner.data, key)
+		expiries[key] = value.ExpiresAt
+		if replica, ok := successor.Replica(key); !ok || !replica.ExpiresAt.Equal(value.ExpiresAt) {
+			t.Fatalf("replica of %q expires at %v, want the owner's %v", key, replica.ExpiresAt, value.ExpiresAt)
+		}
+	}
+
+	net.Fail(owner.Address())
+	for range 3 {
+		for _, node := range nodes[1:] {
+			node.CheckPredecessor(ctx)
+			node.Stabilize(ctx)
+		}
+	}
+
+	if _, _, err := successor.Get(keys[0]); err != nil {
+		t.Fatalf("Get(%q) after the owner failed: %v", keys[0], err)
+	}
+	successor.syncReplicas()
+
+	for _, key := range keys {
+		value, ok := successor.loadVersioned(&successor.data, key)
+		if !ok {
+			t.Fatalf("%q is not stored on the new owner", key)
+		}
+		if !value.ExpiresAt.Equal(expiries[key]) {
+			t.Errorf("%q expires at %v on the new owner, want the original %v", key, value.ExpiresAt, expiries[key])
+		}
+	}
+}
+
+func TestReplicaLeaseEndsBeforeTheKeyExpires(t *testing.T) {
+	node := Create("node-0", WithLogger(quietLogger()))
+	node.AcceptReplicas(map[string]Versioned{"key": {Value: []byte("value"), Version: 1, ExpiresAt: time.Now().Add(time.Hour)}})
+
+	v, _ := node.replicas.Load("key")
+	stored := v.(*storedValue)
+	if stored.expired(time.Now()) {
+		t.Fatal("replica expired right after it was accepted")
+	}
+	if !stored.expired(time.Now().Add(replicaLease)) {
+		t.Error("replica outlived its lease")
+	}
+}
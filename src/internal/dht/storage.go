@@ -0,0 +1,313 @@
+package dht
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Expired keys are swept every expirySweepTicks maintenance ticks
+const expirySweepTicks = 5
+
+// storedValue is the value type of the data map, held by pointer so the map can compare and swap
+// values. Values are raw bytes, binary values are stored as given.
+type storedValue struct {
+	value     []byte
+	version   uint64    // number of the write on the key's owner, see Versioned
+	expiresAt time.Time // zero if the value never expires
+	leaseEnds time.Time // replicas only, dropped at this time unless refreshed by the owner
+	restored  bool      // loaded from disk on startup, replaced by a value handed off on the rejoin
+}
+
+// Versioned is a value with its version. The owner of a key numbers the writes of the key from 1
+// and pushes the version to the replicas with the value, so all copies agree on it. A key handed
+// off to a new owner keeps its version and expiry, so versions only grow across joins and leaves.
+// A deleted key starts again from 1 with its next write.
+type Versioned struct {
+	Value     []byte    `json:"value"` // base64 in JSON, so binary values survive the replica RPCs
+	Version   uint64    `json:"version"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"` // zero if the value never expires
+}
+
+// equal reports whether both have the same value and version
+func (v Versioned) equal(other Versioned) bool {
+	return v.Version == other.Version && bytes.Equal(v.Value, other.Value)
+}
+
+// newStoredValue returns the value to store at the version, expiring after ttl if ttl is positive
+// The value is encrypted if encryption at rest is enabled.
+func (n *Node) newStoredValue(value []byte, version uint64, ttl time.Duration) *storedValue {
+	if n.cipher != nil {
+		value = n.cipher.encrypt(value)
+	}
+	stored := &storedValue{value: value, version: version}
+	if ttl > 0 {
+		stored.expiresAt = time.Now().Add(ttl)
+	}
+	return stored
+}
+
+// clampTTL returns the ttl a written value is stored with, at most maxTTL if set. A value
+// without ttl, which would never expire, gets maxTTL too.
+func (n *Node) clampTTL(ttl time.Duration) time.Duration {
+	if n.maxTTL > 0 && (ttl <= 0 || ttl > n.maxTTL) {
+		return n.maxTTL
+	}
+	return ttl
+}
+
+// storedVersioned returns the value to store for a versioned value, keeping its version and expiry
+// Used for keys handed off by their previous owner and for replicas, which expire with the owner's key.
+func (n *Node) storedVersioned(v Versioned) *storedValue {
+	stored := n.newStoredValue(v.Value, v.Version, 0)
+	stored.expiresAt = v.ExpiresAt
+	return stored
+}
+
+// expired reports whether the value has expired, or its replica lease ended, at the given time
+func (v *storedValue) expired(now time.Time) bool {
+	if !v.leaseEnds.IsZero() && !now.Before(v.leaseEnds) {
+		return true
+	}
+	return !v.expiresAt.IsZero() && !now.Before(v.expiresAt)
+}
+
+// plaintext returns the plaintext of the stored value, false if it can't be decrypted
+func (n *Node) plaintext(key string, stored *storedValue) ([]byte, bool) {
+	if n.cipher == nil {
+		return stored.value, true
+	}
+	value, err := n.cipher.decrypt(stored.value)
+	if err != nil {
+		n.logger.Error("Storage", "failed to decrypt stored value", "key", key, "err", err)
+		return nil, false
+	}
+	return value, true
+}
+
+// load returns the locally stored value of the key, an expired value is treated as absent
+func (n *Node) load(key string) ([]byte, bool) {
+	v, ok := n.loadVersioned(&n.data, key)
+	return v.Value, ok
+}
+
+// loadVersioned returns the value of the key in the map with its version, an expired value is treated as absent
+func (n *Node) loadVersioned(m *sync.Map, key string) (Versioned, bool) {
+	v, ok := m.Load(key)
+	if !ok {
+		return Versioned{}, false
+	}
+	stored, ok := v.(*storedValue)
+	if !ok || stored.expired(time.Now()) {
+		return Versioned{}, false
+	}
+	value, ok := n.plaintext(key, stored)
+	if !ok {
+		return Versioned{}, false
+	}
+	return Versioned{Value: value, Version: stored.version, ExpiresAt: stored.expiresAt}, true
+}
+
+// checkCapacity returns ErrStorageFull if the node rejects writes when full and storing the value
+// would exceed the capacity: a new key at maxKeys keys, or any write growing the values past maxBytes.
+// The growth of an accepted write is reserved on the stored bytes with a compare-and-swap, so writes
+// racing the check, e.g. a read-repair, can't take the same room. The caller stores the value with
+// storeReserved and the reservation.
+func (n *Node) checkCapacity(key string, exists bool, stored *storedValue) (reserved int64, err error) {
+	if !n.rejectWhenFull {
+		return 0, nil
+	}
+	if !exists && n.full() {
+		return 0, fmt.Errorf("%w, the node stores its maximum of %d keys", ErrStorageFull, n.maxKeys)
+	}
+	if n.maxBytes == 0 {
+		return 0, nil
+	}
+	previous, _ := n.data.Load(key)
+	growth := storedLen(stored) - storedLen(previous)
+	for {
+		current := n.dataBytes.Load()
+		if total := current + growth; total > int64(n.maxBytes) {
+			return 0, fmt.Errorf("%w, the write would grow the stored values to %d of at most %d bytes", ErrStorageFull, total, n.maxBytes)
+		}
+		if n.dataBytes.CompareAndSwap(current, current+growth) {
+			return growth, nil
+		}
+	}
+}
+
+// full reports whether the node stores maxKeys keys or more. The count is kept on every write and
+// delete like the stored bytes, so the check doesn't scan the keys. Expired keys count until swept.
+func (n *Node) full() bool {
+	return n.maxKeys > 0 && n.dataKeys.Load() >= int64(n.maxKeys)
+}
+
+// StorageLimit returns the number of keys and of value bytes the node stores at capacity, 0 if
+// unbounded, and whether writes exceeding the capacity are rejected
+func (n *Node) StorageLimit() (maxKeys int, maxBytes int, rejectWhenFull bool) {
+	return n.maxKeys, n.maxBytes, n.rejectWhenFull
+}
+
+// StoredBytes returns the total length of the locally stored values, tracked on every write and
+// delete. Values are counted as held in memory, i.e. encrypted if encryption at rest is enabled.
+func (n *Node) StoredBytes() int64 {
+	return n.dataBytes.Load()
+}
+
+// storedLen returns the length of a value of the data map as held, 0 for none
+func storedLen(v any) int64 {
+	if stored, ok := v.(*storedValue); ok {
+		return int64(len(stored.value))
+	}
+	return 0
+}
+
+// RangeKeys calls f for every locally stored key that has not expired with its value, until f
+// returns false. Safe to call under concurrent writes, like sync.Map.Range a key written during
+// the iteration may or may not be visited, take a Snapshot for a point-in-time view. The value
+// is shared with the store and must not be modified.
+func (n *Node) RangeKeys(f func(key string, value []byte) bool) {
+	n.rangeVersioned(&n.data, func(key string, v Versioned) bool {
+		return f(key, v.Value)
+	})
+}
+
+// rangeVersioned calls f for every key in the map that has not expired with its value and
+// version, until f returns false
+func (n *Node) rangeVersioned(m *sync.Map, f func(key string, v Versioned) bool) {
+	now := time.Now()
+	m.Range(func(k, v any) bool {
+		key, ok := k.(string)
+		if !ok {
+			return true
+		}
+		stored, ok := v.(*storedValue)
+		if !ok || stored.expired(now) {
+			return true
+		}
+		value, ok := n.plaintext(key, stored)
+		if !ok {
+			return true
+		}
+		return f(key, Versioned{Value: value, Version: stored.version, ExpiresAt: stored.expiresAt})
+	})
+}
+
+// storeData stores the value of the key and logs the write if persistence is enabled, dataMu must be held shared
+func (n *Node) storeData(key string, stored *storedValue) {
+	n.storeReserved(key, stored, 0)
+}
+
+// storeReserved stores the value of the key like storeData, of the bytes the value adds the
+// reserved ones were already counted by checkCapacity
+func (n *Node) storeReserved(key string, stored *storedValue, reserved int64) {
+	previous, loaded := n.data.Swap(key, stored)
+	if !loaded {
+		n.dataKeys.Add(1)
+	}
+	n.dataBytes.Add(storedLen(stored) - storedLen(previous) - reserved)
+	n.persistPut(key, stored)
+}
+
+// loadOrStoreData stores the value of the key unless one is stored, like sync.Map.LoadOrStore,
+// and logs the write if persistence is enabled, dataMu must be held shared
+func (n *Node) loadOrStoreData(key string, stored *storedValue) (loaded bool) {
+	if _, loaded = n.data.LoadOrStore(key, stored); !loaded {
+		n.dataKeys.Add(1)
+		n.dataBytes.Add(storedLen(stored))
+		n.persistPut(key, stored)
+	}
+	return loaded
+}
+
+// adoptData stores a value handed off by the successor unless a value is stored, a value restored
+// from disk is replaced since the successor took the writes while this node was down. dataMu must be held shared.
+func (n *Node) adoptData(key string, stored *storedValue) {
+	for {
+		current, loaded := n.data.LoadOrStore(key, stored)
+		if !loaded {
+			n.dataKeys.Add(1)
+			n.dataBytes.Add(storedLen(stored))
+			n.persistPut(key, stored)
+			return
+		}
+		if existing, ok := current.(*storedValue); !ok || !existing.restored {
+			return
+		}
+		if n.data.CompareAndSwap(key, current, stored) {
+			n.dataBytes.Add(storedLen(stored) - storedLen(current))
+			n.persistPut(key, stored)
+			return
+		}
+	}
+}
+
+// deleteData deletes the key and logs the delete if persistence is enabled, dataMu must be held shared
+func (n *Node) deleteData(key string) (existed bool) {
+	previous, existed := n.data.LoadAndDelete(key)
+	if existed {
+		n.dataKeys.Add(-1)
+		n.dataBytes.Add(-storedLen(previous))
+		n.persistDelete(key)
+	}
+	return existed
+}
+
+// compareAndDelete deletes the key if its stored value is still the given value at the same version
+func (n *Node) compareAndDelete(key string, value Versioned) bool {
+	v, ok := n.data.Load(key)
+	if !ok {
+		return false
+	}
+	stored, ok := v.(*storedValue)
+	if !ok {
+		return false
+	}
+	if stored.version != value.Version {
+		return false
+	}
+	if current, ok := n.plaintext(key, stored); !ok || !bytes.Equal(current, value.Value) {
+		return false
+	}
+	if !n.data.CompareAndDelete(key, v) {
+		return false
+	}
+	n.dataKeys.Add(-1)
+	n.dataBytes.Add(-storedLen(v))
+	n.persistDelete(key)
+	return true
+}
+
+// sweepExpired evicts the expired keys from the data map and the replicas whose lease expired
+func (n *Node) sweepExpired() {
+
+	n.dataMu.RLock()
+	defer n.dataMu.RUnlock()
+
+	now := time.Now()
+	sweep := func(m *sync.Map) int {
+		evicted := 0
+		m.Range(func(k, v any) bool {
+			if stored, ok := v.(*storedValue); ok && stored.expired(now) {
+				// Only evict if not overwritten since the check
+				if m.CompareAndDelete(k, v) {
+					evicted++
+					if m == &n.data {
+						n.dataKeys.Add(-1)
+						n.dataBytes.Add(-storedLen(v))
+					}
+				}
+			}
+			return true
+		})
+		return evicted
+	}
+
+	if evicted := sweep(&n.data); evicted > 0 {
+		n.logger.Info("SweepExpired", "evicted expired keys", "evicted", evicted)
+	}
+	if evicted := sweep(&n.replicas); evicted > 0 {
+		n.logger.Info("SweepExpired", "dropped replicas with expired leases", "evicted", evicted)
+	}
+}
@@ -0,0 +1,136 @@
+package dht
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSnapshotUnderConcurrentPuts(t *testing.T) {
+	const writers, keys = 4, 2000
+	node := Create("node-0", WithLogger(quietLogger()))
+	names := make([][]string, writers)
+	for w := range writers {
+		names[w] = make([]string, keys)
+		for i := range keys {
+			names[w][i] = fmt.Sprintf("writer-%d-%d", w, i)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range keys {
+				if _, _, err := node.Put(names[w][i], []byte("v"), 0); err != nil {
+					t.Errorf("Put: %v", err)
+					return
+				}
+			}
+		}()
+	}
+
+	// Every writer stores its keys in order, a coherent snapshot holds a prefix of them
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for {
+		select {
+		case <-done:
+			if got := len(node.Snapshot()); got != writers*keys {
+				t.Errorf("snapshot after the writers finished holds %d keys, want %d", got, writers*keys)
+			}
+			return
+		default:
+		}
+
+		snapshot := node.Snapshot()
+		for w := range writers {
+			stored := 0
+			for i := range keys {
+				if _, ok := snapshot[names[w][i]]; !ok {
+					break
+				}
+				stored++
+			}
+			for i := stored; i < keys; i++ {
+				if _, ok := snapshot[names[w][i]]; ok {
+					t.Fatalf("snapshot holds key %d of writer %d without its key %d", i, w, stored)
+				}
+			}
+		}
+
+		visited := 0
+		node.RangeKeys(func(key string, value []byte) bool {
+			visited++
+			return true
+		})
+		if visited < len(snapshot) {
+			t.Fatalf("RangeKeys visited %d keys after a snapshot of %d", visited, len(snapshot))
+		}
+
+		// Snapshots block the writers, leave them room to make progress
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestKeyCountFollowsWritesAndDeletes(t *testing.T) {
+	node := Create("node-0", WithLogger(quietLogger()), WithMaxKeys(10, true))
+	for _, key := range []string{"a", "b", "c", "a"} {
+		if _, _, err := node.Put(key, []byte("v"), 0); err != nil {
+			t.Fatalf("Put(%q): %v", key, err)
+		}
+	}
+	if _, _, err := node.Put("expiring", []byte("v"), time.Millisecond); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, err := node.Delete("b"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	node.sweepExpired()
+
+	if got, want := node.dataKeys.Load(), int64(node.KeyCount()); got != want || got != 2 {
+		t.Errorf("key count = %d with %d keys stored, want 2", got, want)
+	}
+}
+
+func TestMaxBytesUnderConcurrentPuts(t *testing.T) {
+	const maxBytes, valueLen, writers, keys = 1000, 10, 8, 50
+	node := Create("node-0", WithLogger(quietLogger()), WithMaxBytes(maxBytes), WithMaxKeys(0, true))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	stored := 0
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range keys {
+				_, _, err := node.Put(fmt.Sprintf("writer-%d-%d", w, i), make([]byte, valueLen), 0)
+				switch {
+				case err == nil:
+					mu.Lock()
+					stored++
+					mu.Unlock()
+				case !errors.Is(err, ErrStorageFull):
+					t.Errorf("Put: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stored != maxBytes/valueLen {
+		t.Errorf("%d puts stored, want %d", stored, maxBytes/valueLen)
+	}
+	if got := node.StoredBytes(); got != int64(stored*valueLen) || got > maxBytes {
+		t.Errorf("stored bytes = %d after %d puts of %d bytes, at most %d", got, stored, valueLen, maxBytes)
+	}
+}
@@ -0,0 +1,92 @@
+package dht
+
+import (
+	"slices"
+
+	"assignment/internal/metrics"
+)
+
+// Default number of entries in the successor list, override with WithSuccessorListSize
+const DefaultSuccessorListSize = 3
+
+// SuccessorList returns the addresses of the known successors in ring order, starting with the immediate successor
+func (n *Node) SuccessorList() []string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	addresses := make([]string, 0, len(n.successorList))
+	for _, s := range n.successorList {
+		addresses = append(addresses, s.address)
+	}
+	return addresses
+}
+
+// updateSuccessorList rebuilds the successor list from the successor and the successor's own list
+func (n *Node) updateSuccessorList(successorAddr string) {
+
+	if successorAddr == n.Address() {
+		// Alone in the ring, no backups
+		n.setSuccessorList(nil)
+		return
+	}
+
+	successors, err := n.transport.GetSuccessorList(successorAddr)
+	if err != nil {
+		// Keep the backups we already know of behind the successor
+		metrics.FailedRPCs.WithLabelValues("GetSuccessorList").Inc()
+		n.logger.Warn("Stabilize", "failed to get successor list", "successor", successorAddr, "err", err)
+		successors = n.SuccessorList()
+	}
+
+	// In a small ring the successor's list wraps around past this node, the entries after it
+	// come before the successor and are dropped
+	if i := slices.Index(successors, n.Address()); i >= 0 {
+		successors = successors[:i]
+	}
+
+	n.setSuccessorList(append([]string{successorAddr}, successors...))
+}
+
+// setSuccessorList stores the list deduplicated, without self, in ring order from this node and
+// truncated to the configured size. A stale list from the successor can't put a farther node first.
+func (n *Node) setSuccessorList(addresses []string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	seen := make(map[string]bool)
+	list := make([]node, 0, len(addresses))
+
+	for _, addr := range addresses {
+		if addr == "" || addr == n.address || seen[addr] {
+			continue
+		}
+		list = append(list, node{
+			id:      n.ringId(addr),
+			address: addr,
+		})
+		seen[addr] = true
+	}
+
+	// Stable, so nodes sharing an id keep the successor's order
+	slices.SortStableFunc(list, func(a, b node) int {
+		return ClockwiseDistance(n.id, a.id, n.idSpaceSize) - ClockwiseDistance(n.id, b.id, n.idSpaceSize)
+	})
+	if len(list) > n.successorListSize {
+		list = list[:n.successorListSize]
+	}
+
+	n.successorList = list
+}
+
+// inSuccessorList returns true if the address is one of the backup successors
+func (n *Node) inSuccessorList(addr string) bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	for _, s := range n.successorList {
+		if s.address == addr {
+			return true
+		}
+	}
+	return false
+}
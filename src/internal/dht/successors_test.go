@@ -0,0 +1,53 @@
+package dht
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestLookupFailsOverToBackupSuccessors(t *testing.T) {
+	net := NewMemoryNetwork()
+	nodes := net.BuildRing(6)
+	ctx := context.Background()
+	from, backup := nodes[0], nodes[3]
+
+	if got, want := from.SuccessorList(), []string{nodes[1].Address(), nodes[2].Address(), backup.Address()}; !slices.Equal(got, want) {
+		t.Fatalf("successor list = %v, want %v", got, want)
+	}
+
+	// Both the successor and the first backup die between two maintenance rounds
+	net.Fail(nodes[1].Address())
+	net.Fail(nodes[2].Address())
+
+	// Lookups past the dead nodes route around them
+	for _, tt := range []struct {
+		keyId int
+		want  *Node
+	}{
+		{(nodes[2].Id() + 1) % ID_SPACE_SIZE, backup},
+		{backup.Id(), backup},
+		{nodes[4].Id(), nodes[4]},
+		{nodes[5].Id(), nodes[5]},
+	} {
+		got, err := from.FindSuccessor(ctx, tt.keyId)
+		if err != nil || got != tt.want.Address() {
+			t.Errorf("FindSuccessor(%d) with dead successors = %s, %v, want %s", tt.keyId, got, err, tt.want.Address())
+		}
+	}
+
+	// One Stabilize moves on to the live backup, neither collapsing to a ring of its own nor
+	// adopting the dead node the backup still has as its predecessor
+	from.Stabilize(ctx)
+	if _, successor := from.Successor(); successor != backup.Address() {
+		t.Fatalf("successor after Stabilize = %s, want the live backup %s", successor, backup.Address())
+	}
+	if list := from.SuccessorList(); len(list) == 0 || list[0] != backup.Address() || slices.Contains(list, nodes[1].Address()) {
+		t.Errorf("successor list after Stabilize = %v, want it to start at %s without the dead successor", list, backup.Address())
+	}
+	for _, dead := range nodes[1:3] {
+		if got, err := from.FindSuccessor(ctx, dead.Id()); err != nil || got != backup.Address() {
+			t.Errorf("FindSuccessor(%d) of a dead node = %s, %v, want the backup %s", dead.Id(), got, err, backup.Address())
+		}
+	}
+}
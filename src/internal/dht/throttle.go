@@ -0,0 +1,93 @@
+package dht
+
+import (
+	"sync"
+	"time"
+
+	"assignment/internal/metrics"
+)
+
+// Maintenance rounds over which the outcome of the ring RPCs is judged before the backoff is adjusted
+const throttleWindowRounds = 5
+
+// Fewest RPCs in a window for its overload ratio to count, a handful of failures to one dead node is no storm
+const throttleMinSamples = 10
+
+// Share of overloaded RPCs in a window above which the maintenance interval is doubled
+const throttleOverloadRatio = 0.2
+
+// Default largest multiple of the maintenance interval the backoff stretches rounds to,
+// override with WithMaxMaintenanceBackoff
+const DefaultMaxMaintenanceBackoff = 8
+
+// maintenanceThrottle spaces the maintenance rounds out while peers appear overloaded, e.g. after
+// a mass join where every new node stabilizes and notifies the seed region at once. The transport
+// reports the outcome of every outbound ring RPC, a window with many 503s or timeouts doubles the
+// interval between rounds up to maxBackoff times the base, every healthy window shortens it by one
+// base interval again. This bounds the per-node outbound maintenance RPC rate under overload.
+type maintenanceThrottle struct {
+	mu         sync.Mutex
+	total      int
+	overloaded int
+	rounds     int
+	backoff    int // current multiple of the base interval, 1 if not throttled
+	maxBackoff int // 1 disables the throttle
+}
+
+// observe records the outcome of one outbound ring RPC
+func (t *maintenanceThrottle) observe(overloaded bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.total++
+	if overloaded {
+		t.overloaded++
+	}
+}
+
+// round is called after every maintenance round and returns the multiple of the base interval
+// until the next one, adjusted at the end of every window
+func (t *maintenanceThrottle) round() (backoff int, changed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.backoff == 0 {
+		t.backoff = 1
+	}
+	t.rounds++
+	if t.rounds < throttleWindowRounds || t.maxBackoff <= 1 {
+		return t.backoff, false
+	}
+
+	previous := t.backoff
+	if t.total >= throttleMinSamples && float64(t.overloaded) > throttleOverloadRatio*float64(t.total) {
+		t.backoff = min(t.backoff*2, t.maxBackoff)
+	} else if t.backoff > 1 {
+		t.backoff--
+	}
+	t.total, t.overloaded, t.rounds = 0, 0, 0
+
+	metrics.MaintenanceBackoff.Set(float64(t.backoff))
+	return t.backoff, t.backoff != previous
+}
+
+// ObserveRPC records the outcome of an outbound ring RPC for the maintenance throttle,
+// overloaded if the peer answered 503 or the call timed out
+func (n *Node) ObserveRPC(overloaded bool) {
+	n.throttle.observe(overloaded)
+}
+
+// MaintenanceBackoff returns the current multiple of the maintenance interval between rounds, 1 if not throttled
+func (n *Node) MaintenanceBackoff() int {
+	n.throttle.mu.Lock()
+	defer n.throttle.mu.Unlock()
+	return max(n.throttle.backoff, 1)
+}
+
+// throttledInterval returns the interval until the next maintenance round and whether it changed
+func (n *Node) throttledInterval(base time.Duration) (time.Duration, bool) {
+	backoff, changed := n.throttle.round()
+	if changed {
+		n.logger.Warn("Maintenance", "adjusted maintenance backoff after peer overload signals", "backoff", backoff, "interval", base*time.Duration(backoff))
+	}
+	return base * time.Duration(backoff), changed
+}
@@ -0,0 +1,42 @@
+package dht
+
+import (
+	"fmt"
+	"time"
+)
+
+// Timing is the maintenance interval and the RPC timeouts, shared by the node and the transport so
+// they can be validated together. Raise them for high-latency deployments to avoid false failure detection.
+type Timing struct {
+	MaintenanceInterval time.Duration // base interval of the maintenance loop, up to 50ms jitter is added
+	FastTimeout         time.Duration // timeout of ring RPCs, e.g. ping, lookups and link updates
+	SlowTimeout         time.Duration // timeout of data transfers, e.g. handoff and replication
+	ForwardTimeout      time.Duration // timeout of a forwarded storage request
+}
+
+// Maintenance rounds whose predecessor checks may be in flight at once, each round checks the
+// predecessor in the background and the check of a dead predecessor only returns at the fast timeout
+const maxOverlappingRounds = 3
+
+// DefaultTiming returns the timing tuned for a LAN
+func DefaultTiming() Timing {
+	return Timing{
+		MaintenanceInterval: 200 * time.Millisecond,
+		FastTimeout:         500 * time.Millisecond,
+		SlowTimeout:         2 * time.Second,
+		ForwardTimeout:      5 * time.Second,
+	}
+}
+
+// Validate checks that every duration is positive and that a ring RPC times out before more than
+// maxOverlappingRounds maintenance rounds started, e.g. the 500ms fast timeout spans the checks of
+// three 200ms rounds
+func (t Timing) Validate() error {
+	if t.MaintenanceInterval <= 0 || t.FastTimeout <= 0 || t.SlowTimeout <= 0 || t.ForwardTimeout <= 0 {
+		return fmt.Errorf("maintenance interval and timeouts must be positive")
+	}
+	if t.MaintenanceInterval*maxOverlappingRounds < t.FastTimeout {
+		return fmt.Errorf("maintenance interval %s is smaller than a third of the fast rpc timeout %s, more than %d rounds would overlap", t.MaintenanceInterval, t.FastTimeout, maxOverlappingRounds)
+	}
+	return nil
+}
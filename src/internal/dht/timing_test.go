@@ -0,0 +1,53 @@
+package dht
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDefaultTimingIsValid(t *testing.T) {
+	timing := DefaultTiming()
+	if err := timing.Validate(); err != nil {
+		t.Fatalf("DefaultTiming().Validate() = %v, want nil", err)
+	}
+	if timing.MaintenanceInterval != 200*time.Millisecond || timing.FastTimeout != 500*time.Millisecond {
+		t.Errorf("default maintenance interval and fast timeout = %v, %v, want 200ms, 500ms", timing.MaintenanceInterval, timing.FastTimeout)
+	}
+}
+
+func TestTimingValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Timing)
+		wantErr bool
+	}{
+		{"defaults", func(*Timing) {}, false},
+		{"longer forward timeout", func(tm *Timing) { tm.ForwardTimeout = 10 * time.Second }, false},
+		{"interval equal to the fast timeout", func(tm *Timing) { tm.MaintenanceInterval = tm.FastTimeout }, false},
+		{"fast timeout of three intervals", func(tm *Timing) { tm.FastTimeout = 3 * tm.MaintenanceInterval }, false},
+		{"fast timeout spans more than three intervals", func(tm *Timing) { tm.MaintenanceInterval = tm.FastTimeout / 4 }, true},
+		{"longer fast timeout", func(tm *Timing) { tm.FastTimeout = 4 * tm.MaintenanceInterval }, true},
+		{"zero interval", func(tm *Timing) { tm.MaintenanceInterval = 0 }, true},
+		{"negative slow timeout", func(tm *Timing) { tm.SlowTimeout = -time.Second }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timing := DefaultTiming()
+			tt.modify(&timing)
+			if err := timing.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithTimingIgnoresInvalidTiming(t *testing.T) {
+	timing := DefaultTiming()
+	timing.MaintenanceInterval = timing.FastTimeout / 4
+
+	node := Create("node-0", WithLogger(quietLogger()), WithTiming(timing))
+	if node.tickInterval != DefaultTiming().MaintenanceInterval {
+		t.Errorf("maintenance interval = %v, want the default %v", node.tickInterval, DefaultTiming().MaintenanceInterval)
+	}
+}
@@ -1,15 +1,38 @@
 package dht
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Transport interface {
 	// Basic DHT RPCs
-	CheckAlive(targetAddr string) (ok bool, err error)                        // RPC to check if the node at the given address is alive
-	GetPredecessor(targetAddr string) (predecessor string, err error)         // RPC to get the predecessor of the node
-	Notify(targetAddr string, predecessor string) error                       // RPC to notify the node at the given address that it might have a new predecessor
-	SetPredecessor(targetAddr string, predecessor string) error               // RPC to instruct the node at the given address that has a new predecessor
-	SetSuccessor(targetAddr string, successor string) error                   // RPC to instruct the node at the given address that has a new successor
-	FindSuccessor(targetAddr string, keyId int) (successor string, err error) // RPC to find the successor of the key
+	// The ctx of the link maintenance RPCs cancels the call in flight, e.g. on shutdown
+	CheckAlive(ctx context.Context, targetAddr string) (ok bool, err error)                          // RPC to check if the node at the given address is alive
+	CheckAliveExpecting(ctx context.Context, targetAddr string, expectedId int) (ok bool, err error) // RPC to check if the node at the given address is alive and has the expected id
+	GetPredecessor(ctx context.Context, targetAddr string) (predecessor string, err error)           // RPC to get the predecessor of the node
+	Notify(ctx context.Context, targetAddr string, predecessor string) error                         // RPC to notify the node at the given address that it might have a new predecessor
+	SetPredecessor(ctx context.Context, targetAddr string, predecessor string) error                 // RPC to instruct the node at the given address that has a new predecessor, sent by a leaving node
+	SetSuccessor(ctx context.Context, targetAddr string, successor string) error                     // RPC to instruct the node at the given address that has a new successor
+	FindSuccessor(ctx context.Context, targetAddr string, keyId int) (successor string, err error)   // RPC to find the successor of the key
+	GetSuccessorList(targetAddr string) (successors []string, err error)                             // RPC to get the successor list of the node
+
+	// Data handoff RPCs
+	GetHandoffBatch(targetAddr string, fromId int, toId int, after string, limit int) (pairs map[string]Versioned, more bool, err error) // RPC to get a batch of keys in (fromId, toId] with their versions
+	StoreKey(targetAddr string, key string, value []byte) error                                                                          // RPC to store the key-value pair on the node, forwarded if not owned there
+	PushHandoff(targetAddr string, pairs map[string]Versioned) error                                                                     // RPC to hand off keys to the node when leaving
+	ConfirmHandoff(targetAddr string, pairs map[string]Versioned) error                                                                  // RPC to confirm the keys were stored so the old owner deletes them
+
+	// Iterative lookup RPCs
+	ClosestPreceding(targetAddr string, keyId int) (closest string, successor string, err error) // RPC to get the node's closest preceding node of the key and its successor
+
+	// Replication RPCs
+	PushReplicas(targetAddr string, pairs map[string]Versioned) error                  // RPC to store replicas of the owner's keys on the node
+	GetReplica(targetAddr string, key string) (value Versioned, found bool, err error) // RPC to get the node's replica of the key
+	DeleteReplicas(targetAddr string, keys []string) error                             // RPC to delete the node's replicas of the keys
+
+	// Latency of the RPCs to a node, see PeerLatencies
+	Latency(targetAddr string) (latency time.Duration, known bool) // Returns the recent RPC latency to the node, known is false before any RPC
 
 	// Inactive handling
 	IsInactive() bool
@@ -26,13 +49,57 @@ type INode interface {
 	Predecessor() (id int, address string) // Returns the id and network address of the predecessor
 	String() string                        // Returns a string representation of the node
 	FingerTable() []string                 // Returns the finger table of the node
+	SuccessorList() []string               // Returns the successor list of the node in ring order
+	Epoch() uint64                         // Returns the topology epoch of the node
+	Events() *EventBus                     // Returns the bus the node publishes its membership changes to
 
 	// RPCs
-	Notify(predecessor string)                                    // RPC to notify the node that it might have a new predecessor
-	SetPredecessor(predecessor string)                            // RPC to instruct the node that has a new predecessor
-	SetSuccessor(successor string)                                // RPC to instruct the node that has a new successor
-	FindSuccessor(keyId int) (successor string, err error)        // RPC to find the successor of the key
-	Get(key string) (value string, nextAddress string, err error) // RPC to get the value of the key
-	Put(key string, value string) (nextAddress string)            // RPC to put the key-value pair into the ring
-	Leave() error                                                 // RPC to leave the ring and return to starting state
+	Notify(predecessor string)                                                                                                         // RPC to notify the node that it might have a new predecessor
+	SetPredecessor(predecessor string, force bool)                                                                                     // RPC to instruct the node that has a new predecessor, only accepted if closer unless forced
+	SetSuccessor(successor string)                                                                                                     // RPC to instruct the node that has a new successor
+	SetLinks(successor string, predecessor string)                                                                                     // Sets the successor and predecessor together without checks, for test harnesses
+	FindSuccessor(ctx context.Context, keyId int) (successor string, err error)                                                        // RPC to find the successor of the key, cancelled with ctx
+	NextHop(key string) (nextAddress string)                                                                                           // Returns the address to forward a request for the key to, empty if owned
+	Route(ctx context.Context, key string) (Route, error)                                                                              // Returns where a storage request for the key would go, read-only
+	Get(key string) (value Versioned, nextAddress string, err error)                                                                   // RPC to get the value of the key
+	GetConsistent(key string, level Consistency) (value Versioned, nextAddress string, err error)                                      // RPC to get the value of the key agreed on by the copies the level requires
+	Put(key string, value []byte, ttl time.Duration) (version uint64, nextAddress string, err error)                                   // RPC to put the key-value pair into the ring
+	CompareAndPut(key string, expected []byte, value []byte, ttl time.Duration) (version uint64, nextAddress string, err error)        // RPC to put the pair only if the key currently holds the expected value
+	CompareVersionAndPut(key string, expected uint64, value []byte, ttl time.Duration) (version uint64, nextAddress string, err error) // RPC to put the pair only if the key's value is at the expected version
+	Delete(key string) (nextAddress string, err error)                                                                                 // RPC to delete the key from the ring
+	Dump(consistent bool) map[string][]byte                                                                                            // Returns a copy of the locally stored key-value pairs
+	Snapshot() map[string][]byte                                                                                                       // Returns a point-in-time copy of the locally stored pairs, writes are blocked while copying
+	RangeKeys(f func(key string, value []byte) bool)                                                                                   // Calls f for every locally stored pair until it returns false, weakly consistent
+	KeyCount() int                                                                                                                     // Returns the number of locally stored keys
+	DataSize() (keys int, bytes int)                                                                                                   // Returns the number of locally stored keys and the total length of their values
+	OwnedFraction() (fraction float64, known bool)                                                                                     // Returns the share of the identifier space the node owns, unknown without predecessor unless alone
+	LocalKeys() []string                                                                                                               // Returns the locally stored keys
+	PrefixScan(prefix string, limit int) map[string][]byte                                                                             // Returns the locally stored pairs whose key has the prefix, the limit first if positive
+	OwnedRange() (from int, to int)                                                                                                    // Returns the key-id interval (from, to] the node owns
+	StorageLimit() (maxKeys int, maxBytes int, rejectWhenFull bool)                                                                    // Returns the key and value byte capacity, 0 if unbounded, and whether writes exceeding it are rejected
+	StoredBytes() int64                                                                                                                // Returns the total length of the locally stored values
+	HotKeys(top int) []KeyAccess                                                                                                       // Returns the access counts of the owned keys, most accessed first
+	Join(successor string) error                                                                                                       // Links the node in front of its successor and pulls the keys it now owns
+	SetNetwork(addresses []string) error                                                                                               // Links the node into the ring of the addresses, refused without any change if the list is invalid
+	Leave() (LeaveResult, error)                                                                                                       // RPC to leave the ring and return to starting state
+
+	// Iterative lookup
+	ClosestPreceding(keyId int) (closest string, successor string) // Returns the closest preceding node of the key and the successor
+	FixAllFingers(ctx context.Context) (updated int, failed int)   // Rebuilds the whole finger table in one pass
+	VerifyFingers(ctx context.Context) []FingerMismatch            // Returns the finger entries differing from a fresh lookup, read-only
+
+	// Maintenance throttling
+	ObserveRPC(overloaded bool) // Records the outcome of an outbound ring RPC, overloaded on 503 or timeout
+	MaintenanceBackoff() int    // Returns the current multiple of the maintenance interval between rounds
+
+	// Replication
+	AcceptReplicas(pairs map[string]Versioned)        // Stores replicas pushed by their owner
+	Replica(key string) (value Versioned, found bool) // Returns the locally held replica of the key with its version
+	DropReplicas(keys []string)                       // Deletes the replicas of keys deleted by their owner
+
+	// Data handoff
+	HandoffBatch(fromId int, toId int, after string, limit int) (pairs map[string]Versioned, more bool) // Returns a batch of local keys in (fromId, toId] with their versions
+	Repair() (moved int, failed int)                                                                    // Moves local keys this node does not own to their owner
+	AcceptHandoff(pairs map[string]Versioned)                                                           // Stores keys handed off by a leaving predecessor
+	ConfirmHandoff(pairs map[string]Versioned) (deleted int)                                            // Deletes handed off keys whose values are unchanged
 }
@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// Logger logs messages of an operation with key-value pairs, e.g.
+// logger.Warn("Stabilize", "failed to get predecessor", "candidate", addr, "err", err)
+type Logger interface {
+	Info(op string, msg string, kv ...any)
+	Warn(op string, msg string, kv ...any)
+	Error(op string, msg string, kv ...any)
+
+	// WithNode returns a logger that identifies the node on every line, if the backend supports it
+	WithNode(id int, address string) Logger
+}
+
+// Backends selectable with New
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// New returns a logger with the given backend writing to out
+func New(format string, out io.Writer) (Logger, error) {
+	switch format {
+	case FormatText:
+		return NewText(log.New(out, "", log.LstdFlags|log.Lshortfile)), nil
+	case FormatJSON:
+		return NewJSON(out), nil
+	default:
+		return nil, fmt.Errorf("unknown log format '%s', must be '%s' or '%s'", format, FormatText, FormatJSON)
+	}
+}
+
+// ---- Text backend ----
+
+// textLogger writes free-text lines through the standard log package, the default backend
+type textLogger struct {
+	logger *log.Logger
+}
+
+// NewText returns a text logger writing to the given log.Logger, nil uses the standard logger
+func NewText(logger *log.Logger) Logger {
+	return &textLogger{logger: logger}
+}
+
+func (l *textLogger) Info(op string, msg string, kv ...any) {
+	l.output(op+": ", msg, kv)
+}
+
+func (l *textLogger) Warn(op string, msg string, kv ...any) {
+	l.output(op+" WARNING: ", msg, kv)
+}
+
+func (l *textLogger) Error(op string, msg string, kv ...any) {
+	l.output(op+" ERROR: ", msg, kv)
+}
+
+// WithNode returns the logger itself, every node has its own log file
+func (l *textLogger) WithNode(id int, address string) Logger {
+	return l
+}
+
+// output writes "<prefix><msg> key=value ..." with the caller's file and line
+func (l *textLogger) output(prefix string, msg string, kv []any) {
+	var b strings.Builder
+	b.WriteString(prefix)
+	b.WriteString(msg)
+	for i := 0; i < len(kv); i += 2 {
+		if i+1 < len(kv) {
+			fmt.Fprintf(&b, " %v=%v", kv[i], kv[i+1])
+		} else {
+			fmt.Fprintf(&b, " %v", kv[i])
+		}
+	}
+
+	// Skip output and the level method to report the caller
+	const calldepth = 3
+	if l.logger == nil {
+		_ = log.Output(calldepth, b.String())
+		return
+	}
+	_ = l.logger.Output(calldepth, b.String())
+}
+
+// ---- JSON backend ----
+
+// jsonLogger writes one JSON object per line with the level, time, op, message and key-value pairs
+type jsonLogger struct {
+	logger *slog.Logger
+}
+
+// NewJSON returns a JSON logger writing to out
+func NewJSON(out io.Writer) Logger {
+	return &jsonLogger{logger: slog.New(slog.NewJSONHandler(out, nil))}
+}
+
+func (l *jsonLogger) Info(op string, msg string, kv ...any) {
+	l.logger.Info(msg, append([]any{"op", op}, kv...)...)
+}
+
+func (l *jsonLogger) Warn(op string, msg string, kv ...any) {
+	l.logger.Warn(msg, append([]any{"op", op}, kv...)...)
+}
+
+func (l *jsonLogger) Error(op string, msg string, kv ...any) {
+	l.logger.Error(msg, append([]any{"op", op}, kv...)...)
+}
+
+// WithNode adds the node id and address to every line
+func (l *jsonLogger) WithNode(id int, address string) Logger {
+	return &jsonLogger{logger: l.logger.With("node_id", id, "address", address)}
+}
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Process-wide metrics, registered once with the default registry and served on "/metrics"
+var (
+	// StorageRequests counts the storage requests handled by this node, by method
+	StorageRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dht_storage_requests_total",
+		Help: "Storage requests handled by this node, by method.",
+	}, []string{"method"})
+
+	// ForwardedRequests counts the storage requests forwarded to another node
+	ForwardedRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "dht_forwarded_requests_total",
+		Help: "Storage requests forwarded to another node.",
+	})
+
+	// RedirectedRequests counts the storage requests redirected to the next hop instead of forwarded
+	RedirectedRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "dht_redirected_requests_total",
+		Help: "Storage requests answered with a redirect to the next hop.",
+	})
+
+	// StabilizeRounds counts the stabilize rounds run by the maintenance loop
+	StabilizeRounds = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "dht_stabilize_rounds_total",
+		Help: "Stabilize rounds run.",
+	})
+
+	// FingerFixes counts the finger fixes, by whether the entry changed
+	FingerFixes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dht_finger_fixes_total",
+		Help: "Finger fixes run, by whether the entry was updated.",
+	}, []string{"updated"})
+
+	// FailedRPCs counts the failed RPCs to other nodes, by RPC
+	FailedRPCs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dht_failed_rpcs_total",
+		Help: "Failed RPCs to other nodes, by RPC.",
+	}, []string{"rpc"})
+
+	// ShedRequests counts the client requests refused by load shedding
+	ShedRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "dht_shed_requests_total",
+		Help: "Client requests refused with 503 by load shedding.",
+	})
+
+	// MaintenanceBackoff is the current multiple of the maintenance interval between rounds
+	MaintenanceBackoff = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "dht_maintenance_backoff",
+		Help: "Multiple of the maintenance interval between rounds, above 1 while peers are overloaded.",
+	})
+
+	// RingRPCs counts the outbound ring RPCs, by whether the peer appeared overloaded
+	RingRPCs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dht_ring_rpcs_total",
+		Help: "Outbound ring RPCs, by whether the peer answered 503 or timed out.",
+	}, []string{"overloaded"})
+
+	// OutboundConnections counts the connections dialed to other nodes, pooled connections are reused
+	OutboundConnections = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "dht_outbound_connections_total",
+		Help: "Connections dialed to other nodes, a request on a pooled connection dials none.",
+	})
+
+	// FindSuccessorSeconds observes the latency of successor lookups
+	FindSuccessorSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "dht_find_successor_seconds",
+		Help:    "Latency of FindSuccessor lookups in seconds.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+	})
+)
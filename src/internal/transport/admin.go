@@ -0,0 +1,49 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// adminLinks is the JSON body of a "/admin/links" request and response
+type adminLinks struct {
+	Successor   string `json:"successor"`
+	Predecessor string `json:"predecessor"`
+}
+
+// handleAdminLinks handles POST requests to the "/admin/links" path, only registered with -enable-admin
+// Sets the successor and predecessor of the node at once, without the Notify and ownership checks
+// of the ring RPCs, so a test harness can wedge the node into a broken topology and watch
+// maintenance repair it. An empty predecessor clears it. Answers with the links now set.
+func (t *HTTPTransport) handleAdminLinks(w http.ResponseWriter, r *http.Request) {
+
+	node := t.vnode(r.Context())
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var links adminLinks
+	if err := json.NewDecoder(r.Body).Decode(&links); err != nil {
+		http.Error(w, fmt.Sprintf("invalid links body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if links.Successor == "" {
+		http.Error(w, "successor is required, use the node's own address for a ring of its own", http.StatusBadRequest)
+		return
+	}
+
+	t.logger.Warn("AdminLinks", "forcing links", "successor", links.Successor, "predecessor", links.Predecessor, "remote", r.RemoteAddr)
+	node.SetLinks(links.Successor, links.Predecessor)
+
+	_, successorAddr := node.Successor()
+	_, predecessorAddr := node.Predecessor()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(adminLinks{Successor: successorAddr, Predecessor: predecessorAddr}); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode links: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
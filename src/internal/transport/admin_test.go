@@ -0,0 +1,75 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"assignment/internal/dht"
+)
+
+func TestAdminLinksWedgeIsHealed(t *testing.T) {
+	nodes := dht.BuildRing(4)
+	wedged := nodes[1]
+	tr := newTestTransport(t, wedged, WithAdmin(true))
+	ctx := context.Background()
+
+	// Skip the successor and forget the predecessor
+	body := []byte(`{"successor": "` + nodes[3].Address() + `", "predecessor": ""}`)
+	w := serve(tr, http.MethodPost, "/admin/links", body, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /admin/links: status %d: %s", w.Code, w.Body)
+	}
+	var links adminLinks
+	if err := json.NewDecoder(w.Body).Decode(&links); err != nil {
+		t.Fatalf("failed to decode links: %v", err)
+	}
+	if links.Successor != nodes[3].Address() || links.Predecessor != "" {
+		t.Fatalf("links after the wedge = %+v, want successor %s and no predecessor", links, nodes[3].Address())
+	}
+
+	for range 3 {
+		for _, node := range nodes {
+			node.CheckPredecessor(ctx)
+			node.Stabilize(ctx)
+		}
+	}
+
+	for i, node := range nodes {
+		next, previous := nodes[(i+1)%len(nodes)], nodes[(i+len(nodes)-1)%len(nodes)]
+		if _, successor := node.Successor(); successor != next.Address() {
+			t.Errorf("successor of %s = %q, want %s", node.Address(), successor, next.Address())
+		}
+		if _, predecessor := node.Predecessor(); predecessor != previous.Address() {
+			t.Errorf("predecessor of %s = %q, want %s", node.Address(), predecessor, previous.Address())
+		}
+	}
+}
+
+func TestAdminLinksRequests(t *testing.T) {
+	node := dht.Create("127.0.0.1:1", dht.WithLogger(quietLogger()))
+
+	tests := []struct {
+		name   string
+		admin  bool
+		method string
+		body   string
+		want   int
+	}{
+		{"disabled", false, http.MethodPost, `{"successor": "127.0.0.1:1"}`, http.StatusNotFound},
+		{"get", true, http.MethodGet, "", http.StatusMethodNotAllowed},
+		{"no successor", true, http.MethodPost, `{"predecessor": "127.0.0.1:2"}`, http.StatusBadRequest},
+		{"invalid body", true, http.MethodPost, `{`, http.StatusBadRequest},
+		{"ring of its own", true, http.MethodPost, `{"successor": "127.0.0.1:1"}`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := newTestTransport(t, node, WithAdmin(tt.admin))
+			if w := serve(tr, tt.method, "/admin/links", []byte(tt.body), nil); w.Code != tt.want {
+				t.Errorf("%s /admin/links: status %d, want %d: %s", tt.method, w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Header counting the hops a forwarded batch has taken
+const batchHopsHeader = "X-DHT-Batch-Hops"
+
+// A batch forwarded more often than this is failed, the ring is likely mid-stabilization
+const maxBatchHops = 32
+
+// Per-key status of a stored key in a batch response, otherwise the error
+const batchStored = "stored"
+
+// handleBatch handles POST requests to the "/batch" path
+// Stores a JSON object of key-value pairs with base64 values, forwarding each group of remote keys
+// to its next hop in a single request. Returns a JSON object with the per-key status, "stored" or the error.
+func (t *HTTPTransport) handleBatch(w http.ResponseWriter, r *http.Request) {
+
+	node := t.vnode(r.Context())
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if t.warming.Load() {
+		refuseRequest(w, r, reasonWarming)
+		return
+	}
+	if t.draining.Load() {
+		refuseRequest(w, r, reasonQuiesced)
+		return
+	}
+
+	var pairs map[string][]byte
+	if err := json.NewDecoder(r.Body).Decode(&pairs); err != nil {
+		http.Error(w, fmt.Sprintf("invalid batch body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Optional expiry applies to every key in the batch
+	ttl, err := parseTTL(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hops := 0
+	if header := r.Header.Get(batchHopsHeader); header != "" {
+		hops, _ = strconv.Atoi(header)
+	}
+
+	status := make(map[string]string, len(pairs))
+
+	// Store the local keys, group the rest by the next hop
+	groups := make(map[string]map[string][]byte)
+	for key, value := range pairs {
+		_, nextAddress, err := node.Put(key, value, ttl)
+		switch {
+		case err != nil:
+			status[key] = err.Error()
+		case nextAddress == "":
+			status[key] = batchStored
+		case nextAddress == node.Address() || hops >= maxBatchHops:
+			// Routing loops back to this node or bounces around the ring, don't forward again
+			status[key] = "no route to owner, retry later"
+		default:
+			if groups[nextAddress] == nil {
+				groups[nextAddress] = make(map[string][]byte)
+			}
+			groups[nextAddress][key] = value
+		}
+	}
+
+	// Forward the groups in parallel
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for nextAddress, group := range groups {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			groupStatus := t.forwardBatch(r, nextAddress, group, hops+1)
+			mu.Lock()
+			for key, s := range groupStatus {
+				status[key] = s
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode batch status: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// forwardBatch forwards a group of pairs to the next hop and returns their status
+// Every key of the group is failed with the error if the forward fails.
+func (t *HTTPTransport) forwardBatch(r *http.Request, nextAddress string, group map[string][]byte, hops int) map[string]string {
+
+	failAll := func(err error) map[string]string {
+		t.logger.Error("Batch", "failed to forward batch", "keys", len(group), "next", nextAddress, "err", err)
+		status := make(map[string]string, len(group))
+		for key := range group {
+			status[key] = err.Error()
+		}
+		return status
+	}
+
+	body, err := json.Marshal(group)
+	if err != nil {
+		return failAll(fmt.Errorf("failed to encode batch: %w", err))
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, t.url(nextAddress, "/batch"), bytes.NewReader(body))
+	if err != nil {
+		return failAll(fmt.Errorf("failed to create request: %w", err))
+	}
+	for name, values := range storageHeaders(r) {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(forwardedHeader, t.address)
+	req.Header.Set(batchHopsHeader, strconv.Itoa(hops))
+
+	resp, err := t.slowClient.Do(req)
+	if err != nil {
+		return failAll(fmt.Errorf("failed to forward batch: %w", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return failAll(fmt.Errorf("forwarded batch failed with status %d", resp.StatusCode))
+	}
+
+	var status map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return failAll(fmt.Errorf("failed to decode batch status: %w", err))
+	}
+
+	// Keys missing from the response were not stored
+	for key := range group {
+		if _, ok := status[key]; !ok {
+			status[key] = "no status returned"
+		}
+	}
+	return status
+}
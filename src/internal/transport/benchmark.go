@@ -0,0 +1,192 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// benchmarkParams are the parameters of a "/benchmark" run
+type benchmarkParams struct {
+	PutRatio    float64 `json:"put_ratio"`   // fraction of operations that are PUTs, 0..1
+	Keys        int     `json:"keys"`        // number of distinct keys to operate on
+	Concurrency int     `json:"concurrency"` // number of concurrent workers
+	DurationMs  int     `json:"duration_ms"` // duration of the run
+	ValueSize   int     `json:"value_size"`  // size of PUT values in bytes
+}
+
+// benchmarkResult is the summary returned by "/benchmark"
+type benchmarkResult struct {
+	Operations int                `json:"operations"`
+	Puts       int                `json:"puts"`
+	Gets       int                `json:"gets"`
+	Errors     int                `json:"errors"`
+	DurationMs int64              `json:"duration_ms"`
+	OpsPerSec  float64            `json:"ops_per_sec"`
+	LatencyMs  map[string]float64 `json:"latency_ms"`
+}
+
+// benchmarkWorkerResult is what a single worker collected during the run
+type benchmarkWorkerResult struct {
+	puts      int
+	gets      int
+	errors    int
+	latencies []time.Duration
+}
+
+// handleBenchmark handles requests to the "/benchmark" path
+// Drives synthetic put/get load against the ring through this node's own storage endpoint,
+// so the real forwarding path is exercised.
+func (t *HTTPTransport) handleBenchmark(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Defaults, overridden by the JSON body if present
+	params := benchmarkParams{
+		PutRatio:    0.5,
+		Keys:        100,
+		Concurrency: 4,
+		DurationMs:  2000,
+		ValueSize:   16,
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil && err != io.EOF {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if params.PutRatio < 0 || params.PutRatio > 1 || params.Keys <= 0 || params.Concurrency <= 0 || params.DurationMs <= 0 || params.ValueSize < 0 {
+		http.Error(w, "invalid benchmark parameters", http.StatusBadRequest)
+		return
+	}
+
+	t.logger.Info("Benchmark", "benchmark request received", "params", fmt.Sprintf("%+v", params))
+
+	// Stop on duration or when the client cancels
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(params.DurationMs)*time.Millisecond)
+	defer cancel()
+
+	result := t.runBenchmark(ctx, params)
+
+	t.logger.Info("Benchmark", "benchmark finished", "operations", result.Operations, "ops_per_sec", result.OpsPerSec, "errors", result.Errors)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode benchmark result: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// runBenchmark runs the workers until the context is done and aggregates their results
+func (t *HTTPTransport) runBenchmark(ctx context.Context, params benchmarkParams) benchmarkResult {
+
+	client := &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: t.pool,
+	}
+	value := bytes.Repeat([]byte("x"), params.ValueSize)
+
+	results := make([]benchmarkWorkerResult, params.Concurrency)
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	for i := 0; i < params.Concurrency; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			results[worker] = t.benchmarkWorker(ctx, client, params, value)
+		}(i)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	// Merge the worker results
+	result := benchmarkResult{
+		DurationMs: elapsed.Milliseconds(),
+		LatencyMs:  map[string]float64{},
+	}
+	var latencies []time.Duration
+	for _, res := range results {
+		result.Puts += res.puts
+		result.Gets += res.gets
+		result.Errors += res.errors
+		latencies = append(latencies, res.latencies...)
+	}
+	result.Operations = result.Puts + result.Gets
+	if elapsed > 0 {
+		result.OpsPerSec = float64(result.Operations) / elapsed.Seconds()
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	for name, p := range map[string]float64{"p50": 0.50, "p90": 0.90, "p99": 0.99, "max": 1.0} {
+		result.LatencyMs[name] = percentile(latencies, p)
+	}
+
+	return result
+}
+
+// benchmarkWorker issues operations against this node until the context is done
+func (t *HTTPTransport) benchmarkWorker(ctx context.Context, client *http.Client, params benchmarkParams, value []byte) benchmarkWorkerResult {
+
+	var res benchmarkWorkerResult
+
+	for ctx.Err() == nil {
+		url := t.url(t.ClientAddress(), fmt.Sprintf("/storage/bench-%d", rand.Intn(params.Keys)))
+
+		isPut := rand.Float64() < params.PutRatio
+		var req *http.Request
+		var err error
+		if isPut {
+			req, err = http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(value))
+		} else {
+			req, err = http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		}
+		if err != nil {
+			res.errors++
+			continue
+		}
+
+		start := time.Now()
+		resp, err := client.Do(req)
+		if err != nil {
+			// Requests cut off by the end of the run are not errors
+			if ctx.Err() == nil {
+				res.errors++
+			}
+			continue
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		res.latencies = append(res.latencies, time.Since(start))
+
+		// A GET on a key that was never put is a valid miss
+		if resp.StatusCode != http.StatusOK && !(!isPut && resp.StatusCode == http.StatusNotFound) {
+			res.errors++
+		}
+		if isPut {
+			res.puts++
+		} else {
+			res.gets++
+		}
+	}
+
+	return res
+}
+
+// percentile returns the p-th percentile of the sorted latencies in milliseconds
+func percentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	index := int(p * float64(len(sorted)-1))
+	return float64(sorted[index].Microseconds()) / 1000
+}
@@ -2,80 +2,257 @@ package transport
 
 import (
 	"assignment/internal/dht"
+	"assignment/internal/logging"
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"log"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
 	"strconv"
+	"sync/atomic"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // HTTPTransport represents the HTTP transport with its configuration
 type HTTPTransport struct {
-	node       dht.INode
-	server     *http.Server
-	address    string
-	inactive   bool
-	fastClient *http.Client
-	slowClient *http.Client
+	node        dht.INode
+	vnodes      []dht.INode // ring positions of the process, node first, see WithVnodes
+	server      *http.Server
+	address     string
+	inactive    atomic.Bool // read by request goroutines, written on state transitions
+	warming     atomic.Bool // set while the startup join is retried
+	draining    atomic.Bool // set while leaving, storage is refused but ring RPCs are still served
+	stats       stateStats
+	topology    topologyHistory
+	shedder     loadShedder
+	idempotency idempotencyCache // idempotency keys of the PUTs applied as owner
+	fastClient  *http.Client
+	slowClient  *http.Client
+	latencies   dht.PeerLatencies // of the ring RPCs of the fast client by peer process
+
+	// Separate listener for client traffic, nil if served by server
+	clientServer *http.Server
+
+	// "https" with TLS configured, the client side config is nil otherwise
+	scheme    string
+	clientTLS *tls.Config
+
+	// Kept-alive connections shared by every outbound request, see newConnPool
+	pool            *http.Transport
+	forwardClient   *http.Client // forwarded storage requests, with the forward timeout
+	traversalClient *http.Client // ring traversals, bounded by the request context
+
+	// Client endpoints in registration order, listed by "/"
+	clientEndpoints []string
+
+	// Config
+	benchmarkEnabled bool
+	adminEnabled     bool
+	rootCrashExempt  bool
+	clientAddr       string
+	bindHost         string // interface the rpc listener binds to, all if empty
+	skewThreshold    float64
+	maxHops          int
+	maxIdleConns     int // idle pooled connections per peer
+	forwardTimeout   time.Duration
+	requestTimeout   time.Duration // overall deadline of a storage request across its hops
+	tlsCertFile      string
+	tlsKeyFile       string
+	tlsCAFile        string
+
+	logger logging.Logger
 }
 
 // New creates a new server instance
-func New(hostname string, port string, node dht.INode) (*HTTPTransport, error) {
+// The node is advertised to peers on hostname:port. With WithClientAddr, client traffic is
+// served on its own listener and hostname:port only serves inter-node RPCs. The rpc listener
+// binds to port on all interfaces unless WithBindAddr is given, independent of the hostname.
+func New(hostname string, port string, node dht.INode, opts ...Option) (*HTTPTransport, error) {
 
 	mux := http.NewServeMux()
 
+	timing := dht.DefaultTiming()
 	t := &HTTPTransport{
 		node:    node,
+		vnodes:  []dht.INode{node},
 		address: hostname + ":" + port,
+		stats:   stateStats{currentState: stateActive},
 		slowClient: &http.Client{
-			Timeout: 2 * time.Second,
+			Timeout: timing.SlowTimeout,
 		},
 		fastClient: &http.Client{
-			Timeout: 500 * time.Millisecond,
+			Timeout: timing.FastTimeout,
 		},
+		forwardTimeout: timing.ForwardTimeout,
+		scheme:         "http",
+		skewThreshold:  DefaultSkewThreshold,
+		maxIdleConns:   DefaultMaxIdleConnsPerHost,
+		maxHops:        DefaultMaxHops,
+		requestTimeout: DefaultRequestTimeout,
+		logger:         logging.NewText(nil),
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+	t.logger = t.logger.WithNode(node.Id(), t.address)
+
+	// Every outbound request shares one pool of kept-alive connections, TLS configures it below
+	t.pool = newConnPool(t.maxIdleConns)
+	t.fastClient.Transport = t.pool
+	t.slowClient.Transport = t.pool
+	t.forwardClient = &http.Client{Timeout: t.forwardTimeout, Transport: t.pool}
+	t.traversalClient = &http.Client{Transport: t.pool}
+
+	var serverTLS *tls.Config
+	if t.tlsCertFile != "" {
+		var err error
+		if serverTLS, err = t.configureTLS(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Ring RPCs of the fast client feed the maintenance throttle of the vnodes and the latencies
+	t.fastClient.Transport = &observedRoundTripper{next: t.pool, observe: t.observeRPC, latencies: &t.latencies}
+
+	if t.bindHost != "" && net.ParseIP(t.bindHost) == nil {
+		return nil, fmt.Errorf("invalid bind address '%s', expected the IP address of a local interface", t.bindHost)
+	}
+
+	// Client endpoints share the mux unless a separate client address is configured
+	clientMux := mux
+	if t.clientAddr != "" {
+		if _, _, err := net.SplitHostPort(t.clientAddr); err != nil {
+			return nil, fmt.Errorf("invalid client address '%s': %w", t.clientAddr, err)
+		}
+		clientMux = http.NewServeMux()
+	}
+
+	// client endpoints
+	t.handleClient(mux, clientMux, "/storage", t.handleLocalKeys)
+	t.handleClient(mux, clientMux, "/storage/", t.handleStorage)
+	t.handleClient(mux, clientMux, "/batch", t.handleBatch)
+	t.handleClient(mux, clientMux, "/network", t.handleNetwork)
+	t.handleClient(mux, clientMux, "/node-info", t.handleNodeInfo)
+	t.handleClient(mux, clientMux, "/owned-range", t.handleOwnedRange)
+	t.handleClient(mux, clientMux, "/lookup", t.handleLookup)
+	t.handleClient(mux, clientMux, "/stats", t.handleStats)
+	t.handleClient(mux, clientMux, "/dump", t.handleDump)
+	t.handleClient(mux, clientMux, "/repair", t.handleRepair)
+	t.handleClient(mux, clientMux, "/fix-fingers", t.handleFixFingers)
+	t.handleClient(mux, clientMux, "/verify", t.handleVerify)
+	t.handleClient(mux, clientMux, "/hot-keys", t.handleHotKeys)
+	t.handleClient(mux, clientMux, "/load", t.handleLoad)
+	t.handleClient(mux, clientMux, "/skew", t.handleSkew)
+	t.handleClient(mux, clientMux, "/distribution", t.handleDistribution)
+	t.handleClient(mux, clientMux, "/ring-stats", t.handleRingStats)
+	t.handleClient(mux, clientMux, "/topology", t.handleTopology)
+	t.handleClient(mux, clientMux, "/topology/diff", t.handleTopologyDiff)
+	t.handleClient(mux, clientMux, "/metrics", promhttp.Handler().ServeHTTP)
+
+	// load generator, only when enabled
+	if t.benchmarkEnabled {
+		t.handleClient(mux, clientMux, "/benchmark", t.handleBenchmark)
 	}
 
 	// system endpoints
 	mux.HandleFunc("/ping", t.handlePing)
-	mux.HandleFunc("/storage/", t.handleStorage)
-	mux.HandleFunc("/network", t.handleNetwork)
-	mux.HandleFunc("/node-info", t.handleNodeInfo)
+	mux.HandleFunc("/handoff", t.handleHandoff)
+	mux.HandleFunc("/replica", t.handleReplica)
 	mux.HandleFunc("/join", t.handleJoin)
 	mux.HandleFunc("/leave", t.handleLeave)
+	mux.HandleFunc("/rejoin", t.handleRejoin)
 	mux.HandleFunc("/sim-crash", t.handleSimCrash)
 	mux.HandleFunc("/sim-recover", t.handleSimRecover)
 
+	// admin endpoints for test harnesses, only when enabled
+	if t.adminEnabled {
+		mux.HandleFunc("/admin/links", t.handleAdminLinks)
+	}
+
 	// node rpc endpoints
-	mux.HandleFunc("/predecessor", t.handlePredecessor) // endpoint to get/put predecessor of the node
-	mux.HandleFunc("/successor", t.handleSuccessor)     // endpoint to get/put the successor of the node
+	mux.HandleFunc("/predecessor", t.handlePredecessor)            // endpoint to get/put predecessor of the node
+	mux.HandleFunc("/successor", t.handleSuccessor)                // endpoint to get/put the successor of the node
+	mux.HandleFunc("/successor-list", t.handleSuccessorList)       // endpoint to get the successor list of the node
+	mux.HandleFunc("/closest-preceding", t.handleClosestPreceding) // endpoint to get one step of an iterative lookup
+
+	// membership event stream, long-lived so not counted by the load shedding of client endpoints
+	clientMux.HandleFunc("/events", t.handleEvents)
+	t.clientEndpoints = append(t.clientEndpoints, "/events")
+
+	// health probes and the endpoint index, on both listeners, the index also answers for unknown paths
+	mux.HandleFunc("/healthz", t.handleHealthz)
+	mux.HandleFunc("/readyz", t.handleReadyz)
+	mux.HandleFunc("/", t.handleRoot)
+	if clientMux != mux {
+		clientMux.HandleFunc("/healthz", t.handleHealthz)
+		clientMux.HandleFunc("/readyz", t.handleReadyz)
+		clientMux.HandleFunc("/", t.handleRoot)
+	}
 
-	// Wrap the mux with crash middleware
+	// Wrap the mux with crash middleware, requests to a vnode are scoped to it
 	t.server = &http.Server{
-		Addr:    ":" + port,
-		Handler: t.crashMiddleware(mux),
+		Addr:      net.JoinHostPort(t.bindHost, port),
+		Handler:   t.crashMiddleware(t.vnodeMiddleware(mux)),
+		TLSConfig: serverTLS,
 	}
 
-	log.Printf("Transport created on '%s'", t.address)
+	if clientMux != mux {
+		t.clientServer = &http.Server{
+			Addr:      t.clientAddr,
+			Handler:   t.crashMiddleware(t.vnodeMiddleware(clientMux)),
+			TLSConfig: serverTLS,
+		}
+		t.logger.Info("New", "serving client traffic", "client_address", t.clientAddr)
+	}
+
+	t.logger.Info("New", "transport created", "rpc_address", t.address, "listen_address", t.server.Addr, "scheme", t.scheme, "vnodes", len(t.vnodes))
 	return t, nil
 }
 
+// handleClient registers a client endpoint. With a separate client listener, the rpc listener
+// only serves the endpoint for hops forwarded by other nodes, e.g. storage forwards and traversals.
+func (t *HTTPTransport) handleClient(mux *http.ServeMux, clientMux *http.ServeMux, pattern string, handler http.HandlerFunc) {
+	t.clientEndpoints = append(t.clientEndpoints, pattern)
+	handler = t.shed(handler)
+	clientMux.HandleFunc(pattern, handler)
+	if clientMux == mux {
+		return
+	}
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(forwardedHeader) == "" {
+			http.Error(w, "client endpoint, use the client address", http.StatusNotFound)
+			return
+		}
+		handler(w, r)
+	})
+}
+
 // crashMiddleware wraps the entire mux to check crash status
 func (t *HTTPTransport) crashMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Allow sim-recover even when inactive
-		if r.URL.Path == "/sim-recover" {
+		// Allow stats, the health probes and the state transitions even when inactive, the probes and
+		// transitions check the state themselves
+		switch r.URL.Path {
+		case "/stats", "/healthz", "/readyz", "/sim-crash", "/sim-recover", "/leave", "/rejoin":
 			next.ServeHTTP(w, r)
 			return
+		case "/":
+			if t.rootCrashExempt {
+				next.ServeHTTP(w, r)
+				return
+			}
 		}
 
 		// Refuse all other requests if inactive
-		if t.inactive {
-			refuseRequest(w, r)
+		if t.inactive.Load() {
+			refuseRequest(w, r, t.unavailableReason())
 			return
 		}
 
@@ -85,27 +262,61 @@ func (t *HTTPTransport) crashMiddleware(next http.Handler) http.Handler {
 
 // Start starts the HTTP server in a goroutine
 func (t *HTTPTransport) Start() error {
-	if err := t.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	errs := make(chan error, 2)
+
+	if t.clientServer != nil {
+		go func() {
+			if err := t.listenAndServe(t.clientServer); err != nil && err != http.ErrServerClosed {
+				errs <- fmt.Errorf("could not start client server: %w", err)
+				return
+			}
+			errs <- nil
+		}()
+	}
+
+	if err := t.listenAndServe(t.server); err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("could not start server: %w", err)
 	}
 
+	if t.clientServer != nil {
+		return <-errs
+	}
 	return nil
 }
 
 // Stop gracefully shuts down the server
 func (t *HTTPTransport) Stop(ctx context.Context) error {
-	log.Println("Shutting down server...")
+	t.logger.Info("Stop", "shutting down server")
+	if t.clientServer != nil {
+		if err := t.clientServer.Shutdown(ctx); err != nil {
+			return fmt.Errorf("client server forced to shutdown: %w", err)
+		}
+	}
 	if err := t.server.Shutdown(ctx); err != nil {
 		return fmt.Errorf("server forced to shutdown: %w", err)
 	}
-	log.Println("Server exited cleanly")
+
+	// Release the kept-alive connections to other nodes, so a transport can be recreated in the same
+	// process. Every client shares the pool, the fast one only through its observedRoundTripper.
+	t.pool.CloseIdleConnections()
+
+	t.logger.Info("Stop", "server exited cleanly")
 	return nil
 }
 
+// Address returns the address the node is advertised on to peers
 func (t *HTTPTransport) Address() string {
 	return t.address
 }
 
+// ClientAddress returns the address client traffic is served on
+func (t *HTTPTransport) ClientAddress() string {
+	if t.clientAddr != "" {
+		return t.clientAddr
+	}
+	return t.address
+}
+
 // IMPLEMENTATION OF THE TRANSPORT INTERFACE
 // RPC between nodes
 
@@ -113,12 +324,12 @@ func (t *HTTPTransport) Address() string {
 
 // FindSuccessor finds the successor of the key recursively
 // Used in stabilization and join operations
-func (t *HTTPTransport) FindSuccessor(addr string, keyId int) (successor string, err error) {
+func (t *HTTPTransport) FindSuccessor(ctx context.Context, addr string, keyId int) (successor string, err error) {
 
 	keyIdStr := strconv.Itoa(keyId)
 
 	// Use GET with query parameter
-	resp, err := t.fastClient.Get("http://" + addr + "/successor?key=" + url.QueryEscape(keyIdStr))
+	resp, err := t.fastGet(ctx, t.url(addr, "/successor?key="+url.QueryEscape(keyIdStr)))
 	if err != nil {
 		if ne, ok := err.(net.Error); ok && ne.Timeout() {
 			err = fmt.Errorf("TIMEOUT: exceeded %v", ne.Timeout())
@@ -141,9 +352,9 @@ func (t *HTTPTransport) FindSuccessor(addr string, keyId int) (successor string,
 
 // GetPredecessor gets the predecessor of the node
 // Used in stabilization and leave operations
-func (t *HTTPTransport) GetPredecessor(addr string) (string, error) {
+func (t *HTTPTransport) GetPredecessor(ctx context.Context, addr string) (string, error) {
 
-	resp, err := t.fastClient.Get("http://" + addr + "/predecessor")
+	resp, err := t.fastGet(ctx, t.url(addr, "/predecessor"))
 	if err != nil {
 		if ne, ok := err.(net.Error); ok && ne.Timeout() {
 			err = fmt.Errorf("TIMEOUT: exceeded %v", ne.Timeout())
@@ -164,9 +375,59 @@ func (t *HTTPTransport) GetPredecessor(addr string) (string, error) {
 	return predecessor, nil
 }
 
+// GetSuccessorList gets the successor list of the node
+// Used in stabilization to maintain the backup successors
+func (t *HTTPTransport) GetSuccessorList(addr string) ([]string, error) {
+
+	resp, err := t.fastClient.Get(t.url(addr, "/successor-list"))
+	if err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			err = fmt.Errorf("TIMEOUT: exceeded %v", ne.Timeout())
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("successor list request failed with status %d", resp.StatusCode)
+	}
+
+	var successors []string
+	if err := json.NewDecoder(resp.Body).Decode(&successors); err != nil {
+		return nil, fmt.Errorf("failed to decode successor list response: %w", err)
+	}
+
+	return successors, nil
+}
+
+// ClosestPreceding gets the closest preceding node of the key and the successor of the node
+// Used in iterative lookups
+func (t *HTTPTransport) ClosestPreceding(addr string, keyId int) (closest string, successor string, err error) {
+
+	resp, err := t.fastClient.Get(t.url(addr, "/closest-preceding?key="+strconv.Itoa(keyId)))
+	if err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			err = fmt.Errorf("TIMEOUT: exceeded %v", ne.Timeout())
+		}
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("closest preceding request failed with status %d", resp.StatusCode)
+	}
+
+	var step closestPrecedingStep
+	if err := json.NewDecoder(resp.Body).Decode(&step); err != nil {
+		return "", "", fmt.Errorf("failed to decode closest preceding response: %w", err)
+	}
+
+	return step.Closest, step.Successor, nil
+}
+
 // Notify notifies the node at the given address that it might have a new predecessor
 // Used in stabilization and join operations
-func (t *HTTPTransport) Notify(targetAddr string, newPredecessor string) error {
+func (t *HTTPTransport) Notify(ctx context.Context, targetAddr string, newPredecessor string) error {
 
 	// Create JSON payload
 	payload, err := json.Marshal(newPredecessor)
@@ -175,7 +436,7 @@ func (t *HTTPTransport) Notify(targetAddr string, newPredecessor string) error {
 	}
 
 	// Create PUT request
-	req, err := http.NewRequest("PUT", "http://"+targetAddr+"/predecessor", bytes.NewReader(payload))
+	req, err := http.NewRequestWithContext(ctx, "PUT", t.url(targetAddr, "/predecessor"), bytes.NewReader(payload))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -197,9 +458,9 @@ func (t *HTTPTransport) Notify(targetAddr string, newPredecessor string) error {
 }
 
 // CheckAlive checks if the node at the given address is alive
-func (t *HTTPTransport) CheckAlive(targetAddr string) (bool, error) {
+func (t *HTTPTransport) CheckAlive(ctx context.Context, targetAddr string) (bool, error) {
 
-	resp, err := t.fastClient.Get("http://" + targetAddr + "/ping")
+	resp, err := t.fastGet(ctx, t.url(targetAddr, "/ping"))
 	if err != nil {
 		if ne, ok := err.(net.Error); ok && ne.Timeout() {
 			err = fmt.Errorf("TIMEOUT: exceeded %v", ne.Timeout())
@@ -211,13 +472,55 @@ func (t *HTTPTransport) CheckAlive(targetAddr string) (bool, error) {
 	return resp.StatusCode == http.StatusOK, nil
 }
 
+// CheckAliveExpecting checks if the node at the given address is alive and has the expected id.
+// Ids are hashed from the address, so a mismatch means another process or a node with a different
+// identifier space answered on the address, e.g. after the port was reassigned.
+func (t *HTTPTransport) CheckAliveExpecting(ctx context.Context, targetAddr string, expectedId int) (bool, error) {
+
+	resp, err := t.fastGet(ctx, t.url(targetAddr, "/ping"))
+	if err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			err = fmt.Errorf("TIMEOUT: exceeded %v", ne.Timeout())
+		}
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, nil
+	}
+
+	var identity pingReply
+	if err := json.NewDecoder(resp.Body).Decode(&identity); err != nil {
+		return false, fmt.Errorf("failed to decode ping reply from %s: %w", targetAddr, err)
+	}
+	return checkIdentity(targetAddr, expectedId, identity.Address, identity.Id)
+}
+
+// fastGet sends a GET with the fast client, cancelled with ctx
+func (t *HTTPTransport) fastGet(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	return t.fastClient.Do(req)
+}
+
+// checkIdentity returns an error if the node that answered a ping is not the expected one
+func checkIdentity(targetAddr string, expectedId int, address string, id int) (bool, error) {
+	if id != expectedId {
+		return false, fmt.Errorf("%s answered as node %d (%s), expected node %d", targetAddr, id, address, expectedId)
+	}
+	return true, nil
+}
+
 // =============== AUTHORITY RPC'S ===============
 
 // RPC's that are important to conclude the DHT operations, uses slow client to ensure reliability
 
 // SetSuccessor sets the successor of the node
 // 2 seconds timeout
-func (t *HTTPTransport) SetSuccessor(targetAddr string, newSuccessor string) error {
+func (t *HTTPTransport) SetSuccessor(ctx context.Context, targetAddr string, newSuccessor string) error {
 
 	// Create JSON payload
 	payload, err := json.Marshal(newSuccessor)
@@ -226,7 +529,7 @@ func (t *HTTPTransport) SetSuccessor(targetAddr string, newSuccessor string) err
 	}
 
 	// Create PUT request
-	req, err := http.NewRequest("PUT", "http://"+targetAddr+"/successor", bytes.NewReader(payload))
+	req, err := http.NewRequestWithContext(ctx, "PUT", t.url(targetAddr, "/successor"), bytes.NewReader(payload))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -249,7 +552,7 @@ func (t *HTTPTransport) SetSuccessor(targetAddr string, newSuccessor string) err
 
 // SetPredecessor sets the predecessor of the node
 // 2 seconds timeout
-func (t *HTTPTransport) SetPredecessor(targetAddr string, newPredecessor string) error {
+func (t *HTTPTransport) SetPredecessor(ctx context.Context, targetAddr string, newPredecessor string) error {
 
 	// Create JSON payload
 	payload, err := json.Marshal(newPredecessor)
@@ -258,7 +561,7 @@ func (t *HTTPTransport) SetPredecessor(targetAddr string, newPredecessor string)
 	}
 
 	// Create PUT request
-	req, err := http.NewRequest("POST", "http://"+targetAddr+"/predecessor", bytes.NewReader(payload))
+	req, err := http.NewRequestWithContext(ctx, "POST", t.url(targetAddr, "/predecessor"), bytes.NewReader(payload))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -280,6 +583,220 @@ func (t *HTTPTransport) SetPredecessor(targetAddr string, newPredecessor string)
 
 }
 
+// =============== DATA HANDOFF RPC'S ===============
+
+// GetHandoffBatch gets a batch of the keys in (fromId, toId] stored on the node, ordered by key and starting after the given key
+// Used when a node has taken over part of the key range of the target
+func (t *HTTPTransport) GetHandoffBatch(targetAddr string, fromId int, toId int, after string, limit int) (map[string]dht.Versioned, bool, error) {
+
+	query := url.Values{}
+	query.Set("from", strconv.Itoa(fromId))
+	query.Set("to", strconv.Itoa(toId))
+	query.Set("after", after)
+	query.Set("limit", strconv.Itoa(limit))
+
+	resp, err := t.slowClient.Get(t.url(targetAddr, "/handoff?"+query.Encode()))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get handoff batch from %s: %w", targetAddr, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("handoff batch request failed with status %d", resp.StatusCode)
+	}
+
+	var batch handoffBatch
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		return nil, false, fmt.Errorf("failed to decode handoff batch: %w", err)
+	}
+
+	return batch.Pairs, batch.More, nil
+}
+
+// StoreKey stores the key-value pair on the node, which forwards it if it does not own the key
+// Used to move misplaced keys to their owner
+func (t *HTTPTransport) StoreKey(targetAddr string, key string, value []byte) error {
+
+	req, err := http.NewRequest(http.MethodPut, t.url(targetAddr, "/storage/"+url.PathEscape(key)), bytes.NewReader(value))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set(forwardedHeader, t.address)
+
+	// Send request
+	resp, err := t.slowClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to store key on %s: %w", targetAddr, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("store key failed with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// PushHandoff hands off the key-value pairs to the node, which stores them regardless of ownership
+// Used by a leaving node to transfer its data to the successor
+func (t *HTTPTransport) PushHandoff(targetAddr string, pairs map[string]dht.Versioned) error {
+
+	// Create JSON payload
+	payload, err := json.Marshal(pairs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal handoff: %w", err)
+	}
+
+	// Create PUT request
+	req, err := http.NewRequest(http.MethodPut, t.url(targetAddr, "/handoff"), bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	// Send request
+	resp, err := t.slowClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to hand off keys to %s: %w", targetAddr, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("handoff failed with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// ConfirmHandoff confirms to the old owner that the handed off keys were stored, so it deletes them
+func (t *HTTPTransport) ConfirmHandoff(targetAddr string, pairs map[string]dht.Versioned) error {
+
+	// Create JSON payload
+	payload, err := json.Marshal(pairs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal handoff confirmation: %w", err)
+	}
+
+	// Send request
+	resp, err := t.slowClient.Post(t.url(targetAddr, "/handoff"), "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to confirm handoff on %s: %w", targetAddr, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("handoff confirmation failed with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// PushReplicas stores the versioned values on the node as replicas of this node's keys
+func (t *HTTPTransport) PushReplicas(targetAddr string, pairs map[string]dht.Versioned) error {
+
+	// Create JSON payload
+	payload, err := json.Marshal(pairs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal replicas: %w", err)
+	}
+
+	// Create PUT request
+	req, err := http.NewRequest(http.MethodPut, t.url(targetAddr, "/replica"), bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	// Send request
+	resp, err := t.slowClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push replicas to %s: %w", targetAddr, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("replica push failed with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// GetReplica gets the node's replica of the key with its version and expiry, found is false if the node holds none
+func (t *HTTPTransport) GetReplica(targetAddr string, key string) (dht.Versioned, bool, error) {
+
+	resp, err := t.fastClient.Get(t.url(targetAddr, "/replica?key="+url.QueryEscape(key)))
+	if err != nil {
+		return dht.Versioned{}, false, fmt.Errorf("failed to get replica from %s: %w", targetAddr, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return dht.Versioned{}, false, nil
+	default:
+		return dht.Versioned{}, false, fmt.Errorf("replica request failed with status %d", resp.StatusCode)
+	}
+
+	value, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return dht.Versioned{}, false, fmt.Errorf("failed to read replica: %w", err)
+	}
+	version, ok := parseETag(resp.Header.Get(etagHeader))
+	if !ok {
+		return dht.Versioned{}, false, fmt.Errorf("replica of %s has no version", key)
+	}
+	var expiresAt time.Time
+	if header := resp.Header.Get(expiresAtHeader); header != "" {
+		if expiresAt, err = time.Parse(time.RFC3339Nano, header); err != nil {
+			return dht.Versioned{}, false, fmt.Errorf("replica of %s has an invalid expiry: %w", key, err)
+		}
+	}
+
+	return dht.Versioned{Value: value, Version: version, ExpiresAt: expiresAt}, true, nil
+}
+
+// DeleteReplicas deletes the node's replicas of the keys
+func (t *HTTPTransport) DeleteReplicas(targetAddr string, keys []string) error {
+
+	// Create JSON payload
+	payload, err := json.Marshal(keys)
+	if err != nil {
+		return fmt.Errorf("failed to marshal replica keys: %w", err)
+	}
+
+	// Create DELETE request
+	req, err := http.NewRequest(http.MethodDelete, t.url(targetAddr, "/replica"), bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	// Send request
+	resp, err := t.slowClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete replicas on %s: %w", targetAddr, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("replica delete failed with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Latency returns the recent latency of the ring RPCs to the process of the node
+func (t *HTTPTransport) Latency(targetAddr string) (time.Duration, bool) {
+	host, _ := dht.SplitVnode(targetAddr)
+	return t.latencies.Latency(host)
+}
+
 func (t *HTTPTransport) IsInactive() bool {
-	return t.inactive
+	return t.inactive.Load()
 }
@@ -0,0 +1,156 @@
+package transport
+
+import (
+	"assignment/internal/dht"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// nodeDistribution is the per-node result of a "/distribution" traversal
+type nodeDistribution struct {
+	Node       string  `json:"node"`
+	Id         int     `json:"id"`
+	Successor  string  `json:"successor"`
+	KeyCount   int     `json:"key_count"`
+	TotalBytes int     `json:"total_bytes"`
+	Owned      float64 `json:"owned_fraction"` // share of the identifier space, the ideal share of the keys
+	OwnedKnown bool    `json:"owned_known"`    // false while the node has no predecessor
+	KeyShare   float64 `json:"key_fraction"`   // actual share of the keys
+}
+
+// processDistribution sums the nodeDistribution of the vnodes of a process
+type processDistribution struct {
+	Process  string  `json:"process"`
+	Vnodes   int     `json:"vnodes"`
+	KeyCount int     `json:"key_count"`
+	Owned    float64 `json:"owned_fraction"`
+	KeyShare float64 `json:"key_fraction"`
+}
+
+// distributionReport is the response of "/distribution"
+type distributionReport struct {
+	Nodes      []nodeDistribution    `json:"nodes"`
+	Processes  []processDistribution `json:"processes"` // per process address, the load a machine carries with vnodes
+	TotalKeys  int                   `json:"total_keys"`
+	TotalBytes int                   `json:"total_bytes"`
+	Complete   bool                  `json:"complete"` // the traversal came back to this node
+}
+
+// handleDistribution handles GET requests to the "/distribution" path
+// Returns the key count, value bytes and owned share of the identifier space of every node, sorted
+// by id, collected by walking the ring like "/ring-stats". The traversal stops at the first node
+// visited twice or that can't be reached, so a broken ring returns the nodes reached and complete false.
+// Hops forwarded by other nodes carry "?visited=" with the nodes so far and only return the nodes collected.
+func (t *HTTPTransport) handleDistribution(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Forwarded hop of a traversal
+	if r.URL.Query().Has("visited") {
+		visited := strings.Split(r.URL.Query().Get("visited"), ",")
+		nodes := t.collectDistribution(r.Context(), visited)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(nodes); err != nil {
+			http.Error(w, fmt.Sprintf("failed to encode distribution: %v", err), http.StatusInternalServerError)
+			return
+		}
+		return
+	}
+
+	// The ring is closed if the last node reached links back to this one
+	nodes := t.collectDistribution(r.Context(), nil)
+	report := distributionReport{Nodes: nodes, Complete: nodes[len(nodes)-1].Successor == nodes[0].Node}
+	for _, n := range nodes {
+		report.TotalKeys += n.KeyCount
+		report.TotalBytes += n.TotalBytes
+	}
+	for i := range report.Nodes {
+		if report.TotalKeys > 0 {
+			report.Nodes[i].KeyShare = float64(report.Nodes[i].KeyCount) / float64(report.TotalKeys)
+		}
+	}
+	slices.SortFunc(report.Nodes, func(a, b nodeDistribution) int {
+		return a.Id - b.Id
+	})
+	report.Processes = processDistributions(report.Nodes)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode distribution: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// collectDistribution returns the distribution of this node followed by the nodes up to the first visited one
+// The traversal stops at a successor that was already visited, so a broken ring can't loop it forever.
+func (t *HTTPTransport) collectDistribution(ctx context.Context, visited []string) []nodeDistribution {
+
+	node := t.vnode(ctx)
+
+	_, succAdr := node.Successor()
+	keys, bytes := node.DataSize()
+	owned, known := node.OwnedFraction()
+	nodes := []nodeDistribution{{
+		Node:       node.Address(),
+		Id:         node.Id(),
+		Successor:  succAdr,
+		KeyCount:   keys,
+		TotalBytes: bytes,
+		Owned:      owned,
+		OwnedKnown: known,
+	}}
+
+	visited = append(visited, node.Address())
+
+	// We keep forwarding the request until the traversal comes back to a visited node
+	if succAdr != "" && !slices.Contains(visited, succAdr) {
+		forwardURL := t.url(succAdr, "/distribution?visited="+url.QueryEscape(strings.Join(visited, ",")))
+		resp, err := t.forwardedRequest(ctx, http.MethodGet, forwardURL, nil)
+		if err == nil {
+			defer resp.Body.Close()
+			var succNodes []nodeDistribution
+			if err := json.NewDecoder(resp.Body).Decode(&succNodes); err == nil {
+				nodes = append(nodes, succNodes...)
+			}
+		} else {
+			t.logger.Error("Distribution", "failed to forward distribution request to successor", "successor", succAdr, "err", err)
+		}
+	}
+
+	return nodes
+}
+
+// processDistributions groups the vnodes of the traversal by process, sorted by address
+func processDistributions(nodes []nodeDistribution) []processDistribution {
+
+	byProcess := make(map[string]*processDistribution)
+	for _, n := range nodes {
+		process, _ := dht.SplitVnode(n.Node)
+		p, ok := byProcess[process]
+		if !ok {
+			p = &processDistribution{Process: process}
+			byProcess[process] = p
+		}
+		p.Vnodes++
+		p.KeyCount += n.KeyCount
+		p.Owned += n.Owned
+		p.KeyShare += n.KeyShare
+	}
+
+	processes := make([]processDistribution, 0, len(byProcess))
+	for _, p := range byProcess {
+		processes = append(processes, *p)
+	}
+	slices.SortFunc(processes, func(a, b processDistribution) int {
+		return strings.Compare(a.Process, b.Process)
+	})
+	return processes
+}
@@ -0,0 +1,74 @@
+package transport
+
+import (
+	"assignment/internal/dht"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Events buffered per "/events" subscriber, further events are dropped until the client catches up
+const eventBuffer = 64
+
+// An idle "/events" stream gets a comment line every eventKeepalive, so proxies keep it open
+// and a client that went away is noticed
+const eventKeepalive = 15 * time.Second
+
+// handleEvents handles GET requests to the "/events" path
+// Streams the membership changes of every vnode of the process as server-sent events, the event
+// name is the dht.Event type and the data its JSON. The stream ends when the client disconnects.
+func (t *HTTPTransport) handleEvents(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events := make(chan dht.Event, eventBuffer)
+	for _, node := range t.vnodes {
+		unsubscribe := node.Events().Subscribe(events)
+		defer unsubscribe()
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	t.logger.Info("Events", "subscriber connected", "remote", r.RemoteAddr)
+
+	keepalive := time.NewTicker(eventKeepalive)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			t.logger.Info("Events", "subscriber disconnected", "remote", r.RemoteAddr)
+			return
+
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case e := <-events:
+			data, err := json.Marshal(e)
+			if err != nil {
+				t.logger.Error("Events", "failed to encode event", "type", e.Type, "err", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"assignment/internal/dht"
+)
+
+// subscribeEvents opens GET /events on the server and returns the events streamed to it
+func subscribeEvents(t *testing.T, ctx context.Context, url string) <-chan dht.Event {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+"/events", nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /events: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("GET /events: status %d, content type %q, want 200 text/event-stream", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	events := make(chan dht.Event, eventBuffer)
+	go func() {
+		defer resp.Body.Close()
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		name := ""
+		for scanner.Scan() {
+			line := scanner.Text()
+			if value, ok := strings.CutPrefix(line, "event: "); ok {
+				name = value
+			}
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				var e dht.Event
+				if err := json.Unmarshal([]byte(data), &e); err != nil || e.Type != name {
+					t.Errorf("event %q with data %s: %v", name, data, err)
+					return
+				}
+				events <- e
+			}
+		}
+	}()
+	return events
+}
+
+// nextEvent returns the next event of the type, failing the test if none arrives within a second
+func nextEvent(t *testing.T, events <-chan dht.Event, eventType string) dht.Event {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				t.Fatalf("stream closed before a %s event", eventType)
+			}
+			if e.Type == eventType {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("no %s event within a second", eventType)
+		}
+	}
+}
+
+func TestEventsStreamPredecessorFailure(t *testing.T) {
+	net := dht.NewMemoryNetwork()
+	nodes := net.BuildRing(3)
+	failed, node := nodes[0], nodes[1]
+	server := httptest.NewServer(newTestTransport(t, node).server.Handler)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	first := subscribeEvents(t, ctx, server.URL)
+	second := subscribeEvents(t, ctx, server.URL)
+
+	net.Fail(failed.Address())
+	for range dht.DefaultPredecessorFailureThreshold {
+		node.CheckPredecessor(ctx)
+	}
+
+	// Every subscriber gets the failure, then the predecessor being cleared
+	for _, events := range []<-chan dht.Event{first, second} {
+		e := nextEvent(t, events, dht.EventNodeFailed)
+		if e.Node != node.Address() || e.Peer != failed.Address() || e.Source != "check_predecessor" {
+			t.Errorf("failure event = %+v, want %s detecting %s in check_predecessor", e, node.Address(), failed.Address())
+		}
+		if e := nextEvent(t, events, dht.EventPredecessor); e.Peer != "" || e.Previous != failed.Address() {
+			t.Errorf("predecessor event = %+v, want %s cleared", e, failed.Address())
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package transport
+
+import (
+	"assignment/internal/dht"
+	"assignment/internal/transport/dhtpb"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCTransport sends the inter-node ring RPCs over gRPC instead of HTTP/JSON.
+// It embeds the HTTP transport, which keeps serving client traffic, data handoff and the
+// inactive state. gRPC is served on the same address as HTTP, multiplexed over HTTP/2,
+// unencrypted unless the HTTP transport has TLS configured.
+type GRPCTransport struct {
+	*HTTPTransport
+	grpcServer *grpc.Server
+
+	// Client connections to other nodes, created on first use
+	connsMu sync.Mutex
+	conns   map[string]*grpc.ClientConn
+}
+
+// NewGRPC creates a gRPC transport on top of the HTTP transport, must be called before Start
+func NewGRPC(t *HTTPTransport) *GRPCTransport {
+
+	g := &GRPCTransport{
+		HTTPTransport: t,
+		conns:         make(map[string]*grpc.ClientConn),
+	}
+	g.grpcServer = grpc.NewServer(grpc.UnaryInterceptor(g.refuseInactive))
+	dhtpb.RegisterNodeServer(g.grpcServer, &grpcNodeServer{transport: t})
+
+	// Accept gRPC's HTTP/2 next to HTTP/1 on the rpc listener
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	t.server.Protocols = protocols
+	t.server.Handler = routeGRPC(g.grpcServer, t.server.Handler)
+
+	t.logger.Info("NewGRPC", "serving ring RPCs over gRPC", "rpc_address", t.address)
+	return g
+}
+
+// refuseInactive refuses the calls of an inactive node with Unavailable, like crashMiddleware
+// refuses its HTTP requests. The message carries the unavailable reason, see unavailableReason.
+func (g *GRPCTransport) refuseInactive(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if g.inactive.Load() {
+		return nil, status.Error(codes.Unavailable, unavailableMessage(g.unavailableReason()))
+	}
+	return handler(ctx, req)
+}
+
+// routeGRPC sends gRPC requests to the gRPC handler and everything else to next
+func routeGRPC(grpcHandler http.Handler, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			grpcHandler.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Stop closes the client connections and shuts down the server
+func (g *GRPCTransport) Stop(ctx context.Context) error {
+	g.connsMu.Lock()
+	for addr, conn := range g.conns {
+		_ = conn.Close()
+		delete(g.conns, addr)
+	}
+	g.connsMu.Unlock()
+
+	return g.HTTPTransport.Stop(ctx)
+}
+
+// client returns a client for the process of the node at the address, reusing its connection
+func (g *GRPCTransport) client(addr string) (dhtpb.NodeClient, error) {
+	addr, _ = dht.SplitVnode(addr)
+
+	g.connsMu.Lock()
+	defer g.connsMu.Unlock()
+
+	conn, ok := g.conns[addr]
+	if !ok {
+		creds := insecure.NewCredentials()
+		if g.clientTLS != nil {
+			creds = credentials.NewTLS(g.clientTLS)
+		}
+
+		var err error
+		conn, err = grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create grpc client for %s: %w", addr, err)
+		}
+		g.conns[addr] = conn
+	}
+	return dhtpb.NewNodeClient(conn), nil
+}
+
+// call runs the RPC against the node at the address with the call deadline, cancelled with ctx. A vnode is addressed
+// through the call's metadata
+func call[T any](ctx context.Context, g *GRPCTransport, addr string, rpc func(ctx context.Context, client dhtpb.NodeClient) (T, error)) (T, error) {
+	var zero T
+
+	client, err := g.client(addr)
+	if err != nil {
+		return zero, err
+	}
+
+	// Same deadline as the fast HTTP client
+	ctx, cancel := context.WithTimeout(ctx, g.fastClient.Timeout)
+	defer cancel()
+	if _, vnode := dht.SplitVnode(addr); vnode > 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, vnodeMetadata, strconv.Itoa(vnode))
+	}
+	reply, err := rpc(ctx, client)
+	g.observeGRPC(err)
+	return reply, err
+}
+
+// CheckAlive checks if the node at the given address is alive
+func (g *GRPCTransport) CheckAlive(ctx context.Context, targetAddr string) (bool, error) {
+	reply, err := call(ctx, g, targetAddr, func(ctx context.Context, client dhtpb.NodeClient) (*dhtpb.PingReply, error) {
+		return client.Ping(ctx, &dhtpb.Empty{})
+	})
+	if err != nil {
+		return false, err
+	}
+	return reply.GetAddress() != "", nil
+}
+
+// CheckAliveExpecting checks if the node at the given address is alive and has the expected id
+func (g *GRPCTransport) CheckAliveExpecting(ctx context.Context, targetAddr string, expectedId int) (bool, error) {
+	reply, err := call(ctx, g, targetAddr, func(ctx context.Context, client dhtpb.NodeClient) (*dhtpb.PingReply, error) {
+		return client.Ping(ctx, &dhtpb.Empty{})
+	})
+	if err != nil {
+		return false, err
+	}
+	return checkIdentity(targetAddr, expectedId, reply.GetAddress(), int(reply.GetId()))
+}
+
+// GetPredecessor gets the predecessor of the node
+func (g *GRPCTransport) GetPredecessor(ctx context.Context, targetAddr string) (string, error) {
+	reply, err := call(ctx, g, targetAddr, func(ctx context.Context, client dhtpb.NodeClient) (*dhtpb.NodeReply, error) {
+		return client.GetPredecessor(ctx, &dhtpb.Empty{})
+	})
+	if err != nil {
+		return "", err
+	}
+	return reply.GetAddress(), nil
+}
+
+// Notify notifies the node at the given address that it might have a new predecessor
+func (g *GRPCTransport) Notify(ctx context.Context, targetAddr string, newPredecessor string) error {
+	_, err := call(ctx, g, targetAddr, func(ctx context.Context, client dhtpb.NodeClient) (*dhtpb.Empty, error) {
+		return client.Notify(ctx, &dhtpb.NodeRequest{Address: newPredecessor})
+	})
+	return err
+}
+
+// SetPredecessor instructs the node at the given address that it has a new predecessor
+func (g *GRPCTransport) SetPredecessor(ctx context.Context, targetAddr string, newPredecessor string) error {
+	_, err := call(ctx, g, targetAddr, func(ctx context.Context, client dhtpb.NodeClient) (*dhtpb.Empty, error) {
+		return client.SetPredecessor(ctx, &dhtpb.NodeRequest{Address: newPredecessor})
+	})
+	return err
+}
+
+// SetSuccessor instructs the node at the given address that it has a new successor
+func (g *GRPCTransport) SetSuccessor(ctx context.Context, targetAddr string, newSuccessor string) error {
+	_, err := call(ctx, g, targetAddr, func(ctx context.Context, client dhtpb.NodeClient) (*dhtpb.Empty, error) {
+		return client.SetSuccessor(ctx, &dhtpb.NodeRequest{Address: newSuccessor})
+	})
+	return err
+}
+
+// FindSuccessor finds the successor of the key through the node at the given address
+func (g *GRPCTransport) FindSuccessor(ctx context.Context, addr string, keyId int) (string, error) {
+	reply, err := call(ctx, g, addr, func(ctx context.Context, client dhtpb.NodeClient) (*dhtpb.NodeReply, error) {
+		return client.FindSuccessor(ctx, &dhtpb.FindSuccessorRequest{KeyId: int64(keyId)})
+	})
+	if err != nil {
+		return "", err
+	}
+	return reply.GetAddress(), nil
+}
+
+// GetSuccessorList gets the successor list of the node
+func (g *GRPCTransport) GetSuccessorList(addr string) ([]string, error) {
+	reply, err := call(context.Background(), g, addr, func(ctx context.Context, client dhtpb.NodeClient) (*dhtpb.SuccessorListReply, error) {
+		return client.GetSuccessorList(ctx, &dhtpb.Empty{})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reply.GetAddresses(), nil
+}
+
+// ClosestPreceding gets the closest preceding node of the key and the successor of the node
+func (g *GRPCTransport) ClosestPreceding(addr string, keyId int) (string, string, error) {
+	reply, err := call(context.Background(), g, addr, func(ctx context.Context, client dhtpb.NodeClient) (*dhtpb.ClosestPrecedingReply, error) {
+		return client.ClosestPreceding(ctx, &dhtpb.FindSuccessorRequest{KeyId: int64(keyId)})
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return reply.GetClosest(), reply.GetSuccessor(), nil
+}
+
+// --------- gRPC SERVER ---------
+
+// grpcNodeServer serves the ring RPCs of the vnodes, the gRPC counterpart of the HTTP rpc handlers
+type grpcNodeServer struct {
+	dhtpb.UnimplementedNodeServer
+	transport *HTTPTransport
+}
+
+// node returns the vnode the call is addressed to, the primary node if its metadata names none
+func (s *grpcNodeServer) node(ctx context.Context) dht.INode {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(vnodeMetadata); len(values) > 0 {
+			if i, ok := s.transport.parseVnode(values[0]); ok {
+				ctx = withVnode(ctx, i)
+			}
+		}
+	}
+	return s.transport.vnode(ctx)
+}
+
+func (s *grpcNodeServer) Ping(ctx context.Context, _ *dhtpb.Empty) (*dhtpb.PingReply, error) {
+	node := s.node(ctx)
+	return &dhtpb.PingReply{Address: node.Address(), Id: int64(node.Id())}, nil
+}
+
+func (s *grpcNodeServer) GetPredecessor(ctx context.Context, _ *dhtpb.Empty) (*dhtpb.NodeReply, error) {
+	_, predecessorAddr := s.node(ctx).Predecessor()
+	return &dhtpb.NodeReply{Address: predecessorAddr}, nil
+}
+
+func (s *grpcNodeServer) Notify(ctx context.Context, req *dhtpb.NodeRequest) (*dhtpb.Empty, error) {
+	s.node(ctx).Notify(req.GetAddress())
+	return &dhtpb.Empty{}, nil
+}
+
+func (s *grpcNodeServer) SetPredecessor(ctx context.Context, req *dhtpb.NodeRequest) (*dhtpb.Empty, error) {
+	s.node(ctx).SetPredecessor(req.GetAddress(), true)
+	return &dhtpb.Empty{}, nil
+}
+
+func (s *grpcNodeServer) SetSuccessor(ctx context.Context, req *dhtpb.NodeRequest) (*dhtpb.Empty, error) {
+	s.node(ctx).SetSuccessor(req.GetAddress())
+	return &dhtpb.Empty{}, nil
+}
+
+func (s *grpcNodeServer) FindSuccessor(ctx context.Context, req *dhtpb.FindSuccessorRequest) (*dhtpb.NodeReply, error) {
+	successorAddr, err := s.node(ctx).FindSuccessor(ctx, int(req.GetKeyId()))
+	if err != nil {
+		return nil, err
+	}
+	return &dhtpb.NodeReply{Address: successorAddr}, nil
+}
+
+func (s *grpcNodeServer) GetSuccessorList(ctx context.Context, _ *dhtpb.Empty) (*dhtpb.SuccessorListReply, error) {
+	return &dhtpb.SuccessorListReply{Addresses: s.node(ctx).SuccessorList()}, nil
+}
+
+func (s *grpcNodeServer) ClosestPreceding(ctx context.Context, req *dhtpb.FindSuccessorRequest) (*dhtpb.ClosestPrecedingReply, error) {
+	closest, successor := s.node(ctx).ClosestPreceding(int(req.GetKeyId()))
+	return &dhtpb.ClosestPrecedingReply{Closest: closest, Successor: successor}, nil
+}
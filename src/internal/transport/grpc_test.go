@@ -0,0 +1,66 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"assignment/internal/dht"
+	"assignment/internal/transport/dhtpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// pingBoth pings the node over HTTP and over gRPC, returning the HTTP status and the gRPC code
+func pingBoth(t *testing.T, tr *HTTPTransport, client dhtpb.NodeClient) (int, codes.Code) {
+	t.Helper()
+	w := serve(tr, http.MethodGet, "/ping", nil, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := client.Ping(ctx, &dhtpb.Empty{})
+	return w.Code, status.Code(err)
+}
+
+func TestCrashRefusesHTTPAndGRPC(t *testing.T) {
+	tr := newTestTransport(t, dht.Create("127.0.0.1:1", dht.WithLogger(quietLogger())))
+	tr.markActive()
+	g := NewGRPC(tr)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	go func() { _ = g.grpcServer.Serve(lis) }()
+	defer g.grpcServer.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	defer conn.Close()
+	client := dhtpb.NewNodeClient(conn)
+
+	if code, grpcCode := pingBoth(t, tr, client); code != http.StatusOK || grpcCode != codes.OK {
+		t.Fatalf("ping before the crash = %d / %v, want 200 / OK", code, grpcCode)
+	}
+
+	if w := serve(tr, http.MethodPost, "/sim-crash", nil, nil); w.Code != http.StatusOK {
+		t.Fatalf("POST /sim-crash: status %d: %s", w.Code, w.Body)
+	}
+	if code, grpcCode := pingBoth(t, tr, client); code != http.StatusServiceUnavailable || grpcCode != codes.Unavailable {
+		t.Errorf("ping while crashed = %d / %v, want 503 / Unavailable", code, grpcCode)
+	}
+
+	if w := serve(tr, http.MethodPost, "/sim-recover", nil, nil); w.Code != http.StatusOK {
+		t.Fatalf("POST /sim-recover: status %d: %s", w.Code, w.Body)
+	}
+	if code, grpcCode := pingBoth(t, tr, client); code != http.StatusOK || grpcCode != codes.OK {
+		t.Errorf("ping after the recovery = %d / %v, want 200 / OK", code, grpcCode)
+	}
+}
@@ -0,0 +1,91 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// healthStatus is the JSON body of a "/healthz" or "/readyz" response
+type healthStatus struct {
+	Status  string   `json:"status"` // "ok", "ready" or "not_ready"
+	State   string   `json:"state"`
+	Reasons []string `json:"reasons,omitempty"` // why the node is not ready
+}
+
+// handleHealthz handles GET requests to the "/healthz" path
+// Liveness: answers 200 whenever the process serves requests, also while crashed or left, those
+// are states of the node in the ring and not of the process.
+func (t *HTTPTransport) handleHealthz(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	t.writeHealth(w, http.StatusOK, healthStatus{Status: "ok", State: t.currentState()})
+}
+
+// handleReadyz handles GET requests to the "/readyz" path
+// Readiness: answers 200 once the node is part of a formed ring, i.e. it is active and not
+// joining, has a successor and a predecessor other than itself and a finger pointing at another
+// node. Otherwise 503 with the reasons. A node alone in its ring is not ready, e.g. one that was
+// started but not joined yet.
+func (t *HTTPTransport) handleReadyz(w http.ResponseWriter, r *http.Request) {
+
+	node := t.vnode(r.Context())
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	state := t.currentState()
+	var reasons []string
+	if state != stateActive {
+		reasons = append(reasons, fmt.Sprintf("node is %s", state))
+	}
+	if t.warming.Load() {
+		reasons = append(reasons, "joining the ring")
+	}
+
+	self := node.Address()
+	if _, successorAddr := node.Successor(); successorAddr == "" || successorAddr == self {
+		reasons = append(reasons, "successor is self")
+	}
+	if _, predecessorAddr := node.Predecessor(); predecessorAddr == "" || predecessorAddr == self {
+		reasons = append(reasons, "predecessor unknown")
+	}
+	peerFinger := false
+	for _, addr := range node.FingerTable() {
+		if addr != "" && addr != self {
+			peerFinger = true
+			break
+		}
+	}
+	if !peerFinger {
+		reasons = append(reasons, "finger table holds no other node")
+	}
+
+	if len(reasons) > 0 {
+		t.writeHealth(w, http.StatusServiceUnavailable, healthStatus{Status: "not_ready", State: state, Reasons: reasons})
+		return
+	}
+	t.writeHealth(w, http.StatusOK, healthStatus{Status: "ready", State: state})
+}
+
+// currentState returns the state of the node as reported in /stats
+func (t *HTTPTransport) currentState() string {
+	t.stats.mu.Lock()
+	defer t.stats.mu.Unlock()
+	return t.stats.currentState
+}
+
+// writeHealth writes the health status with the status code
+func (t *HTTPTransport) writeHealth(w http.ResponseWriter, code int, status healthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		t.logger.Error("Health", "failed to encode health status", "err", err)
+	}
+}
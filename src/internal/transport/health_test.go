@@ -0,0 +1,66 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"assignment/internal/dht"
+)
+
+// getHealth returns the status code and body of a health probe
+func getHealth(t *testing.T, tr *HTTPTransport, path string) (int, healthStatus) {
+	t.Helper()
+	w := serve(tr, http.MethodGet, path, nil, nil)
+	var status healthStatus
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatalf("failed to decode %s: %v", path, err)
+	}
+	return w.Code, status
+}
+
+func TestReadyzOnceStabilized(t *testing.T) {
+	net := dht.NewMemoryNetwork()
+	first := dht.Create("node-a", dht.WithLogger(quietLogger()))
+	net.Add(first)
+	tr := newTestTransport(t, first)
+	tr.markActive()
+	ctx := context.Background()
+
+	code, status := getHealth(t, tr, "/readyz")
+	if code != http.StatusServiceUnavailable || len(status.Reasons) != 3 {
+		t.Fatalf("/readyz of a fresh node = %d %+v, want 503 with the successor, predecessor and fingers failing", code, status)
+	}
+	if code, _ := getHealth(t, tr, "/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz of a fresh node = %d, want 200", code)
+	}
+
+	joining := dht.Create("node-b", dht.WithLogger(quietLogger()))
+	net.Add(joining)
+	if err := joining.Join(first.Address()); err != nil {
+		t.Fatalf("Join: %v", err)
+	}
+	for range 3 {
+		for _, node := range []*dht.Node{first, joining} {
+			node.Stabilize(ctx)
+			node.FixAllFingers(ctx)
+		}
+	}
+	if code, status := getHealth(t, tr, "/readyz"); code != http.StatusOK || status.Status != "ready" {
+		t.Errorf("/readyz once stabilized = %d %+v, want 200 ready", code, status)
+	}
+
+	// Crashed, the process is still live but the node is not ready
+	if err := tr.simCrash(); err != nil {
+		t.Fatalf("simCrash: %v", err)
+	}
+	if code, _ := getHealth(t, tr, "/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz while crashed = %d, want 200", code)
+	}
+	code, status = getHealth(t, tr, "/readyz")
+	if code != http.StatusServiceUnavailable || !slices.Contains(status.Reasons, "node is crashed") {
+		t.Errorf("/readyz while crashed = %d %+v, want 503 \"node is crashed\"", code, status)
+	}
+}
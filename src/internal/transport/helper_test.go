@@ -0,0 +1,80 @@
+package transport
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"assignment/internal/dht"
+	"assignment/internal/logging"
+)
+
+// quietLogger returns a logger discarding everything
+func quietLogger() logging.Logger {
+	return logging.NewText(log.New(io.Discard, "", 0))
+}
+
+// newTestTransport returns a transport serving the node without listening, requests are passed
+// to its handler with serve. The node keeps its own transport, e.g. of a dht.MemoryNetwork.
+func newTestTransport(t *testing.T, node dht.INode, opts ...Option) *HTTPTransport {
+	t.Helper()
+	opts = append([]Option{WithLogger(quietLogger())}, opts...)
+	tr, err := New("127.0.0.1", "1", node, opts...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return tr
+}
+
+// serve passes the request to the handler of the transport and returns the recorded response
+func serve(tr *HTTPTransport, method string, target string, body []byte, header http.Header) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	r := httptest.NewRequest(method, target, reader)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	tr.server.Handler.ServeHTTP(w, r)
+	return w
+}
+
+// startNode serves a new node on a free port until the end of the test, over https if the options
+// configure TLS. Its maintenance is not started, tests run the rounds they need.
+func startNode(t *testing.T, opts ...Option) (*dht.Node, *HTTPTransport) {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+
+	host, port, _ := net.SplitHostPort(lis.Addr().String())
+	node := dht.Create(lis.Addr().String(), dht.WithLogger(quietLogger()))
+	tr, err := New(host, port, node, append([]Option{WithLogger(quietLogger())}, opts...)...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	tr.server.ErrorLog = log.New(io.Discard, "", 0) // e.g. handshakes refused by a test
+	node.SetTransport(tr)
+	tr.markActive()
+
+	if tr.server.TLSConfig != nil {
+		lis = tls.NewListener(lis, tr.server.TLSConfig)
+	}
+	go func() { _ = tr.server.Serve(lis) }()
+	// Closed rather than shut down, a graceful shutdown waits on connections just opened by the
+	// maintenance of other nodes
+	t.Cleanup(func() {
+		_ = tr.server.Close()
+		tr.pool.CloseIdleConnections()
+	})
+	return node, tr
+}
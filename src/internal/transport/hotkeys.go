@@ -0,0 +1,52 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"assignment/internal/dht"
+)
+
+// Hot keys returned by "/hot-keys" without "?top="
+const defaultHotKeys = 10
+
+// hotKeysResult is the JSON body of a "/hot-keys" response
+type hotKeysResult struct {
+	Node string          `json:"node"`
+	Keys []dht.KeyAccess `json:"keys"`
+}
+
+// handleHotKeys handles GET requests to the "/hot-keys" path
+// Returns the most accessed keys this node owns with their get and put counts, "?top=" of them.
+// Local to the node, never forwarded.
+func (t *HTTPTransport) handleHotKeys(w http.ResponseWriter, r *http.Request) {
+
+	node := t.vnode(r.Context())
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	top := defaultHotKeys
+	if topStr := r.URL.Query().Get("top"); topStr != "" {
+		var err error
+		if top, err = strconv.Atoi(topStr); err != nil || top <= 0 {
+			http.Error(w, fmt.Sprintf("invalid top '%s', must be a positive integer", topStr), http.StatusBadRequest)
+			return
+		}
+	}
+
+	result := hotKeysResult{
+		Node: node.Address(),
+		Keys: node.HotKeys(top),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode hot keys: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
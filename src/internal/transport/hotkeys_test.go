@@ -0,0 +1,50 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"assignment/internal/dht"
+)
+
+func TestHotKeysReportsMostAccessedKey(t *testing.T) {
+	node := dht.Create("127.0.0.1:1", dht.WithLogger(quietLogger()), dht.WithHotKeyCapacity(4))
+	tr := newTestTransport(t, node)
+
+	for i := range 30 {
+		if w := serve(tr, http.MethodPut, "/storage/hot", []byte(fmt.Sprintf("v%d", i)), nil); w.Code != http.StatusOK {
+			t.Fatalf("PUT /storage/hot: status %d: %s", w.Code, w.Body)
+		}
+		if w := serve(tr, http.MethodGet, "/storage/hot", nil, nil); w.Code != http.StatusOK {
+			t.Fatalf("GET /storage/hot: status %d: %s", w.Code, w.Body)
+		}
+	}
+
+	// More distinct keys than the counter holds, the hot key must not be evicted by them
+	for i := range 40 {
+		if w := serve(tr, http.MethodPut, fmt.Sprintf("/storage/cold-%d", i), []byte("v"), nil); w.Code != http.StatusOK {
+			t.Fatalf("PUT cold-%d: status %d: %s", i, w.Code, w.Body)
+		}
+	}
+
+	w := serve(tr, http.MethodGet, "/hot-keys?top=3", nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /hot-keys: status %d: %s", w.Code, w.Body)
+	}
+	var result hotKeysResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode hot keys: %v", err)
+	}
+	if len(result.Keys) == 0 || len(result.Keys) > 3 {
+		t.Fatalf("hot keys = %+v, want 1 to 3 keys", result.Keys)
+	}
+	if top := result.Keys[0]; top.Key != "hot" || top.Gets != 30 || top.Puts != 30 {
+		t.Errorf("hottest key = %+v, want \"hot\" with 30 gets and 30 puts", top)
+	}
+
+	if w := serve(tr, http.MethodGet, "/hot-keys?top=x", nil, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("GET /hot-keys?top=x: status %d, want 400", w.Code)
+	}
+}
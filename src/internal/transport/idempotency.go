@@ -0,0 +1,105 @@
+package transport
+
+import (
+	"sync"
+	"time"
+)
+
+// Header carrying the client's idempotency key of a PUT, kept when forwarding it
+const idempotencyKeyHeader = "X-Idempotency-Key"
+
+// Header set on the response to a PUT whose idempotency key was already applied
+const idempotentReplayHeader = "X-Idempotent-Replay"
+
+// How long the owner remembers an applied idempotency key
+const idempotencyTTL = 5 * time.Minute
+
+// Most idempotency keys remembered at once, the oldest are forgotten first
+const idempotencyCapacity = 10000
+
+// idempotencyEntry is the state of one idempotency key of a storage key
+type idempotencyEntry struct {
+	expires time.Time
+	pending bool   // the first request is still being applied
+	status  int    // response status of the applied request
+	seq     uint64 // position in the queue, a key released and reserved again is queued anew
+}
+
+// queuedKey is an entry of the eviction queue
+type queuedKey struct {
+	cacheKey string
+	seq      uint64
+}
+
+// idempotencyCache remembers the PUTs applied by this node as owner, keyed by storage key and
+// idempotency key, so a retried forward returns the prior result instead of storing again.
+// Entries are kept in insertion order and all expire after idempotencyTTL, so the oldest are
+// at the front of the queue and are dropped first, both once expired and above the capacity.
+type idempotencyCache struct {
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+	order   []queuedKey
+	seq     uint64
+}
+
+// idempotencyCacheKey scopes an idempotency key to the storage key it was sent with
+func idempotencyCacheKey(key string, idempotencyKey string) string {
+	return key + "\x00" + idempotencyKey
+}
+
+// begin reserves the idempotency key of a PUT before it is applied. Returns the status of the
+// applied request and true for a duplicate, pending is true if the first request is still in progress.
+func (c *idempotencyCache) begin(key string, idempotencyKey string) (status int, duplicate bool, pending bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.evict(now)
+
+	cacheKey := idempotencyCacheKey(key, idempotencyKey)
+	if entry, ok := c.entries[cacheKey]; ok && now.Before(entry.expires) {
+		return entry.status, true, entry.pending
+	}
+
+	if c.entries == nil {
+		c.entries = make(map[string]*idempotencyEntry)
+	}
+	c.seq++
+	c.entries[cacheKey] = &idempotencyEntry{expires: now.Add(idempotencyTTL), pending: true, seq: c.seq}
+	c.order = append(c.order, queuedKey{cacheKey: cacheKey, seq: c.seq})
+	return 0, false, false
+}
+
+// complete records the status of the applied request, later duplicates get it back
+func (c *idempotencyCache) complete(key string, idempotencyKey string, status int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[idempotencyCacheKey(key, idempotencyKey)]; ok {
+		entry.pending = false
+		entry.status = status
+	}
+}
+
+// release forgets a reservation whose request was not applied here, e.g. it failed or was
+// forwarded to another owner, so a retry is applied again
+func (c *idempotencyCache) release(key string, idempotencyKey string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, idempotencyCacheKey(key, idempotencyKey))
+}
+
+// evict drops the expired entries and the oldest ones at the capacity, c.mu must be held
+func (c *idempotencyCache) evict(now time.Time) {
+	for len(c.order) > 0 {
+		queued := c.order[0]
+		entry, ok := c.entries[queued.cacheKey]
+		current := ok && entry.seq == queued.seq
+		if current && now.Before(entry.expires) && len(c.order) < idempotencyCapacity {
+			return
+		}
+		if current {
+			delete(c.entries, queued.cacheKey)
+		}
+		c.order = c.order[1:]
+	}
+}
@@ -0,0 +1,81 @@
+package transport
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"assignment/internal/dht"
+)
+
+// putIdempotent sends a PUT of the key to the transport, with the idempotency key if not empty
+func putIdempotent(tr *HTTPTransport, key string, value string, idempotencyKey string) (int, bool) {
+	header := http.Header{}
+	if idempotencyKey != "" {
+		header.Set(idempotencyKeyHeader, idempotencyKey)
+	}
+	w := serve(tr, http.MethodPut, "/storage/"+key, []byte(value), header)
+	return w.Code, w.Header().Get(idempotentReplayHeader) == "true"
+}
+
+func TestIdempotentPutReplaysAfterAnotherWrite(t *testing.T) {
+	node := dht.Create("127.0.0.1:1", dht.WithLogger(quietLogger()))
+	tr := newTestTransport(t, node)
+
+	if status, replayed := putIdempotent(tr, "key", "first", "request-1"); status != http.StatusOK || replayed {
+		t.Fatalf("first PUT = %d, replayed %v, want 200 applied", status, replayed)
+	}
+	if status, _ := putIdempotent(tr, "key", "second", ""); status != http.StatusOK {
+		t.Fatalf("PUT in between = %d, want 200", status)
+	}
+
+	// The retry of the first request gets its result back and doesn't overwrite the later write
+	if status, replayed := putIdempotent(tr, "key", "first", "request-1"); status != http.StatusOK || !replayed {
+		t.Errorf("replayed PUT = %d, replayed %v, want the prior 200 replayed", status, replayed)
+	}
+	value, _, err := node.Get("key")
+	if err != nil || string(value.Value) != "second" || value.Version != 2 {
+		t.Errorf("key = %q at version %d, %v, want \"second\" at version 2", value.Value, value.Version, err)
+	}
+
+	// The idempotency key is scoped to the storage key
+	if status, replayed := putIdempotent(tr, "other", "value", "request-1"); status != http.StatusOK || replayed {
+		t.Errorf("PUT of another key with the same idempotency key = %d, replayed %v, want 200 applied", status, replayed)
+	}
+}
+
+func TestIdempotentPutReplaysThroughForward(t *testing.T) {
+	nodes := dht.BuildRing(2)
+	ring := make(map[string]*HTTPTransport)
+	for _, node := range nodes {
+		ring[node.Address()] = newTestTransport(t, node)
+	}
+	routeForwards(ring)
+	entry := ring[nodes[0].Address()]
+	key := forwardedKey(t, nodes[0])
+	owner := nodes[1]
+	if ownerAddr := nodes[0].NextHop(key); ownerAddr != owner.Address() {
+		t.Fatalf("key forwarded to %s, want %s", ownerAddr, owner.Address())
+	}
+
+	if status, replayed := putIdempotent(entry, key, "first", "request-1"); status != http.StatusOK || replayed {
+		t.Fatalf("forwarded PUT = %d, replayed %v, want 200 applied", status, replayed)
+	}
+	if status, _ := putIdempotent(ring[owner.Address()], key, "second", ""); status != http.StatusOK {
+		t.Fatalf("PUT in between = %d, want 200", status)
+	}
+
+	// The owner remembers the key, a retry through the entry node or straight to it is a replay
+	for _, tr := range []*HTTPTransport{entry, ring[owner.Address()]} {
+		if status, replayed := putIdempotent(tr, key, "first", "request-1"); status != http.StatusOK || !replayed {
+			t.Errorf("replayed PUT through %s = %d, replayed %v, want the prior 200 replayed", tr.Address(), status, replayed)
+		}
+	}
+	value, _, err := owner.Get(key)
+	if err != nil || string(value.Value) != "second" || value.Version != 2 {
+		t.Errorf("key on the owner = %q at version %d, %v, want \"second\" at version 2", value.Value, value.Version, err)
+	}
+	if slices.Contains(nodes[0].LocalKeys(), key) {
+		t.Error("the entry node stored the forwarded key")
+	}
+}
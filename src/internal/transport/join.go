@@ -0,0 +1,169 @@
+package transport
+
+import (
+	"assignment/internal/dht"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff between startup join attempts, doubled after every failed attempt up to the max.
+// Each wait is jittered, so nodes started together don't hit the seed in lockstep.
+const (
+	minJoinBackoff = 250 * time.Millisecond
+	maxJoinBackoff = 5 * time.Second
+)
+
+// errSeedUnreachable is returned by Join if the ring node to join through could not be asked for a successor
+var errSeedUnreachable = errors.New("ring node unreachable")
+
+// Join joins the ring through nprime: the successor is looked up through nprime, then the node
+// links in front of it, pulls the keys it now owns and notifies it, see dht.Node.Join. Every vnode
+// of the process joins in turn, a vnode that is nprime itself is already in the ring. The node is
+// marked active on success.
+func (t *HTTPTransport) Join(nprime string) error {
+
+	for _, node := range t.vnodes {
+		if node.Address() == nprime {
+			continue
+		}
+		if err := t.joinVnode(node, nprime); err != nil {
+			return err
+		}
+	}
+
+	// Set the node to active so it starts processing requests.
+	t.markActive()
+	return nil
+}
+
+// joinVnode joins the ring through nprime with one vnode of the process
+func (t *HTTPTransport) joinVnode(node dht.INode, nprime string) error {
+
+	// Find the successor the loner node from nprime
+	successorAddress, err := t.FindSuccessor(context.Background(), nprime, node.Id())
+	if err != nil {
+		return fmt.Errorf("%w: failed to find successor through %s: %w", errSeedUnreachable, nprime, err)
+	}
+
+	t.logger.Info("Join", "successor found, joining in front of it", "vnode", node.Address(), "successor", successorAddress)
+
+	// The successor link is set even if the rest fails, the maintenance goroutine then completes the join
+	err = node.Join(successorAddress)
+	if errors.Is(err, dht.ErrIdCollision) {
+		return err
+	}
+	if err != nil {
+		t.logger.Warn("Join", "join incomplete, relying on maintenance", "vnode", node.Address(), "successor", successorAddress, "err", err)
+	}
+	return nil
+}
+
+// Leave makes every vnode of the process "plug" the hole in the ring and return to starting
+// state, see dht.Node.Leave, and marks the node as left. Storage requests are refused from the
+// start, the node keeps answering ring RPCs and only turns inactive once every vnode has left and
+// its link updates returned. The result is the primary node's, incomplete if a neighbour of any vnode missed the link
+// updates. left is false if the node had already left, a repeated leave is a no-op. If a vnode
+// fails to leave, the vnodes that already left rejoin the ring through it and the node is active
+// again, so the process never stays in the ring with only some of its vnodes.
+func (t *HTTPTransport) Leave() (result dht.LeaveResult, left bool, err error) {
+
+	started, err := t.beginLeave()
+	if err != nil {
+		t.logger.Warn("Leave", "leave refused", "err", err)
+		return dht.LeaveResult{}, false, err
+	}
+	if !started {
+		t.logger.Info("Leave", "already left the ring, ignoring")
+		return dht.LeaveResult{}, false, nil
+	}
+
+	for i, node := range t.vnodes {
+		vnodeResult, err := node.Leave()
+		if err != nil {
+			// Leave was aborted, the vnode is still part of the ring
+			t.logger.Error("Leave", "leave failed, resuming", "vnode", node.Address(), "err", err)
+			if rollbackErr := t.rejoinVnodes(node, t.vnodes[:i]); rollbackErr != nil {
+				err = errors.Join(err, rollbackErr)
+			}
+			t.endLeave(false)
+			return dht.LeaveResult{}, false, fmt.Errorf("failed to leave: %w", err)
+		}
+		if i == 0 {
+			result = vnodeResult
+		}
+		result.SuccessorNotified = result.SuccessorNotified && vnodeResult.SuccessorNotified
+		result.PredecessorNotified = result.PredecessorNotified && vnodeResult.PredecessorNotified
+	}
+
+	// Only marked as left once the link updates returned
+	t.endLeave(true)
+	if !result.Complete() {
+		t.logger.Warn("Leave", "left the ring, but not all neighbours were notified", "successor_notified", result.SuccessorNotified, "predecessor_notified", result.PredecessorNotified)
+	}
+	return result, true, nil
+}
+
+// rejoinVnodes joins the vnodes that already left back into the ring through member, a vnode of
+// the process that is still part of it. Each vnode links in front of its old successor again and
+// pulls the keys it handed off. A vnode that fails to rejoin is left to the maintenance goroutine
+// if its successor link is set, and reported in the error otherwise.
+func (t *HTTPTransport) rejoinVnodes(member dht.INode, left []dht.INode) error {
+
+	var errs []error
+	for _, node := range left {
+		successorAddress, err := member.FindSuccessor(context.Background(), node.Id())
+		if err != nil {
+			t.logger.Error("Leave", "failed to find successor to rejoin", "vnode", node.Address(), "err", err)
+			errs = append(errs, fmt.Errorf("vnode %s did not rejoin: %w", node.Address(), err))
+			continue
+		}
+		if err := node.Join(successorAddress); err != nil {
+			t.logger.Warn("Leave", "rejoin incomplete, relying on maintenance", "vnode", node.Address(), "successor", successorAddress, "err", err)
+			continue
+		}
+		t.logger.Info("Leave", "rejoined the ring after the failed leave", "vnode", node.Address(), "successor", successorAddress)
+	}
+	return errors.Join(errs...)
+}
+
+// JoinWithRetry repeatedly tries to join the ring through the seed with backoff until it succeeds
+// or the timeout elapses, so nodes can be started before their seed is up.
+func (t *HTTPTransport) JoinWithRetry(ctx context.Context, seed string, timeout time.Duration) error {
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Storage requests are refused until the node is in the ring
+	t.warming.Store(true)
+	defer t.warming.Store(false)
+
+	backoff := minJoinBackoff
+	for attempt := 1; ; attempt++ {
+		err := t.Join(seed)
+		if err == nil {
+			t.logger.Info("Join", "joined the ring through seed", "seed", seed, "attempts", attempt)
+			return nil
+		}
+		// Retrying leads to the same colliding successor
+		if errors.Is(err, dht.ErrIdCollision) {
+			return err
+		}
+		wait := jitter(backoff)
+		t.logger.Warn("Join", "join attempt through seed failed, retrying", "attempt", attempt, "seed", seed, "backoff", wait, "err", err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to join through %s after %d attempts: %w", seed, attempt, ctx.Err())
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, maxJoinBackoff)
+	}
+}
+
+// jitter returns a random duration in [d/2, d)
+func jitter(d time.Duration) time.Duration {
+	return d/2 + rand.N(d/2)
+}
@@ -0,0 +1,307 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"assignment/internal/dht"
+)
+
+// leaveObserver is a node that calls during before it leaves, while the transport is leaving
+type leaveObserver struct {
+	*dht.Node
+	during func()
+}
+
+func (o leaveObserver) Leave() (dht.LeaveResult, error) {
+	o.during()
+	return o.Node.Leave()
+}
+
+func TestLeaveWithUnreachableSuccessor(t *testing.T) {
+	net := dht.NewMemoryNetwork()
+	nodes := net.BuildRing(3)
+	leaving, successor := nodes[1], nodes[2]
+	net.Fail(successor.Address())
+
+	var tr *HTTPTransport
+	var inactiveDuring bool
+	var storageDuring *http.Response
+	tr = newTestTransport(t, leaveObserver{Node: leaving, during: func() {
+		inactiveDuring = tr.IsInactive()
+		storageDuring = serve(tr, http.MethodGet, "/storage/key", nil, nil).Result()
+	}})
+
+	w := serve(tr, http.MethodPost, "/leave", nil, nil)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("POST /leave: status %d, want 202: %s", w.Code, w.Body)
+	}
+	var result dht.LeaveResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode leave result: %v", err)
+	}
+	if result.SuccessorNotified || !result.PredecessorNotified {
+		t.Errorf("leave result = %+v, want only the successor not notified", result)
+	}
+
+	// Ring RPCs are served until the link updates returned, storage is refused
+	if inactiveDuring {
+		t.Error("node was inactive before its link updates returned")
+	}
+	if storageDuring.StatusCode != http.StatusServiceUnavailable || storageDuring.Header.Get(unavailableReasonHeader) != reasonQuiesced {
+		t.Errorf("storage while leaving: status %d reason %q, want 503 %q", storageDuring.StatusCode, storageDuring.Header.Get(unavailableReasonHeader), reasonQuiesced)
+	}
+	if !tr.IsInactive() {
+		t.Error("node is not inactive after the leave")
+	}
+	if state := tr.currentState(); state != stateLeft {
+		t.Errorf("state after the leave = %s, want %s", state, stateLeft)
+	}
+}
+
+// putOwned puts a key the node owns and returns it
+func putOwned(t *testing.T, node *dht.Node) string {
+	t.Helper()
+	for i := 0; ; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if node.NextHop(key) != "" {
+			continue
+		}
+		if _, _, err := node.Put(key, []byte("value"), 0); err != nil {
+			t.Fatalf("Put(%q): %v", key, err)
+		}
+		return key
+	}
+}
+
+func TestLeaveRollsBackVnodes(t *testing.T) {
+	net := dht.NewMemoryNetwork()
+	nodes := net.BuildRing(8)
+	primary, failing := nodes[0], nodes[2]
+	tr := newTestTransport(t, primary, WithVnodes(failing, nodes[4]))
+	tr.markActive()
+
+	// The primary leaves first, the second vnode can't hand off to its crashed successor
+	primaryKey := putOwned(t, primary)
+	putOwned(t, failing)
+	net.Fail(nodes[3].Address())
+
+	if w := serve(tr, http.MethodPost, "/leave", nil, nil); w.Code != http.StatusInternalServerError {
+		t.Fatalf("POST /leave: status %d, want 500: %s", w.Code, w.Body)
+	}
+
+	if state := tr.currentState(); state != stateActive || tr.IsInactive() {
+		t.Errorf("state after the failed leave = %s, inactive %v, want %s", state, tr.IsInactive(), stateActive)
+	}
+	if _, successor := primary.Successor(); successor != nodes[1].Address() {
+		t.Errorf("successor of the primary after the rollback = %q, want %s", successor, nodes[1].Address())
+	}
+	if _, _, err := primary.Get(primaryKey); err != nil {
+		t.Errorf("key %q not back on the primary: %v", primaryKey, err)
+	}
+
+	// The predecessor links to the primary again on its next stabilize round
+	nodes[7].Stabilize(context.Background())
+	if _, successor := nodes[7].Successor(); successor != primary.Address() {
+		t.Errorf("successor of the predecessor after the rollback = %q, want %s", successor, primary.Address())
+	}
+}
+
+// joinThrough sends POST /join through the seed to the transport and returns the status
+func joinThrough(tr *HTTPTransport, seed string) *httptest.ResponseRecorder {
+	return serve(tr, http.MethodPost, "/join?nprime="+seed, nil, nil)
+}
+
+// startRing serves n nodes joined through the first one, with a few maintenance rounds run so
+// the links and fingers are settled
+func startRing(t *testing.T, ctx context.Context, n int) ([]*dht.Node, []*HTTPTransport) {
+	t.Helper()
+	var nodes []*dht.Node
+	var transports []*HTTPTransport
+	for i := range n {
+		node, tr := startNode(t)
+		if i > 0 {
+			if w := joinThrough(tr, nodes[0].Address()); w.Code != http.StatusOK {
+				t.Fatalf("POST /join: status %d: %s", w.Code, w.Body)
+			}
+		}
+		nodes = append(nodes, node)
+		transports = append(transports, tr)
+	}
+	for range 3 {
+		for _, node := range nodes {
+			node.Stabilize(ctx)
+			node.FixAllFingers(ctx)
+		}
+	}
+	return nodes, transports
+}
+
+// keysIn returns count keys whose ring id is in (from, to], e.g. the range a node owns
+func keysIn(t *testing.T, from int, to int, count int) []string {
+	t.Helper()
+	var keys []string
+	for i := 0; len(keys) < count; i++ {
+		if i == 10_000_000 {
+			t.Fatalf("no %d keys found in (%d, %d]", count, from, to)
+		}
+		key := fmt.Sprintf("key-%d", i)
+		if dht.InIntervalRightInclusive(dht.KeyToRingId(key, dht.ID_SPACE_SIZE), from, to) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// putValues puts the keys through the transport and returns the values by key
+func putValues(t *testing.T, tr *HTTPTransport, keys []string) map[string]string {
+	t.Helper()
+	values := make(map[string]string)
+	for _, key := range keys {
+		value := "value of " + key
+		if w := serve(tr, http.MethodPut, "/storage/"+key, []byte(value), nil); w.Code != http.StatusOK {
+			t.Fatalf("PUT %q: status %d: %s", key, w.Code, w.Body)
+		}
+		values[key] = value
+	}
+	return values
+}
+
+// checkOwnedKeys checks the node serves the keys it owns itself, without a hop, and returns how many it owns
+func checkOwnedKeys(t *testing.T, node *dht.Node, tr *HTTPTransport, values map[string]string) int {
+	t.Helper()
+	owned := 0
+	for key, value := range values {
+		if node.NextHop(key) != "" {
+			continue
+		}
+		owned++
+		w := serve(tr, http.MethodGet, "/storage/"+key, nil, nil)
+		if w.Code != http.StatusOK || w.Body.String() != value || w.Header().Get(hopCountHeader) != "0" {
+			t.Errorf("GET %q on %s: status %d, body %q, hops %s, want %q served locally", key, node.Address(), w.Code, w.Body, w.Header().Get(hopCountHeader), value)
+		}
+	}
+	return owned
+}
+
+// waitForKeys runs the maintenance of the nodes and waits until every key is read back through
+// every transport, failing the test after 5s
+func waitForKeys(t *testing.T, ctx context.Context, nodes []*dht.Node, transports []*HTTPTransport, values map[string]string) {
+	t.Helper()
+	for _, node := range nodes {
+		go node.RunMaintenance(ctx)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		missing := ""
+		for key, value := range values {
+			for _, tr := range transports {
+				if w := serve(tr, http.MethodGet, "/storage/"+key, nil, nil); w.Code != http.StatusOK || w.Body.String() != value {
+					missing = fmt.Sprintf("GET %q through %s: status %d, body %q", key, tr.Address(), w.Code, w.Body)
+				}
+			}
+		}
+		if missing == "" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("keys not reachable after 5s: %s", missing)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestJoinPullsKeysFromRing(t *testing.T) {
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	nodes, transports := startRing(t, ctx, 3)
+	fresh, freshTransport := startNode(t)
+
+	// Keys all over the ring, and some in the range the fresh node will take over from its successor
+	predecessor := nodes[0]
+	for _, node := range nodes {
+		if dht.ClockwiseDistance(node.Id(), fresh.Id(), dht.ID_SPACE_SIZE) < dht.ClockwiseDistance(predecessor.Id(), fresh.Id(), dht.ID_SPACE_SIZE) {
+			predecessor = node
+		}
+	}
+	keys := append(keysIn(t, 0, dht.ID_SPACE_SIZE-1, 40), keysIn(t, predecessor.Id(), fresh.Id(), 5)...)
+	values := putValues(t, transports[0], keys)
+
+	// The fresh node holds the keys it owns as soon as the join returns, before any maintenance
+	if w := joinThrough(freshTransport, nodes[0].Address()); w.Code != http.StatusOK {
+		t.Fatalf("POST /join of the fresh node: status %d: %s", w.Code, w.Body)
+	}
+	if owned := checkOwnedKeys(t, fresh, freshTransport, values); owned == 0 {
+		t.Fatal("the fresh node owns none of the keys")
+	}
+
+	// Once maintenance ran, every key is reachable through every node within a bounded time
+	waitForKeys(t, ctx, append(nodes, fresh), append(transports, freshTransport), values)
+}
+
+func TestRejoinReacquiresKeyRange(t *testing.T) {
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	nodes, transports := startRing(t, ctx, 4)
+	rejoining, tr := nodes[2], transports[2]
+	id := rejoining.Id()
+	from, to := rejoining.OwnedRange()
+	values := putValues(t, transports[0], append(keysIn(t, 0, dht.ID_SPACE_SIZE-1, 40), keysIn(t, from, to, 5)...))
+
+	if w := serve(tr, http.MethodPost, "/rejoin?nprime="+nodes[0].Address(), nil, nil); w.Code != http.StatusConflict {
+		t.Errorf("POST /rejoin before leaving: status %d, want 409", w.Code)
+	}
+	if w := serve(tr, http.MethodPost, "/leave", nil, nil); w.Code != http.StatusOK {
+		t.Fatalf("POST /leave: status %d: %s", w.Code, w.Body)
+	}
+	if keys := rejoining.LocalKeys(); len(keys) != 0 {
+		t.Fatalf("keys %v kept after the leave", keys)
+	}
+	if w := serve(tr, http.MethodPost, "/rejoin?nprime="+rejoining.Address(), nil, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("POST /rejoin through itself: status %d, want 400", w.Code)
+	}
+
+	// The same node and transport join again and take their range of keys back
+	if w := serve(tr, http.MethodPost, "/rejoin?nprime="+nodes[0].Address(), nil, nil); w.Code != http.StatusOK {
+		t.Fatalf("POST /rejoin: status %d: %s", w.Code, w.Body)
+	}
+	if rejoining.Id() != id || tr.currentState() != stateActive {
+		t.Errorf("after the rejoin: id %d, state %s, want id %d and %s", rejoining.Id(), tr.currentState(), id, stateActive)
+	}
+	if owned := checkOwnedKeys(t, rejoining, tr, values); owned == 0 {
+		t.Fatal("the rejoined node owns none of the keys")
+	}
+	waitForKeys(t, ctx, nodes, transports, values)
+
+	w := serve(tr, http.MethodGet, "/stats", nil, nil)
+	var stats struct {
+		RejoinTotal int `json:"rejoin_total"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil || stats.RejoinTotal != 1 {
+		t.Errorf("rejoin_total = %d, %v, want 1", stats.RejoinTotal, err)
+	}
+}
+
+func TestJoinThroughUnreachableSeed(t *testing.T) {
+	_, tr := startNode(t)
+	unreachable, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	addr := unreachable.Addr().String()
+	unreachable.Close()
+
+	if w := joinThrough(tr, addr); w.Code != http.StatusBadGateway || !strings.Contains(w.Body.String(), addr) {
+		t.Errorf("POST /join through a closed port: status %d, body %q, want 502 naming the seed", w.Code, w.Body)
+	}
+	if w := joinThrough(tr, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("POST /join without nprime: status %d, want 400", w.Code)
+	}
+}
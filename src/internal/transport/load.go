@@ -0,0 +1,112 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Default most/least loaded key count ratio above which "/skew" reports the ring as unbalanced
+const DefaultSkewThreshold = 2.0
+
+// nodeLoad is the per-node result of a "/load" traversal
+type nodeLoad struct {
+	Node     string `json:"node"`
+	KeyCount int    `json:"key_count"`
+}
+
+// skewReport is the response of "/skew"
+type skewReport struct {
+	Nodes      []nodeLoad `json:"nodes"`
+	MaxKeys    int        `json:"max_keys"`
+	MinKeys    int        `json:"min_keys"`
+	Ratio      float64    `json:"ratio"`
+	Threshold  float64    `json:"threshold"`
+	Unbalanced bool       `json:"unbalanced"`
+}
+
+// handleLoad handles requests to the "/load" path
+// Returns the key count of every node, collected by walking the ring like "/network".
+func (t *HTTPTransport) handleLoad(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Flag the origin of the traversal
+	origin := r.URL.Query().Get("origin")
+	if origin == "" {
+		origin = t.vnode(r.Context()).Address() // first node
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(t.collectLoad(r.Context(), origin)); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode load: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// collectLoad returns the key count of this node followed by the nodes up to the origin
+func (t *HTTPTransport) collectLoad(ctx context.Context, origin string) []nodeLoad {
+
+	node := t.vnode(ctx)
+
+	loads := []nodeLoad{{Node: node.Address(), KeyCount: node.KeyCount()}}
+
+	_, succAdr := node.Successor()
+
+	// We keep forwarding the request until the ring is covered
+	if succAdr != origin && succAdr != node.Address() {
+		forwardURL := t.url(succAdr, "/load?origin="+url.QueryEscape(origin))
+		resp, err := t.forwardedRequest(ctx, http.MethodGet, forwardURL, nil)
+		if err == nil {
+			defer resp.Body.Close()
+			var succLoads []nodeLoad
+			if err := json.NewDecoder(resp.Body).Decode(&succLoads); err == nil {
+				loads = append(loads, succLoads...)
+			}
+		} else {
+			t.logger.Error("Load", "failed to forward load request to successor", "successor", succAdr, "err", err)
+		}
+	}
+
+	return loads
+}
+
+// handleSkew handles requests to the "/skew" path
+// Reports the ratio of the most to the least loaded node's key count, and whether it exceeds the threshold.
+// An empty node counts as one key so the ratio stays finite.
+func (t *HTTPTransport) handleSkew(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	loads := t.collectLoad(r.Context(), t.vnode(r.Context()).Address())
+
+	report := skewReport{
+		Nodes:     loads,
+		MinKeys:   loads[0].KeyCount,
+		Threshold: t.skewThreshold,
+	}
+	for _, load := range loads {
+		report.MaxKeys = max(report.MaxKeys, load.KeyCount)
+		report.MinKeys = min(report.MinKeys, load.KeyCount)
+	}
+	report.Ratio = float64(report.MaxKeys) / float64(max(report.MinKeys, 1))
+	report.Unbalanced = report.Ratio > report.Threshold
+
+	if report.Unbalanced {
+		t.logger.Warn("Skew", "ring is unbalanced, key count ratio exceeds threshold", "ratio", report.Ratio, "threshold", report.Threshold)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode skew: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,55 @@
+package transport
+
+import (
+	"assignment/internal/metrics"
+	"net/http"
+	"runtime"
+	"sync/atomic"
+)
+
+// In-flight client request cap applied above the goroutine soft limit when no cap is configured
+const overloadedMaxInFlight = 32
+
+// loadShedder caps the client requests handled at once and tightens the cap while
+// the process is above its goroutine soft limit, e.g. under churn with many stuck forwards
+type loadShedder struct {
+	maxInFlight        int // 0 for no cap below the soft limit
+	goroutineSoftLimit int // 0 to disable
+	inFlight           atomic.Int64
+	shedTotal          atomic.Int64
+}
+
+// overloaded reports whether the process is above the goroutine soft limit
+func (s *loadShedder) overloaded() bool {
+	return s.goroutineSoftLimit > 0 && runtime.NumGoroutine() > s.goroutineSoftLimit
+}
+
+// limit returns the current in-flight cap, 0 if unlimited
+// Above the soft limit the configured cap is quartered, or capped at overloadedMaxInFlight if none.
+func (s *loadShedder) limit() int {
+	if !s.overloaded() {
+		return s.maxInFlight
+	}
+	if s.maxInFlight == 0 {
+		return overloadedMaxInFlight
+	}
+	return max(s.maxInFlight/4, 1)
+}
+
+// shed wraps a client endpoint, refusing the request with 503 "overloaded" if the in-flight cap is reached
+func (t *HTTPTransport) shed(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inFlight := t.shedder.inFlight.Add(1)
+		defer t.shedder.inFlight.Add(-1)
+
+		if limit := t.shedder.limit(); limit > 0 && inFlight > int64(limit) {
+			t.shedder.shedTotal.Add(1)
+			metrics.ShedRequests.Inc()
+			w.Header().Set("Retry-After", "1")
+			refuseRequest(w, r, reasonOverloaded)
+			return
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,90 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+
+	"assignment/internal/dht"
+)
+
+// routeTraversals passes the traversal requests of every transport of the ring to the handler of
+// the transport they are addressed to
+func routeTraversals(ring map[string]*HTTPTransport) {
+	for _, tr := range ring {
+		tr.traversalClient.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			next := ring[r.URL.Host]
+			if next == nil {
+				return nil, fmt.Errorf("%s is not a node of the ring", r.URL.Host)
+			}
+			return serve(next, r.Method, r.URL.RequestURI(), nil, r.Header).Result(), nil
+		})
+	}
+}
+
+func TestNetworkSkipsCrashedNode(t *testing.T) {
+	nodes := dht.BuildRing(5)
+	ring := make(map[string]*HTTPTransport)
+	for _, node := range nodes {
+		ring[node.Address()] = newTestTransport(t, node)
+		ring[node.Address()].markActive()
+	}
+	routeTraversals(ring)
+	crashed := nodes[2]
+	if err := ring[crashed.Address()].simCrash(); err != nil {
+		t.Fatalf("simCrash: %v", err)
+	}
+
+	w := serve(ring[nodes[0].Address()], http.MethodGet, "/network", nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /network: status %d: %s", w.Code, w.Body)
+	}
+	var got []string
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode /network: %v", err)
+	}
+
+	var want []string
+	for _, node := range nodes {
+		if node != crashed {
+			want = append(want, node.Address())
+		}
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("/network with %s crashed = %v, want %v", crashed.Address(), got, want)
+	}
+}
+
+func TestPutNetwork(t *testing.T) {
+	const self = "127.0.0.1:8000"
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"valid", `["127.0.0.1:8000", "127.0.0.1:8001"]`, http.StatusOK},
+		{"empty", `[]`, http.StatusBadRequest},
+		{"missing self", `["127.0.0.1:8001"]`, http.StatusBadRequest},
+		{"malformed address", `["127.0.0.1:8000", "node-1"]`, http.StatusBadRequest},
+		{"not a list", `{"nodes": []}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := dht.Create(self, dht.WithLogger(quietLogger()))
+			tr := newTestTransport(t, node)
+			tr.markActive()
+
+			w := serve(tr, http.MethodPut, "/network", []byte(tt.body), nil)
+			if w.Code != tt.want {
+				t.Fatalf("PUT /network %s: status %d, want %d: %s", tt.body, w.Code, tt.want, w.Body)
+			}
+			_, successor := node.Successor()
+			if changed := successor != self; changed != (tt.want == http.StatusOK) {
+				t.Errorf("successor after PUT /network %s = %s", tt.body, successor)
+			}
+		})
+	}
+}
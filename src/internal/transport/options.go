@@ -0,0 +1,160 @@
+package transport
+
+import (
+	"assignment/internal/dht"
+	"assignment/internal/logging"
+	"time"
+)
+
+// Option configures optional behaviour of the transport in New
+type Option func(*HTTPTransport)
+
+// WithBenchmark enables the "/benchmark" load generator endpoint
+func WithBenchmark(enabled bool) Option {
+	return func(t *HTTPTransport) {
+		t.benchmarkEnabled = enabled
+	}
+}
+
+// WithAdmin enables the "/admin/links" endpoint forcing the links of a node, for test harnesses only
+func WithAdmin(enabled bool) Option {
+	return func(t *HTTPTransport) {
+		t.adminEnabled = enabled
+	}
+}
+
+// WithRootCrashExempt answers the "/" endpoint index also while the node is crashed or has left,
+// e.g. for health probes that only check the service responds. By default "/" is refused like
+// every other endpoint.
+func WithRootCrashExempt(exempt bool) Option {
+	return func(t *HTTPTransport) {
+		t.rootCrashExempt = exempt
+	}
+}
+
+// WithVnodes serves the virtual nodes of the process next to the node of New, one ring position
+// each. They share the listeners, a request is scoped to the vnode of its "?vnode=" query.
+func WithVnodes(nodes ...dht.INode) Option {
+	return func(t *HTTPTransport) {
+		t.vnodes = append(t.vnodes, nodes...)
+	}
+}
+
+// WithClientAddr serves the client endpoints (storage, network, node-info, ...) on a separate
+// listener, the transport address then only serves inter-node RPCs.
+func WithClientAddr(addr string) Option {
+	return func(t *HTTPTransport) {
+		t.clientAddr = addr
+	}
+}
+
+// WithBindAddr binds the rpc listener to the interface with the IP address instead of all
+// interfaces. The address advertised to peers is not affected.
+func WithBindAddr(ip string) Option {
+	return func(t *HTTPTransport) {
+		t.bindHost = ip
+	}
+}
+
+// WithSkewThreshold sets the key count ratio above which "/skew" reports the ring as unbalanced
+func WithSkewThreshold(threshold float64) Option {
+	return func(t *HTTPTransport) {
+		if threshold < 1 {
+			t.logger.Warn("WithSkewThreshold", "invalid threshold, must be at least 1", "threshold", threshold, "default", t.skewThreshold)
+			return
+		}
+		t.skewThreshold = threshold
+	}
+}
+
+// WithRequestTimeout sets the overall deadline of a storage request, started by the node the client
+// contacted and carried to the next hops, which answer 504 Gateway Timeout once it passed. Every hop
+// is also bounded by the forward timeout of WithTiming.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(t *HTTPTransport) {
+		if timeout <= 0 {
+			t.logger.Warn("WithRequestTimeout", "invalid request timeout, must be positive", "timeout", timeout, "default", t.requestTimeout)
+			return
+		}
+		t.requestTimeout = timeout
+	}
+}
+
+// WithMaxHops sets the number of hops a storage request is forwarded over before it is refused
+// with 508 Loop Detected
+func WithMaxHops(max int) Option {
+	return func(t *HTTPTransport) {
+		if max < 1 {
+			t.logger.Warn("WithMaxHops", "invalid hop limit, must be at least 1", "max", max, "default", t.maxHops)
+			return
+		}
+		t.maxHops = max
+	}
+}
+
+// WithMaxIdleConnsPerHost sets the idle connections kept open per peer for reuse, see newConnPool
+func WithMaxIdleConnsPerHost(max int) Option {
+	return func(t *HTTPTransport) {
+		if max < 1 {
+			t.logger.Warn("WithMaxIdleConnsPerHost", "invalid pool size, must be at least 1", "max", max, "default", t.maxIdleConns)
+			return
+		}
+		t.maxIdleConns = max
+	}
+}
+
+// WithMaxInFlight caps the client requests handled at once, further requests get a 503 "overloaded".
+// 0 (default) leaves them uncapped below the goroutine soft limit.
+func WithMaxInFlight(max int) Option {
+	return func(t *HTTPTransport) {
+		if max < 0 {
+			t.logger.Warn("WithMaxInFlight", "invalid in-flight cap, leaving uncapped", "max", max)
+			return
+		}
+		t.shedder.maxInFlight = max
+	}
+}
+
+// WithGoroutineSoftLimit sheds client requests more aggressively while the process runs more
+// goroutines than the limit, see loadShedder. 0 (default) disables the limit.
+func WithGoroutineSoftLimit(limit int) Option {
+	return func(t *HTTPTransport) {
+		if limit < 0 {
+			t.logger.Warn("WithGoroutineSoftLimit", "invalid goroutine soft limit, leaving disabled", "limit", limit)
+			return
+		}
+		t.shedder.goroutineSoftLimit = limit
+	}
+}
+
+// WithTiming sets the timeouts of the ring RPCs, data transfers and forwarded storage requests, see dht.Timing.
+// A timing that fails Validate is ignored and the defaults are used.
+func WithTiming(timing dht.Timing) Option {
+	return func(t *HTTPTransport) {
+		if err := timing.Validate(); err != nil {
+			t.logger.Warn("WithTiming", "invalid timing, using defaults", "error", err)
+			return
+		}
+		t.fastClient.Timeout = timing.FastTimeout
+		t.slowClient.Timeout = timing.SlowTimeout
+		t.forwardTimeout = timing.ForwardTimeout
+	}
+}
+
+// WithLogger sets the logger of the transport, the default is the text logger on the standard log package
+func WithLogger(logger logging.Logger) Option {
+	return func(t *HTTPTransport) {
+		t.logger = logger
+	}
+}
+
+// WithTLS serves both listeners over https with the certificate and key, and makes the
+// transport's own requests use https. Peers are verified against the CA file, or the
+// system pool if caFile is empty.
+func WithTLS(certFile string, keyFile string, caFile string) Option {
+	return func(t *HTTPTransport) {
+		t.tlsCertFile = certFile
+		t.tlsKeyFile = keyFile
+		t.tlsCAFile = caFile
+	}
+}
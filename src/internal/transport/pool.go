@@ -0,0 +1,38 @@
+package transport
+
+import (
+	"assignment/internal/metrics"
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Default idle connections kept open per peer, override with WithMaxIdleConnsPerHost. The
+// default http.Transport keeps 2, so a busy node dials most of its requests anew.
+const DefaultMaxIdleConnsPerHost = 64
+
+// Idle pooled connections are closed after idleConnTimeout
+const idleConnTimeout = 90 * time.Second
+
+// newConnPool returns the http.Transport shared by every outbound request of the transport:
+// ring RPCs, data transfers, forwards and traversals. Connections are kept alive and reused,
+// every new one is counted in dht_outbound_connections_total.
+func newConnPool(maxIdlePerHost int) *http.Transport {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err == nil {
+				metrics.OutboundConnections.Inc()
+			}
+			return conn, err
+		},
+		ForceAttemptHTTP2:     true,
+		MaxIdleConnsPerHost:   maxIdlePerHost,
+		IdleConnTimeout:       idleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
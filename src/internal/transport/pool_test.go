@@ -0,0 +1,117 @@
+package transport
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"assignment/internal/dht"
+)
+
+// idlePeer serves a peer counting the connections it closed, and opens a kept-alive connection
+// to it through the client
+func idlePeer(t *testing.T, client *http.Client) *atomic.Int32 {
+	t.Helper()
+	var closed atomic.Int32
+	peer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	peer.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed.Add(1)
+		}
+	}
+	peer.Start()
+	t.Cleanup(peer.Close)
+
+	resp, err := client.Get(peer.URL)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return &closed
+}
+
+// waitClosed waits until the peer closed a connection
+func waitClosed(t *testing.T, closed *atomic.Int32, what string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); closed.Load() == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("kept-alive connection still open after %s", what)
+		}
+	}
+}
+
+func TestStopClosesIdleConnections(t *testing.T) {
+	tr := newTestTransport(t, dht.Create("127.0.0.1:1", dht.WithLogger(quietLogger())))
+
+	// A ring RPC of the fast client leaves its connection in the pool
+	closed := idlePeer(t, tr.fastClient)
+	time.Sleep(50 * time.Millisecond)
+	if closed.Load() != 0 {
+		t.Fatal("connection closed before the transport stopped, want it kept alive")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := tr.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	waitClosed(t, closed, "Stop")
+}
+
+func TestFastClientClosesIdleConnections(t *testing.T) {
+	tr := newTestTransport(t, dht.Create("127.0.0.1:1", dht.WithLogger(quietLogger())))
+
+	// Closing the idle connections of the client goes through its observedRoundTripper to the pool
+	closed := idlePeer(t, tr.fastClient)
+	tr.fastClient.CloseIdleConnections()
+	waitClosed(t, closed, "CloseIdleConnections")
+}
+
+func TestRecreateAfterStop(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+	host, port, _ := net.SplitHostPort(addr)
+
+	// Each transport registers its handlers on its own mux and gives the port back when stopped
+	for i := range 3 {
+		node := dht.Create(addr, dht.WithLogger(quietLogger()))
+		tr, err := New(host, port, node, WithLogger(quietLogger()))
+		if err != nil {
+			t.Fatalf("New #%d: %v", i, err)
+		}
+		node.SetTransport(tr)
+		started := make(chan error, 1)
+		go func() { started <- tr.Start() }()
+
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+			resp, err := http.Get("http://" + addr + "/ping")
+			if err == nil {
+				resp.Body.Close()
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("transport #%d not up: %v", i, err)
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err = tr.Stop(ctx)
+		cancel()
+		if err != nil {
+			t.Fatalf("Stop #%d: %v", i, err)
+		}
+		if err := <-started; err != nil {
+			t.Fatalf("Start #%d: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"assignment/internal/dht"
+)
+
+// Header set on a prefix query response that was cut to its limit
+const truncatedHeader = "X-DHT-Truncated"
+
+// handlePrefixQuery handles GET requests to "/storage?prefix=<prefix>"
+// Keys are scattered over the ring by their hash, so every node is visited by walking the ring
+// like "/network" until the traversal is back at its origin. Returns the matching key-value pairs
+// as a JSON object with base64 values, with "&limit=" only the first ones in sorted key order.
+func (t *HTTPTransport) handlePrefixQuery(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+
+	limit := 0
+	if limitStr := query.Get("limit"); limitStr != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitStr); err != nil || limit <= 0 {
+			http.Error(w, fmt.Sprintf("invalid limit '%s', must be a positive integer", limitStr), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Flag the origin of the traversal
+	origin := query.Get("origin")
+	if origin == "" {
+		origin = t.vnode(r.Context()).Address() // first node
+	}
+
+	// Hops keep one pair more than the limit, so the first node can tell whether more keys matched
+	pairs := t.collectPrefix(r.Context(), prefix, limit, origin)
+	if query.Has("origin") {
+		pairs = dht.TruncateSorted(pairs, scanLimit(limit))
+	} else if limit > 0 && len(pairs) > limit {
+		pairs = dht.TruncateSorted(pairs, limit)
+		w.Header().Set(truncatedHeader, "true")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(pairs); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode keys: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// scanLimit returns the pairs a node of a prefix query keeps, 0 for all of them
+func scanLimit(limit int) int {
+	if limit <= 0 {
+		return 0
+	}
+	return limit + 1
+}
+
+// collectPrefix returns the matching pairs of this node merged with those of the nodes after it
+// up to the origin. Every node returns the first scanLimit pairs of its own and the later nodes,
+// so the merge holds the first keys of the ring.
+func (t *HTTPTransport) collectPrefix(ctx context.Context, prefix string, limit int, origin string) map[string][]byte {
+
+	node := t.vnode(ctx)
+
+	pairs := node.PrefixScan(prefix, scanLimit(limit))
+
+	// We keep forwarding the request until the traversal is back at the origin
+	_, succAdr := node.Successor()
+	if succAdr == "" || succAdr == origin || succAdr == node.Address() {
+		return pairs
+	}
+
+	query := url.Values{"prefix": {prefix}, "origin": {origin}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	resp, err := t.forwardedRequest(ctx, http.MethodGet, t.url(succAdr, "/storage?"+query.Encode()), nil)
+	if err != nil {
+		t.logger.Error("PrefixQuery", "failed to forward prefix query to successor", "successor", succAdr, "err", err)
+		return pairs
+	}
+	defer resp.Body.Close()
+
+	var succPairs map[string][]byte
+	if err := json.NewDecoder(resp.Body).Decode(&succPairs); err != nil {
+		t.logger.Error("PrefixQuery", "failed to decode prefix query response", "successor", succAdr, "err", err)
+		return pairs
+	}
+	for key, value := range succPairs {
+		pairs[key] = value
+	}
+	return pairs
+}
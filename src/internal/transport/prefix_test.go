@@ -0,0 +1,92 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"testing"
+
+	"assignment/internal/dht"
+)
+
+func TestPrefixQueryAcrossRing(t *testing.T) {
+	nodes := dht.BuildRing(4)
+	ring := newTestRing(t, nodes)
+	routeTraversals(ring)
+
+	var userKeys, otherKeys []string
+	for i := range 12 {
+		userKeys = append(userKeys, fmt.Sprintf("user:%02d", i))
+		otherKeys = append(otherKeys, fmt.Sprintf("other:%02d", i))
+	}
+	values := putValues(t, ring[nodes[0].Address()], append(userKeys, otherKeys...))
+	holders := 0
+	for _, node := range nodes {
+		if len(node.PrefixScan("user:", 0)) > 0 {
+			holders++
+		}
+	}
+	if holders < 2 {
+		t.Fatalf("the user keys are on %d nodes, want them spread over the ring", holders)
+	}
+
+	// query returns the pairs and whether they were cut to the limit
+	query := func(tr *HTTPTransport, target string) (map[string]string, bool) {
+		t.Helper()
+		w := serve(tr, http.MethodGet, target, nil, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: status %d: %s", target, w.Code, w.Body)
+		}
+		var pairs map[string][]byte
+		if err := json.NewDecoder(w.Body).Decode(&pairs); err != nil {
+			t.Fatalf("failed to decode GET %s: %v", target, err)
+		}
+		got := make(map[string]string, len(pairs))
+		for key, value := range pairs {
+			got[key] = string(value)
+		}
+		return got, w.Header().Get(truncatedHeader) == "true"
+	}
+	want := func(keys []string) map[string]string {
+		pairs := make(map[string]string, len(keys))
+		for _, key := range keys {
+			pairs[key] = values[key]
+		}
+		return pairs
+	}
+
+	// Every node collects the whole ring, wherever the traversal starts
+	for _, node := range nodes {
+		got, truncated := query(ring[node.Address()], "/storage?prefix=user:")
+		if !maps.Equal(got, want(userKeys)) || truncated {
+			t.Errorf("prefix query from %s = %v, truncated %v, want the %d user keys", node.Address(), slices.Sorted(maps.Keys(got)), truncated, len(userKeys))
+		}
+	}
+
+	tr := ring[nodes[1].Address()]
+	tests := []struct {
+		target    string
+		want      []string
+		truncated bool
+	}{
+		{"/storage?prefix=user:&limit=5", userKeys[:5], true},
+		{"/storage?prefix=user:&limit=12", userKeys, false},
+		{"/storage?prefix=user:&limit=100", userKeys, false},
+		{"/storage?prefix=", append(slices.Clone(otherKeys), userKeys...), false},
+		{"/storage?prefix=missing:", nil, false},
+	}
+	for _, tt := range tests {
+		got, truncated := query(tr, tt.target)
+		if !maps.Equal(got, want(tt.want)) || truncated != tt.truncated {
+			t.Errorf("GET %s = %v, truncated %v, want %v, truncated %v", tt.target, slices.Sorted(maps.Keys(got)), truncated, tt.want, tt.truncated)
+		}
+	}
+
+	for _, limit := range []string{"0", "-1", "ten"} {
+		if w := serve(tr, http.MethodGet, "/storage?prefix=user:&limit="+limit, nil, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("GET with limit %s: status %d, want 400", limit, w.Code)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"assignment/internal/dht"
+)
+
+func TestReplicaRouteIsNotAKey(t *testing.T) {
+	node := dht.Create("127.0.0.1:1", dht.WithLogger(quietLogger()))
+	tr := newTestTransport(t, node)
+
+	if w := serve(tr, http.MethodPut, "/storage/replica", []byte("user value"), nil); w.Code != http.StatusOK {
+		t.Fatalf("PUT /storage/replica: status %d: %s", w.Code, w.Body)
+	}
+	w := serve(tr, http.MethodGet, "/storage/replica", nil, nil)
+	if w.Code != http.StatusOK || w.Body.String() != "user value" {
+		t.Errorf("GET /storage/replica: status %d, body %q, want 200 \"user value\"", w.Code, w.Body)
+	}
+	if _, found := node.Replica("replica"); found {
+		t.Error("PUT of the key \"replica\" was stored as a replica")
+	}
+}
+
+func TestGetAfterPrimaryCrashReadsReplica(t *testing.T) {
+	net := dht.NewMemoryNetwork()
+	nodes := net.BuildRing(3, dht.WithReplicationFactor(3), dht.WithSuccessorListSize(2))
+	primary, successor := nodes[0], nodes[1]
+	ctx := context.Background()
+
+	key := putOwned(t, primary)
+	w := serve(newTestTransport(t, successor), http.MethodGet, "/replica?key="+key, nil, nil)
+	if w.Code != http.StatusOK || w.Body.String() != "value" {
+		t.Fatalf("GET /replica on the successor: status %d, body %q, want 200 \"value\"", w.Code, w.Body)
+	}
+
+	// The successor takes over the keys of the crashed primary once stabilization dropped it
+	net.Fail(primary.Address())
+	for range 3 {
+		for _, node := range nodes[1:] {
+			node.CheckPredecessor(ctx)
+			node.Stabilize(ctx)
+		}
+	}
+
+	w = serve(newTestTransport(t, successor), http.MethodGet, "/storage/"+key, nil, nil)
+	if w.Code != http.StatusOK || w.Body.String() != "value" {
+		t.Fatalf("GET of %q after the primary crashed: status %d, body %q, want 200 \"value\"", key, w.Code, w.Body)
+	}
+	if got := w.Header().Get(etagHeader); got != `"1"` {
+		t.Errorf("ETag of the repaired value = %s, want \"1\"", got)
+	}
+}
+
+func TestTransportRecordsReplicaLatency(t *testing.T) {
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		http.NotFound(w, r)
+	}))
+	defer peer.Close()
+	addr := strings.TrimPrefix(peer.URL, "http://")
+
+	tr := newTestTransport(t, dht.Create("127.0.0.1:1", dht.WithLogger(quietLogger())))
+	if _, known := tr.Latency(addr); known {
+		t.Fatal("latency known before any RPC")
+	}
+	if _, _, err := tr.GetReplica(addr, "key"); err != nil {
+		t.Fatalf("GetReplica: %v", err)
+	}
+
+	// Vnodes of the peer process share its latency
+	for _, target := range []string{addr, dht.VnodeAddress(addr, 1)} {
+		if latency, known := tr.Latency(target); !known || latency < 5*time.Millisecond {
+			t.Errorf("Latency(%s) = %v, %v, want at least 5ms", target, latency, known)
+		}
+	}
+}
+
+func TestGetReplicaKeepsExpiry(t *testing.T) {
+	holder := dht.Create("127.0.0.1:1", dht.WithLogger(quietLogger()))
+	expiresAt := time.Now().Add(time.Hour)
+	holder.AcceptReplicas(map[string]dht.Versioned{
+		"expiring":  {Value: []byte("value"), Version: 2, ExpiresAt: expiresAt},
+		"permanent": {Value: []byte("value"), Version: 1},
+	})
+	peer := httptest.NewServer(newTestTransport(t, holder).server.Handler)
+	defer peer.Close()
+	addr := strings.TrimPrefix(peer.URL, "http://")
+
+	tr := newTestTransport(t, dht.Create("127.0.0.1:2", dht.WithLogger(quietLogger())))
+	for key, want := range map[string]time.Time{"expiring": expiresAt, "permanent": {}} {
+		value, found, err := tr.GetReplica(addr, key)
+		if err != nil || !found {
+			t.Fatalf("GetReplica(%q) = %v, %v", key, found, err)
+		}
+		if !value.ExpiresAt.Equal(want) {
+			t.Errorf("replica of %q expires at %v, want %v", key, value.ExpiresAt, want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// ringNodeStats is the per-node result of a "/ring-stats" traversal
+type ringNodeStats struct {
+	Node        string `json:"node"`
+	Id          int    `json:"id"`
+	Successor   string `json:"successor"`
+	Predecessor string `json:"predecessor"`
+	KeyCount    int    `json:"key_count"`
+}
+
+// ringStatsReport is the response of "/ring-stats"
+type ringStatsReport struct {
+	Nodes           []ringNodeStats `json:"nodes"`
+	Inconsistencies []string        `json:"inconsistencies"`
+}
+
+// handleRingStats handles requests to the "/ring-stats" path
+// Returns the id, links and key count of every node in ring order from this node, collected by
+// walking the ring like "/network", with the inconsistencies found between neighbours.
+// Hops forwarded by other nodes carry "?visited=" with the nodes so far and only return the stats collected.
+func (t *HTTPTransport) handleRingStats(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Forwarded hop of a traversal
+	if r.URL.Query().Has("visited") {
+		visited := strings.Split(r.URL.Query().Get("visited"), ",")
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(t.collectRingStats(r.Context(), visited)); err != nil {
+			http.Error(w, fmt.Sprintf("failed to encode ring stats: %v", err), http.StatusInternalServerError)
+			return
+		}
+		return
+	}
+
+	nodes := t.collectRingStats(r.Context(), nil)
+	report := ringStatsReport{Nodes: nodes, Inconsistencies: ringInconsistencies(nodes)}
+
+	if len(report.Inconsistencies) > 0 {
+		t.logger.Warn("RingStats", "ring is inconsistent", "inconsistencies", len(report.Inconsistencies))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode ring stats: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// collectRingStats returns the stats of this node followed by the nodes up to the first visited one
+// The traversal stops at a successor that was already visited, so a broken ring can't loop it forever.
+func (t *HTTPTransport) collectRingStats(ctx context.Context, visited []string) []ringNodeStats {
+
+	node := t.vnode(ctx)
+
+	_, succAdr := node.Successor()
+	_, predAdr := node.Predecessor()
+
+	nodes := []ringNodeStats{{
+		Node:        node.Address(),
+		Id:          node.Id(),
+		Successor:   succAdr,
+		Predecessor: predAdr,
+		KeyCount:    node.KeyCount(),
+	}}
+
+	visited = append(visited, node.Address())
+
+	// We keep forwarding the request until the traversal comes back to a visited node
+	if succAdr != "" && !slices.Contains(visited, succAdr) {
+		forwardURL := t.url(succAdr, "/ring-stats?visited="+url.QueryEscape(strings.Join(visited, ",")))
+		resp, err := t.forwardedRequest(ctx, http.MethodGet, forwardURL, nil)
+		if err == nil {
+			defer resp.Body.Close()
+			var succNodes []ringNodeStats
+			if err := json.NewDecoder(resp.Body).Decode(&succNodes); err == nil {
+				nodes = append(nodes, succNodes...)
+			}
+		} else {
+			t.logger.Error("RingStats", "failed to forward ring stats request to successor", "successor", succAdr, "err", err)
+		}
+	}
+
+	return nodes
+}
+
+// ringInconsistencies returns the broken links between the nodes of a traversal, in ring order
+func ringInconsistencies(nodes []ringNodeStats) []string {
+
+	inconsistencies := []string{}
+	byAddress := make(map[string]ringNodeStats, len(nodes))
+	for _, n := range nodes {
+		byAddress[n.Node] = n
+	}
+
+	for i, n := range nodes {
+		last := i == len(nodes)-1
+
+		// A node of its own has no predecessor to check
+		if n.Successor == n.Node && len(nodes) == 1 {
+			continue
+		}
+
+		succ, reached := byAddress[n.Successor]
+		switch {
+		case !reached:
+			inconsistencies = append(inconsistencies, fmt.Sprintf("%s: successor %s did not respond", n.Node, n.Successor))
+			continue
+		case last && n.Successor != nodes[0].Node:
+			inconsistencies = append(inconsistencies, fmt.Sprintf("%s: successor %s closes a cycle that skips %s", n.Node, n.Successor, nodes[0].Node))
+		}
+
+		if succ.Predecessor != n.Node {
+			inconsistencies = append(inconsistencies, fmt.Sprintf("%s: successor %s has predecessor %s", n.Node, n.Successor, succ.Predecessor))
+		}
+	}
+
+	return inconsistencies
+}
@@ -1,22 +1,82 @@
 package transport
 
 import (
+	"assignment/internal/dht"
+	"assignment/internal/metrics"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"net/url"
+	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// Header marking a request as a hop forwarded by another node, set to the forwarding node's address
+const forwardedHeader = "X-DHT-Forwarded"
+
+// Header setting the expiry of a PUT in seconds
+const ttlHeader = "X-TTL-Seconds"
+
+// Header counting the hops of a forwarded storage request
+const hopCountHeader = "X-Hop-Count"
+
+// Default number of hops a storage request is forwarded over before it is refused, override with WithMaxHops
+const DefaultMaxHops = 32
+
+// Header of a forwarded storage request carrying the milliseconds left of its overall deadline,
+// relative so the clocks of the nodes don't need to agree. A client may set it to a shorter budget.
+const deadlineHeader = "X-DHT-Deadline-Ms"
+
+// Default overall deadline of a storage request across all its hops, override with WithRequestTimeout
+const DefaultRequestTimeout = 10 * time.Second
+
+// Nodes a "/network" traversal visits at most, so broken successor links can't loop it forever
+const maxNetworkNodes = 1024
+
+// Header of a conditional PUT carrying the value the key must currently hold, or its ETag,
+// see dht.CompareAndPut and dht.CompareVersionAndPut
+const ifMatchHeader = "If-Match"
+
+// Header of a storage response carrying the version of the value, see etag
+const etagHeader = "ETag"
+
+// Header of a "/replica" response carrying the expiry of the owner's key in RFC 3339, absent if the key never expires
+const expiresAtHeader = "X-DHT-Expires-At"
+
+// Header of a storage request asking to be redirected to the next hop with 307 instead of being forwarded
+const preferRedirectHeader = "X-Prefer-Redirect"
+
+// Header with the machine-readable reason of a 503, one of the unavailable reasons below
+const unavailableReasonHeader = "X-DHT-Unavailable-Reason"
+
+// Reasons a request is refused with 503 Service Unavailable
+const (
+	reasonCrashed    = "crashed"    // the node is sim-crashed, route elsewhere until it recovers
+	reasonQuiesced   = "quiesced"   // the node left the ring or the key is being moved, retry shortly
+	reasonOverloaded = "overloaded" // the node is over capacity, back off
+	reasonWarming    = "warming"    // the node is still joining the ring on startup, retry shortly
+)
+
+// Query parameter of a GET on "/storage/{key}" setting the read consistency level, see dht.ParseConsistency
+const consistencyParam = "consistency"
+
+// Header listing the addresses of the nodes a storage request has been forwarded by, comma separated
+const visitedHeader = "X-DHT-Visited"
+
 // --------- NODE RPC HANDLERS ---------
 
 // handleSuccessor handles GET/PUT requests to the "/successor" path
 func (t *HTTPTransport) handleSuccessor(w http.ResponseWriter, r *http.Request) {
 
+	node := t.vnode(r.Context())
+
 	// Switch on the method and perform Get/Set on node
 	switch r.Method {
 	case http.MethodGet:
@@ -35,7 +95,7 @@ func (t *HTTPTransport) handleSuccessor(w http.ResponseWriter, r *http.Request)
 
 		//log.Printf("SERVER: received request to FindSuccessor for key %d", keyId)
 
-		successorAddr, err := t.node.FindSuccessor(keyId)
+		successorAddr, err := node.FindSuccessor(r.Context(), keyId)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("failed to find successor: %v", err), http.StatusInternalServerError)
 			return
@@ -55,8 +115,8 @@ func (t *HTTPTransport) handleSuccessor(w http.ResponseWriter, r *http.Request)
 			return
 		}
 
-		log.Printf("Transport: SetSuccessor to '%s'", successor)
-		t.node.SetSuccessor(successor)
+		t.logger.Info("SetSuccessor", "successor set by peer", "successor", successor)
+		node.SetSuccessor(successor)
 
 		// Send response confirming the update
 		w.Header().Set("Content-Type", "application/json")
@@ -65,7 +125,7 @@ func (t *HTTPTransport) handleSuccessor(w http.ResponseWriter, r *http.Request)
 			"status":    "success",
 			"successor": successor,
 		}); err != nil {
-			log.Printf("Failed to encode response: %v", err)
+			t.logger.Error("Successor", "failed to encode response", "err", err)
 		}
 
 	default:
@@ -77,10 +137,12 @@ func (t *HTTPTransport) handleSuccessor(w http.ResponseWriter, r *http.Request)
 // handlePredecessor handles requests to the "/predecessor" path
 func (t *HTTPTransport) handlePredecessor(w http.ResponseWriter, r *http.Request) {
 
+	node := t.vnode(r.Context())
+
 	// Switch on the method and perform Get/Set on node
 	switch r.Method {
 	case http.MethodGet:
-		_, predecessorAddr := t.node.Predecessor()
+		_, predecessorAddr := node.Predecessor()
 
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(predecessorAddr); err != nil {
@@ -97,7 +159,7 @@ func (t *HTTPTransport) handlePredecessor(w http.ResponseWriter, r *http.Request
 		}
 
 		// Suggest that the node might have a new predecessor
-		t.node.Notify(predecessor)
+		node.Notify(predecessor)
 
 		// Send response confirming the update
 		w.Header().Set("Content-Type", "application/json")
@@ -106,7 +168,7 @@ func (t *HTTPTransport) handlePredecessor(w http.ResponseWriter, r *http.Request
 			"status":      "success",
 			"predecessor": predecessor,
 		}); err != nil {
-			log.Printf("Failed to encode response: %v", err)
+			t.logger.Error("Predecessor", "failed to encode response", "err", err)
 		}
 
 	case http.MethodPost:
@@ -117,9 +179,169 @@ func (t *HTTPTransport) handlePredecessor(w http.ResponseWriter, r *http.Request
 			return
 		}
 
-		// Instruct the node that has a new predecessor
-		t.node.SetPredecessor(predecessor)
+		// Instruct the node that has a new predecessor, sent by its leaving predecessor and forced
+		node.SetPredecessor(predecessor, true)
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+// handleSuccessorList handles GET requests to the "/successor-list" path
+func (t *HTTPTransport) handleSuccessorList(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(t.vnode(r.Context()).SuccessorList()); err != nil {
+		http.Error(w, "failed to encode successor list", http.StatusInternalServerError)
+		return
+	}
+}
+
+// closestPrecedingStep is the JSON body of a "/closest-preceding" response
+type closestPrecedingStep struct {
+	Closest   string `json:"closest"`
+	Successor string `json:"successor"`
+}
+
+// handleClosestPreceding handles GET requests to the "/closest-preceding" path
+// Returns the node's closest preceding node of the key and its successor, one step of an iterative lookup.
+func (t *HTTPTransport) handleClosestPreceding(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	keyId, err := strconv.Atoi(r.URL.Query().Get("key"))
+	if err != nil {
+		http.Error(w, "invalid key format", http.StatusBadRequest)
+		return
+	}
+
+	closest, successor := t.vnode(r.Context()).ClosestPreceding(keyId)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(closestPrecedingStep{Closest: closest, Successor: successor}); err != nil {
+		http.Error(w, "failed to encode closest preceding node", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handoffBatch is the JSON body of a "/handoff" GET response
+type handoffBatch struct {
+	Pairs map[string]dht.Versioned `json:"pairs"` // {value, version, expires_at}, base64 values
+	More  bool                     `json:"more"`
+}
+
+// handleReplica handles requests to the "/replica" path
+// PUT stores replicas pushed by their owner as a JSON object of {key: {value, version}}, GET "?key="
+// returns the replica of the key with its version as ETag or 404,
+// DELETE deletes the replicas of a JSON array of keys.
+func (t *HTTPTransport) handleReplica(w http.ResponseWriter, r *http.Request) {
+
+	node := t.vnode(r.Context())
+
+	switch r.Method {
+	case http.MethodGet:
+		value, found := node.Replica(r.URL.Query().Get("key"))
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set(etagHeader, etag(value.Version))
+		if !value.ExpiresAt.IsZero() {
+			w.Header().Set(expiresAtHeader, value.ExpiresAt.Format(time.RFC3339Nano))
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(value.Value)
+
+	case http.MethodPut:
+		var pairs map[string]dht.Versioned
+		if err := json.NewDecoder(r.Body).Decode(&pairs); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		node.AcceptReplicas(pairs)
 		w.WriteHeader(http.StatusOK)
+
+	case http.MethodDelete:
+		var keys []string
+		if err := json.NewDecoder(r.Body).Decode(&keys); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		node.DropReplicas(keys)
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+// handleHandoff handles requests to the "/handoff" path
+// GET returns a batch of local keys in (from, to] for a new owner, POST confirms the
+// new owner stored them so they can be deleted here, PUT stores keys pushed by a leaving predecessor.
+// Keys are sent as a JSON object of {key: {value, version, expires_at}}, the version is kept.
+func (t *HTTPTransport) handleHandoff(w http.ResponseWriter, r *http.Request) {
+
+	node := t.vnode(r.Context())
+
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		fromId, err := strconv.Atoi(query.Get("from"))
+		if err != nil {
+			http.Error(w, "invalid from id", http.StatusBadRequest)
+			return
+		}
+		toId, err := strconv.Atoi(query.Get("to"))
+		if err != nil {
+			http.Error(w, "invalid to id", http.StatusBadRequest)
+			return
+		}
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+
+		pairs, more := node.HandoffBatch(fromId, toId, query.Get("after"), limit)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(handoffBatch{Pairs: pairs, More: more}); err != nil {
+			http.Error(w, "failed to encode handoff batch", http.StatusInternalServerError)
+			return
+		}
+
+	case http.MethodPost:
+		var pairs map[string]dht.Versioned
+		if err := json.NewDecoder(r.Body).Decode(&pairs); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		node.ConfirmHandoff(pairs)
+		w.WriteHeader(http.StatusOK)
+
+	case http.MethodPut:
+		var pairs map[string]dht.Versioned
+		if err := json.NewDecoder(r.Body).Decode(&pairs); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		node.AcceptHandoff(pairs)
+		w.WriteHeader(http.StatusOK)
+
 	default:
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -128,28 +350,127 @@ func (t *HTTPTransport) handlePredecessor(w http.ResponseWriter, r *http.Request
 
 // --------- SYSTEM HANDLERS ---------
 
-// helloHandler handles requests to the "/ping" path
-func (t *HTTPTransport) handlePing(w http.ResponseWriter, r *http.Request) {
-	// Write the hostname and port to the response
-	fmt.Fprintf(w, "%s", t.address)
+// endpointIndex is the JSON body of a "/" response
+type endpointIndex struct {
+	Node          string   `json:"node"`
+	Id            int      `json:"id"`
+	State         string   `json:"state"`
+	ClientAddress string   `json:"client_address,omitempty"`
+	Endpoints     []string `json:"endpoints"`
+}
+
+// handleRoot handles GET requests to the "/" path
+// Returns the node's identity and the client endpoints it serves, any other unmatched path is a 404.
+func (t *HTTPTransport) handleRoot(w http.ResponseWriter, r *http.Request) {
+
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(endpointIndex{
+		Node:          t.address,
+		Id:            t.node.Id(),
+		State:         t.currentState(),
+		ClientAddress: t.clientAddr,
+		Endpoints:     t.clientEndpoints,
+	}); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode endpoint index: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// pingReply is the JSON body of a "/ping" response
+type pingReply struct {
+	Id      int    `json:"id"`
+	Address string `json:"address"`
+}
 
-	// Log the request
-	//log.Printf("Ping request received from %s\n", r.RemoteAddr)
+// handlePing handles requests to the "/ping" path
+// Returns the id and address of the node, so the caller can check it reached the node it expected.
+func (t *HTTPTransport) handlePing(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	node := t.vnode(r.Context())
+	if err := json.NewEncoder(w).Encode(pingReply{Id: node.Id(), Address: node.Address()}); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode ping reply: %v", err), http.StatusInternalServerError)
+		return
+	}
 }
 
-// handleStorage handles GET and PUT on the node.
-// requests are forwarded if the node is not responsible for the key.
+// handleStorage handles GET, HEAD, PUT and DELETE on the node.
+// requests are forwarded if the node is not responsible for the key. A HEAD is routed like a GET
+// and answered with the status and Content-Length of the value only, e.g. to check a key exists.
+// A PUT with an X-Idempotency-Key header is applied once per key by the owner, a duplicate
+// within idempotencyTTL, e.g. a forward retried after a timeout, gets the prior result back.
+// A PUT with an If-Match header is only stored if the key holds that value, or is at that version
+// if it is an ETag, else 412 is returned. GET, HEAD and PUT answer with the version as ETag.
 func (t *HTTPTransport) handleStorage(w http.ResponseWriter, r *http.Request) {
 
-	log.Printf("handleStorage request received: %s, %s", r.URL.Path, r.Method)
+	node := t.vnode(r.Context())
+
+	// Hops the request has been forwarded so far, echoed back by the node that answers it
+	hops := hopCount(r.Header)
+	w.Header().Set(hopCountHeader, strconv.Itoa(hops))
 
-	// Get the key from the request path
-	key := strings.TrimPrefix(r.URL.Path, "/storage/")
+	t.logger.Info("Storage", "request received", "path", r.URL.Path, "method", r.Method, "hops", hops)
+
+	// Keys stored before the startup join would be placed in a ring of its own
+	if t.warming.Load() {
+		refuseRequest(w, r, reasonWarming)
+		return
+	}
+
+	// A leaving node is handing its keys off, they may already be gone
+	if t.draining.Load() {
+		refuseRequest(w, r, reasonQuiesced)
+		return
+	}
+
+	// Get the key from the request path, escaped by the client so it may hold '?', '#' or '%'
+	key, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/storage/"))
+	if err != nil {
+		http.Error(w, "invalid key escaping", http.StatusBadRequest)
+		return
+	}
+
+	// No key, list the local keys instead
+	if key == "" {
+		t.handleLocalKeys(w, r)
+		return
+	}
+
+	// Optional expiry of a PUT
+	ttl, err := parseTTL(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Copies a GET must agree on, kept when the request is forwarded to the owner
+	consistency, err := dht.ParseConsistency(r.URL.Query().Get(consistencyParam))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	metrics.StorageRequests.WithLabelValues(r.Method).Inc()
+
+	// Stream a PUT this node does not own to the next hop, the value is only buffered on the owner
+	if r.Method == http.MethodPut {
+		if nextNodeAddress := node.NextHop(key); nextNodeAddress != "" {
+			t.forwardStorage(w, r, key, nextNodeAddress, r.Body)
+			return
+		}
+	}
 
 	// Extract body of PUT
 	var body []byte
 	if r.Method == http.MethodPut {
-		var err error
 		body, err = io.ReadAll(r.Body)
 		if err != nil {
 			http.Error(w, "failed to read body", http.StatusInternalServerError)
@@ -158,21 +479,91 @@ func (t *HTTPTransport) handleStorage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var nextNodeAddress string
-	var value string
-	var err error
+	var value dht.Versioned
+	var version uint64
 
-	// Switch on the method and perform Get/Put on node
+	// Switch on the method and perform Get/Put/Delete on node
 	switch r.Method {
-	case http.MethodGet:
-		value, nextNodeAddress, err = t.node.Get(key)
-		if err != nil {
-			log.Printf("ERROR: Get failed for key %s: %v", key, err)
+	case http.MethodGet, http.MethodHead:
+		value, nextNodeAddress, err = node.GetConsistent(key, consistency)
+		if errors.Is(err, dht.ErrKeyNotFound) {
+			t.logger.Info("Storage", "key not found on owner", "key", key)
 			http.NotFound(w, r)
 			return
 		}
+		if errors.Is(err, dht.ErrInconsistent) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			t.logger.Error("Storage", "get failed", "key", key, "err", err)
+			http.Error(w, fmt.Sprintf("failed to get key: %v", err), http.StatusInternalServerError)
+			return
+		}
 
 	case http.MethodPut:
-		nextNodeAddress = t.node.Put(key, string(body))
+		// A PUT retried after a timeout may already have been applied by this owner
+		idempotencyKey := r.Header.Get(idempotencyKeyHeader)
+		if idempotencyKey != "" {
+			status, duplicate, pending := t.idempotency.begin(key, idempotencyKey)
+			if duplicate && pending {
+				http.Error(w, "a request with this idempotency key is still in progress", http.StatusConflict)
+				return
+			}
+			if duplicate {
+				t.logger.Info("Storage", "duplicate put, returning the prior result", "key", key, "idempotency_key", idempotencyKey)
+				w.Header().Set(idempotentReplayHeader, "true")
+				w.WriteHeader(status)
+				return
+			}
+		}
+		// An empty If-Match is a swap from the empty value, not an unconditional PUT
+		if _, conditional := r.Header[ifMatchHeader]; conditional {
+			expected := r.Header.Get(ifMatchHeader)
+			if expectedVersion, isETag := parseETag(expected); isETag {
+				version, nextNodeAddress, err = node.CompareVersionAndPut(key, expectedVersion, body, ttl)
+			} else {
+				version, nextNodeAddress, err = node.CompareAndPut(key, []byte(expected), body, ttl)
+			}
+		} else {
+			version, nextNodeAddress, err = node.Put(key, body, ttl)
+		}
+		if idempotencyKey != "" {
+			if err == nil && nextNodeAddress == "" {
+				t.idempotency.complete(key, idempotencyKey, http.StatusOK)
+			} else {
+				t.idempotency.release(key, idempotencyKey)
+			}
+		}
+		if errors.Is(err, dht.ErrKeyMoving) {
+			t.refuseMovingKey(w, key)
+			return
+		}
+		if errors.Is(err, dht.ErrStorageFull) {
+			http.Error(w, err.Error(), http.StatusInsufficientStorage)
+			return
+		}
+		if errors.Is(err, dht.ErrPreconditionFailed) {
+			http.Error(w, "the key does not hold the value or version given in If-Match", http.StatusPreconditionFailed)
+			return
+		}
+
+	case http.MethodDelete:
+		nextNodeAddress, err = node.Delete(key)
+		if errors.Is(err, dht.ErrKeyMoving) {
+			t.refuseMovingKey(w, key)
+			return
+		}
+		if errors.Is(err, dht.ErrKeyNotFound) {
+			t.logger.Info("Storage", "key not found on owner", "key", key)
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			t.logger.Error("Storage", "delete failed", "key", key, "err", err)
+			http.Error(w, fmt.Sprintf("failed to delete key: %v", err), http.StatusInternalServerError)
+			return
+		}
 
 	default:
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -181,26 +572,136 @@ func (t *HTTPTransport) handleStorage(w http.ResponseWriter, r *http.Request) {
 
 	// Forward request if this node was not correct node
 	if nextNodeAddress != "" {
-		forwardURL := "http://" + nextNodeAddress + "/storage/" + key
-		forwardRequest(w, r.Method, forwardURL, body)
+		var forwardBody io.Reader
+		if body != nil {
+			forwardBody = bytes.NewReader(body)
+		}
+		t.forwardStorage(w, r, key, nextNodeAddress, forwardBody)
 		return
 	}
 
-	// Write value to body if GET, otherwise write header status OK for PUT
-	if r.Method == http.MethodGet {
+	// Write value to body if GET, only its length if HEAD, otherwise write header status OK for PUT/DELETE
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set(etagHeader, etag(value.Version))
 		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte(value))
-	} else {
+		_, _ = w.Write(value.Value)
+	case http.MethodHead:
+		w.Header().Set(etagHeader, etag(value.Version))
+		w.Header().Set("Content-Length", strconv.Itoa(len(value.Value)))
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPut:
+		w.Header().Set(etagHeader, etag(version))
+		w.WriteHeader(http.StatusOK)
+	default:
 		w.WriteHeader(http.StatusOK)
 	}
 }
 
+// forwardStorage forwards a storage request to the next hop towards the owner of the key
+func (t *HTTPTransport) forwardStorage(w http.ResponseWriter, r *http.Request, key string, nextNodeAddress string, body io.Reader) {
+
+	// Routing came back to a node already in the chain, or points at this node itself, forwarding
+	// would cycle
+	self := t.vnode(r.Context()).Address()
+	visited := visitedNodes(r.Header)
+	if slices.Contains(visited, self) || nextNodeAddress == self {
+		t.refuseLoop(w, self, key, visited)
+		return
+	}
+
+	// A chain of distinct nodes can still grow for as long as routing is wrong under churn
+	if hops := hopCount(r.Header); hops >= t.maxHops {
+		chain := strings.Join(append(visited, self), " -> ")
+		t.logger.Warn("Storage", "hop limit reached, refusing to forward", "key", key, "hops", hops, "max_hops", t.maxHops, "chain", chain)
+		http.Error(w, fmt.Sprintf("hop limit of %d reached, forwarding chain: %s", t.maxHops, chain), http.StatusLoopDetected)
+		return
+	}
+
+	// The hops left share what remains of the overall deadline
+	deadline := t.requestDeadline(r)
+	if time.Until(deadline) <= 0 {
+		t.logger.Warn("Storage", "deadline exceeded, refusing to forward", "key", key, "hops", hopCount(r.Header), "next", nextNodeAddress)
+		http.Error(w, "deadline of the request exceeded before reaching the owner", http.StatusGatewayTimeout)
+		return
+	}
+
+	forwardURL := t.url(nextNodeAddress, "/storage/"+url.PathEscape(key))
+	if consistency := r.URL.Query().Get(consistencyParam); consistency != "" {
+		forwardURL = t.url(nextNodeAddress, "/storage/"+url.PathEscape(key)+"?"+consistencyParam+"="+url.QueryEscape(consistency))
+	}
+
+	// Another vnode of this process is served without leaving it
+	i, local := t.localVnode(nextNodeAddress)
+
+	// The client goes to the next hop itself, a separate client address can't be redirected to
+	// since the next hop is only known by its ring address
+	if !local && r.Header.Get(preferRedirectHeader) == "true" && t.clientAddr == "" {
+		t.logger.Info("Storage", "redirecting to the next hop", "key", key, "method", r.Method, "location", forwardURL)
+		metrics.RedirectedRequests.Inc()
+		http.Redirect(w, r, forwardURL, http.StatusTemporaryRedirect)
+		return
+	}
+	metrics.ForwardedRequests.Inc()
+
+	if local {
+		t.serveLocalVnode(w, r, i, body)
+		return
+	}
+	t.forwardRequest(r.Context(), w, r.Method, forwardURL, body, storageHeaders(r), deadline)
+}
+
+// requestDeadline returns the overall deadline of a storage request, the X-DHT-Deadline-Ms left on
+// arrival if set, at most the request timeout from now
+func (t *HTTPTransport) requestDeadline(r *http.Request) time.Time {
+	timeout := t.requestTimeout
+	if ms, err := strconv.ParseInt(r.Header.Get(deadlineHeader), 10, 64); err == nil {
+		timeout = min(timeout, time.Duration(ms)*time.Millisecond)
+	}
+	return time.Now().Add(timeout)
+}
+
+// handleLocalKeys handles GET requests to the "/storage" path
+// Returns the keys stored on this node as JSON, never forwarded. With "?prefix=" the pairs of
+// the whole ring whose key has the prefix are returned instead, see handlePrefixQuery.
+func (t *HTTPTransport) handleLocalKeys(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.URL.Query().Has("prefix") {
+		t.handlePrefixQuery(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(t.vnode(r.Context()).LocalKeys()); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode keys: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
 // handleNetwork handles requests to the "/network" path
+// GET returns the addresses of the ring in order, collected by forwarding the request to the successor
+// until it is back at the origin. A successor that can't be reached or answers with an error is
+// skipped for the next live node of the successor list or finger table, so one dead node doesn't
+// hide the rest of the ring. Hops carry "?hops=", at most maxNetworkNodes nodes are visited.
+// PUT links the node into the ring of a JSON array of addresses, see dht.Node.SetNetwork.
 func (t *HTTPTransport) handleNetwork(w http.ResponseWriter, r *http.Request) {
 
-	// PUT: NETWORK INITIALIZATION
+	node := t.vnode(r.Context())
 
-	// deprecated
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		t.setNetwork(w, r, node)
+		return
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
 	// GET: NETWORK TRAVERSAL
 
@@ -209,27 +710,42 @@ func (t *HTTPTransport) handleNetwork(w http.ResponseWriter, r *http.Request) {
 	// Flag the origin of the traversal
 	origin := r.URL.Query().Get("origin")
 	if origin == "" {
-		origin = t.node.Address() // first node
+		origin = node.Address() // first node
 	}
+	hops, _ := strconv.Atoi(r.URL.Query().Get("hops"))
 
 	// Start list with this node
-	nodes := []string{t.node.Address()}
+	nodes := []string{node.Address()}
 
-	_, succAdr := t.node.Successor()
+	// Surface a successor pointing at self while the finger table knows of peers,
+	// otherwise the traversal silently returns only this node and masks the problem.
+	if peers := successorSelfWithPeers(node); len(peers) > 0 {
+		t.logger.Warn("Network", "successor is self but finger table references other nodes", "peers", peers)
+		w.Header().Set("X-DHT-Inconsistent", "successor-self-with-peers")
+	}
 
 	// We keep forwarding request, add node to list if not the origin.
-	if succAdr != origin {
-		forwardURL := fmt.Sprintf("http://%s/network?origin=%s", succAdr, origin)
-		resp, err := http.Get(forwardURL)
-		if err == nil {
-			defer resp.Body.Close()
-			var succNodes []string
-			if err := json.NewDecoder(resp.Body).Decode(&succNodes); err == nil {
+	if hops+1 < maxNetworkNodes {
+		for _, next := range networkCandidates(node, origin) {
+			succNodes, err := t.forwardNetwork(r.Context(), next, origin, hops+1)
+			if err == nil {
 				nodes = append(nodes, succNodes...)
+				break
 			}
-		} else {
-			log.Printf("Failed to contact successor %s: %v", succAdr, err)
+			t.logger.Error("Network", "failed to contact next node, skipping it", "next", next, "err", err)
 		}
+	} else {
+		t.logger.Warn("Network", "traversal node limit reached", "origin", origin, "max_nodes", maxNetworkNodes)
+	}
+
+	// A traversal that skipped nodes can reach nodes twice, keep the first of each
+	if origin == node.Address() {
+		seen := make(map[string]bool, len(nodes))
+		nodes = slices.DeleteFunc(nodes, func(addr string) bool {
+			duplicate := seen[addr]
+			seen[addr] = true
+			return duplicate
+		})
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -239,26 +755,223 @@ func (t *HTTPTransport) handleNetwork(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// setNetwork handles PUT requests to the "/network" path
+// The list is validated before the node changes anything, 400 if it is refused.
+func (t *HTTPTransport) setNetwork(w http.ResponseWriter, r *http.Request, node dht.INode) {
+
+	var addresses []string
+	if err := json.NewDecoder(r.Body).Decode(&addresses); err != nil {
+		http.Error(w, "invalid JSON, want an array of addresses", http.StatusBadRequest)
+		return
+	}
+
+	if err := node.SetNetwork(addresses); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, dht.ErrInvalidNetwork) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	_, successorAddr := node.Successor()
+	_, predecessorAddr := node.Predecessor()
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{
+		"successor":   successorAddr,
+		"predecessor": predecessorAddr,
+	}); err != nil {
+		t.logger.Error("Network", "failed to encode response", "err", err)
+	}
+}
+
+// networkCandidates returns the nodes a "/network" traversal continues from, the successor first,
+// then the successor list and the fingers in ring order. The list ends before the origin, where
+// the traversal is complete.
+func networkCandidates(node dht.INode, origin string) []string {
+
+	_, succAdr := node.Successor()
+	self := node.Address()
+
+	var candidates []string
+	seen := map[string]bool{self: true}
+	for _, addr := range slices.Concat([]string{succAdr}, node.SuccessorList(), node.FingerTable()) {
+		if addr == origin {
+			break
+		}
+		if addr != "" && !seen[addr] {
+			candidates = append(candidates, addr)
+			seen[addr] = true
+		}
+	}
+	return candidates
+}
+
+// forwardNetwork continues the "/network" traversal of the origin on the node at the address
+func (t *HTTPTransport) forwardNetwork(ctx context.Context, addr string, origin string, hops int) ([]string, error) {
+
+	forwardURL := t.url(addr, "/network?origin="+url.QueryEscape(origin)+"&hops="+strconv.Itoa(hops))
+	resp, err := t.forwardedRequest(ctx, http.MethodGet, forwardURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s answered %s", addr, resp.Status)
+	}
+	var nodes []string
+	if err := json.NewDecoder(resp.Body).Decode(&nodes); err != nil {
+		return nil, fmt.Errorf("failed to decode nodes from %s: %w", addr, err)
+	}
+	return nodes, nil
+}
+
+// fixFingersResult is the JSON body of a "/fix-fingers" response
+type fixFingersResult struct {
+	Updated int      `json:"updated"`
+	Failed  int      `json:"failed"`
+	Fingers []string `json:"fingers"`
+}
+
+// handleFixFingers handles POST requests to the "/fix-fingers" path
+// Rebuilds the node's whole finger table in one pass, so test harnesses don't wait M ticks
+// for it to converge after a topology change. Never forwarded.
+func (t *HTTPTransport) handleFixFingers(w http.ResponseWriter, r *http.Request) {
+
+	node := t.vnode(r.Context())
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	updated, failed := node.FixAllFingers(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(fixFingersResult{Updated: updated, Failed: failed, Fingers: node.FingerTable()}); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode finger fix result: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// verifyResult is the JSON body of a "/verify" response
+type verifyResult struct {
+	Node       string               `json:"node"`
+	Consistent bool                 `json:"consistent"`
+	Fingers    []string             `json:"fingers"`
+	Mismatches []dht.FingerMismatch `json:"mismatches"`
+}
+
+// handleVerify handles GET requests to the "/verify" path
+// Looks up the successor of every finger start in the live ring and reports the entries that
+// differ from it, without updating the finger table like "/fix-fingers". Never forwarded.
+func (t *HTTPTransport) handleVerify(w http.ResponseWriter, r *http.Request) {
+
+	node := t.vnode(r.Context())
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	fingers := node.FingerTable()
+	mismatches := node.VerifyFingers(r.Context())
+
+	result := verifyResult{
+		Node:       node.Address(),
+		Consistent: len(mismatches) == 0,
+		Fingers:    fingers,
+		Mismatches: mismatches,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode finger verification: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// repairSummary is the per-node result of a "/repair" run
+type repairSummary struct {
+	Node   string `json:"node"`
+	Moved  int    `json:"moved"`
+	Failed int    `json:"failed"`
+}
+
+// handleRepair handles requests to the "/repair" path
+// Moves this node's misplaced keys to their owners, then forwards the repair to the
+// successor until the traversal is back at the origin, like the "/network" traversal.
+func (t *HTTPTransport) handleRepair(w http.ResponseWriter, r *http.Request) {
+
+	node := t.vnode(r.Context())
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Flag the origin of the traversal
+	origin := r.URL.Query().Get("origin")
+	if origin == "" {
+		origin = node.Address() // first node
+	}
+
+	moved, failed := node.Repair()
+	summaries := []repairSummary{{Node: node.Address(), Moved: moved, Failed: failed}}
+
+	_, succAdr := node.Successor()
+
+	// We keep forwarding the repair until the ring is covered
+	if succAdr != origin && succAdr != node.Address() {
+		forwardURL := t.url(succAdr, "/repair?origin="+url.QueryEscape(origin))
+		resp, err := t.forwardedRequest(r.Context(), http.MethodPost, forwardURL, nil)
+		if err == nil {
+			defer resp.Body.Close()
+			var succSummaries []repairSummary
+			if err := json.NewDecoder(resp.Body).Decode(&succSummaries); err == nil {
+				summaries = append(summaries, succSummaries...)
+			}
+		} else {
+			t.logger.Error("Repair", "failed to forward repair to successor", "successor", succAdr, "err", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(summaries); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode repair summary: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
 // handleNodeInfo handles requests to the "/node-info" path
 func (t *HTTPTransport) handleNodeInfo(w http.ResponseWriter, r *http.Request) {
 
+	node := t.vnode(r.Context())
+
 	type NodeInfo struct {
-		NodeHash    string   `json:"node_hash"`
-		Successor   string   `json:"successor"`
-		Predecessor string   `json:"predecessor"`
-		Others      []string `json:"others"`
+		NodeHash      string   `json:"node_hash"`
+		Successor     string   `json:"successor"`
+		SuccessorList []string `json:"successor_list"` // backups of the successor, the first replicas of the keys
+		Predecessor   string   `json:"predecessor"`
+		Others        []string `json:"others"`
+		Epoch         uint64   `json:"epoch"`
+		KeyCount      int      `json:"key_count"`
 	}
 
-	nodeHash := strconv.Itoa(t.node.Id())
-	_, successorAddress := t.node.Successor()
-	_, predecessorAddress := t.node.Predecessor()
-	others := t.node.FingerTable()
+	nodeHash := strconv.Itoa(node.Id())
+	_, successorAddress := node.Successor()
+	_, predecessorAddress := node.Predecessor()
+	others := node.FingerTable()
 
 	info := NodeInfo{
-		NodeHash:    nodeHash,
-		Successor:   successorAddress,
-		Predecessor: predecessorAddress,
-		Others:      others,
+		NodeHash:      nodeHash,
+		Successor:     successorAddress,
+		SuccessorList: node.SuccessorList(),
+		Predecessor:   predecessorAddress,
+		Others:        others,
+		Epoch:         node.Epoch(),
+		KeyCount:      node.KeyCount(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -268,6 +981,164 @@ func (t *HTTPTransport) handleNodeInfo(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// ownedRange is the JSON body of a "/owned-range" response
+type ownedRange struct {
+	From        int    `json:"from"`
+	To          int    `json:"to"`
+	Inclusive   string `json:"inclusive"`   // always "right", the interval is (from, to]
+	WholeRing   bool   `json:"whole_ring"`  // from == to, the node owns every key
+	Predecessor string `json:"predecessor"` // empty if unknown, from is then 0
+}
+
+// handleOwnedRange handles GET requests to the "/owned-range" path
+// Returns the key-id interval the node stores keys of, the same check its storage operations use.
+func (t *HTTPTransport) handleOwnedRange(w http.ResponseWriter, r *http.Request) {
+
+	node := t.vnode(r.Context())
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	from, to := node.OwnedRange()
+	_, predecessor := node.Predecessor()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ownedRange{
+		From:        from,
+		To:          to,
+		Inclusive:   "right",
+		WholeRing:   from == to,
+		Predecessor: predecessor,
+	}); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode owned range: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleLookup handles GET requests to the "/lookup" path
+// Returns where a storage request for "?key=" would go from this node as JSON, see dht.Route.
+// Read-only, nothing is stored and no link changes. 502 if the owner can't be looked up.
+func (t *HTTPTransport) handleLookup(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		http.Error(w, "key is required", http.StatusBadRequest)
+		return
+	}
+
+	route, err := t.vnode(r.Context()).Route(r.Context(), key)
+	if err != nil {
+		t.logger.Error("Lookup", "failed to route key", "key", key, "err", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(route); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode route: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleDump handles requests to the "/dump" path
+// Returns the key-value pairs stored on this node with base64 values, never forwarded.
+// "?consistent=true" briefly blocks writes to take a point-in-time snapshot, e.g. for backups.
+func (t *HTTPTransport) handleDump(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	consistent := r.URL.Query().Get("consistent") == "true"
+	data := t.vnode(r.Context()).Dump(consistent)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode dump: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleStats handles requests to the "/stats" path
+func (t *HTTPTransport) handleStats(w http.ResponseWriter, r *http.Request) {
+
+	node := t.vnode(r.Context())
+
+	type Stats struct {
+		CurrentState    string `json:"current_state"`
+		SimCrashTotal   int    `json:"sim_crash_total"`
+		SimRecoverTotal int    `json:"sim_recover_total"`
+		LeaveTotal      int    `json:"leave_total"`
+		RejoinTotal     int    `json:"rejoin_total"`
+		LastCrashAt     string `json:"last_crash_at"`
+		LastRecoverAt   string `json:"last_recover_at"`
+		LastLeaveAt     string `json:"last_leave_at"`
+		LastRejoinAt    string `json:"last_rejoin_at"`
+
+		// Load shedding
+		Goroutines         int   `json:"goroutines"`
+		GoroutineSoftLimit int   `json:"goroutine_soft_limit"`
+		Overloaded         bool  `json:"overloaded"`
+		InFlight           int64 `json:"in_flight"`
+		MaxInFlight        int   `json:"max_in_flight"`
+		ShedTotal          int64 `json:"shed_total"`
+
+		// Storage capacity, fullness is 0 if unbounded
+		KeyCount       int     `json:"key_count"`
+		MaxKeys        int     `json:"max_keys"`
+		Fullness       float64 `json:"fullness"`
+		DataBytes      int64   `json:"data_bytes"`
+		MaxBytes       int     `json:"max_bytes"`
+		RejectWhenFull bool    `json:"reject_when_full"`
+
+		// Multiple of the maintenance interval between rounds, above 1 while peers are overloaded
+		MaintenanceBackoff int `json:"maintenance_backoff"`
+	}
+
+	t.stats.mu.Lock()
+	stats := Stats{
+		CurrentState:    t.stats.currentState,
+		SimCrashTotal:   t.stats.simCrashTotal,
+		SimRecoverTotal: t.stats.simRecoverTotal,
+		LeaveTotal:      t.stats.leaveTotal,
+		RejoinTotal:     t.stats.rejoinTotal,
+		LastCrashAt:     formatTime(t.stats.lastCrashAt),
+		LastRecoverAt:   formatTime(t.stats.lastRecoverAt),
+		LastLeaveAt:     formatTime(t.stats.lastLeaveAt),
+		LastRejoinAt:    formatTime(t.stats.lastRejoinAt),
+	}
+	t.stats.mu.Unlock()
+
+	stats.Goroutines = runtime.NumGoroutine()
+	stats.GoroutineSoftLimit = t.shedder.goroutineSoftLimit
+	stats.Overloaded = t.shedder.overloaded()
+	stats.InFlight = t.shedder.inFlight.Load()
+	stats.MaxInFlight = t.shedder.limit()
+	stats.ShedTotal = t.shedder.shedTotal.Load()
+
+	stats.KeyCount = node.KeyCount()
+	stats.MaxKeys, stats.MaxBytes, stats.RejectWhenFull = node.StorageLimit()
+	stats.DataBytes = node.StoredBytes()
+	if stats.MaxKeys > 0 {
+		stats.Fullness = float64(stats.KeyCount) / float64(stats.MaxKeys)
+	}
+	stats.MaintenanceBackoff = node.MaintenanceBackoff()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode stats: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
 // handleJoin handles requests to the "/join" path
 func (t *HTTPTransport) handleJoin(w http.ResponseWriter, r *http.Request) {
 
@@ -278,24 +1149,25 @@ func (t *HTTPTransport) handleJoin(w http.ResponseWriter, r *http.Request) {
 
 	// Get the nprime from the request
 	nprime := r.URL.Query().Get("nprime")
-
-	log.Printf("SERVER: Join request received, trying to join with nprime: %s", nprime)
-
-	// Find the successor the loner node from nprime
-	successorAddress, err := t.FindSuccessor(nprime, t.node.Id())
-	if err != nil {
-		log.Printf("ERROR: Failed to find successor this node %s: %v", nprime, err)
-		http.Error(w, "failed to find successor", http.StatusInternalServerError)
+	if nprime == "" {
+		http.Error(w, "missing nprime, the address of a ring node to join through", http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("SERVER: Successor address found = '%s', setting as successor", successorAddress)
-
-	// Set the successor of the node. The maintenance goroutine will update the successor of the node.
-	t.node.SetSuccessor(successorAddress)
+	t.logger.Info("Join", "join request received", "nprime", nprime)
 
-	// Set the node to active so it starts processing requests.
-	t.inactive = false
+	if err := t.Join(nprime); err != nil {
+		t.logger.Error("Join", "failed to join", "nprime", nprime, "err", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, errSeedUnreachable) {
+			status = http.StatusBadGateway
+		}
+		if errors.Is(err, dht.ErrIdCollision) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -308,18 +1180,80 @@ func (t *HTTPTransport) handleLeave(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Println("SERVER: Leave request received")
+	t.logger.Info("Leave", "leave request received")
+
+	result, left, err := t.Leave()
+	if errors.Is(err, errLeaveInProgress) || errors.Is(err, errCrashed) {
+		http.Error(w, fmt.Sprintf("cannot leave: %v", err), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !left {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// 202 if a neighbour missed the link updates
+	status := http.StatusOK
+	if !result.Complete() {
+		status = http.StatusAccepted
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		t.logger.Error("Leave", "failed to encode leave result", "err", err)
+	}
+}
+
+// handleRejoin handles requests to the "/rejoin" path
+// A node that left the ring joins it again through nprime with the same id and address. The node,
+// its maintenance loop and the transport are kept, the node pulls the keys it owns again.
+func (t *HTTPTransport) handleRejoin(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	nprime := r.URL.Query().Get("nprime")
+	if nprime == "" {
+		http.Error(w, "missing nprime, the address of a ring node to join through", http.StatusBadRequest)
+		return
+	}
+	if _, local := t.localVnode(nprime); local {
+		http.Error(w, "nprime is served by this node, rejoin through another ring node", http.StatusBadRequest)
+		return
+	}
+
+	t.logger.Info("Rejoin", "rejoin request received", "nprime", nprime)
 
-	// Immedately stop processing requests.
-	t.inactive = true
+	if err := t.beginRejoin(); err != nil {
+		t.logger.Warn("Rejoin", "rejoin refused", "err", err)
+		http.Error(w, fmt.Sprintf("cannot rejoin: %v", err), http.StatusConflict)
+		return
+	}
 
-	// Make the node "plug" the hole in the ring and return to starting state.
-	err := t.node.Leave()
+	// Storage requests are refused until the keys are pulled, like on the startup join
+	t.warming.Store(true)
+	err := t.Join(nprime)
+	t.warming.Store(false)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to leave: %v", err), http.StatusInternalServerError)
+		t.logger.Error("Rejoin", "failed to rejoin, still left", "nprime", nprime, "err", err)
+		t.endRejoin(false)
+		status := http.StatusInternalServerError
+		if errors.Is(err, errSeedUnreachable) {
+			status = http.StatusBadGateway
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
 
+	t.endRejoin(true)
+	t.logger.Info("Rejoin", "rejoined the ring", "nprime", nprime)
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -331,9 +1265,13 @@ func (t *HTTPTransport) handleSimCrash(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Println("SERVER: Sim crash request received")
+	t.logger.Info("SimCrash", "sim crash request received")
 
-	t.inactive = true
+	if err := t.simCrash(); err != nil {
+		t.logger.Warn("SimCrash", "sim crash refused", "err", err)
+		http.Error(w, fmt.Sprintf("cannot crash: %v", err), http.StatusConflict)
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -345,57 +1283,203 @@ func (t *HTTPTransport) handleSimRecover(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	log.Println("SERVER: Recovery request received")
+	t.logger.Info("SimRecover", "recovery request received")
 
-	t.inactive = false
+	if err := t.simRecover(); err != nil {
+		t.logger.Warn("SimRecover", "recovery refused", "err", err)
+		http.Error(w, fmt.Sprintf("cannot recover: %v", err), http.StatusConflict)
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 
 }
 
 // HELPER
 
-func forwardRequest(w http.ResponseWriter, method, url string, body []byte) {
+// forwardRequest forwards the request to the url and copies the response back.
+// The forward is tied to the inbound request's context, so a client disconnect cancels the downstream chain.
+// It ends at the deadline of the whole request, which is passed on to the next hop, or after the
+// forward timeout, both answered with 504 Gateway Timeout.
+func (t *HTTPTransport) forwardRequest(ctx context.Context, w http.ResponseWriter, method, url string, body io.Reader, header http.Header, deadline time.Time) {
 
-	var req *http.Request
-	var err error
+	hopCtx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
 
-	if body != nil {
-		req, err = http.NewRequest(method, url, bytes.NewReader(body))
-	} else {
-		req, err = http.NewRequest(method, url, nil)
-	}
+	req, err := http.NewRequestWithContext(hopCtx, method, url, body)
 	if err != nil {
-		log.Printf("ERROR: Failed to create request for %s: %v", url, err)
+		t.logger.Error("Forward", "failed to create request", "url", url, "err", err)
 		http.Error(w, fmt.Sprintf("failed to create request: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set(hopCountHeader, strconv.Itoa(hopCount(header)+1))
+	req.Header.Set(visitedHeader, strings.Join(append(visitedNodes(header), t.vnode(ctx).Address()), ","))
 	if method == http.MethodPut {
 		req.Header.Set("Content-Type", "text/plain")
 	}
+	req.Header.Set(forwardedHeader, t.address)
+	req.Header.Set(deadlineHeader, strconv.FormatInt(time.Until(deadline).Milliseconds(), 10))
 
-	// Add timeout to prevent hanging
-	client := &http.Client{
-		Timeout: 5 * time.Second,
-	}
-
-	resp, err := client.Do(req)
+	// The forward client times out to prevent hanging
+	resp, err := t.forwardClient.Do(req)
 	if err != nil {
-		log.Printf("ERROR: Failed to forward %s to %s: %v", method, url, err)
+		if ctx.Err() != nil {
+			t.logger.Info("Forward", "forward cancelled, client disconnected", "method", method, "url", url, "err", ctx.Err())
+			return
+		}
+		if hopCtx.Err() != nil {
+			t.logger.Warn("Forward", "deadline exceeded", "method", method, "url", url, "err", err)
+			http.Error(w, "deadline of the request exceeded while forwarding", http.StatusGatewayTimeout)
+			return
+		}
+		if isTimeout(err) {
+			t.logger.Warn("Forward", "forward timed out", "method", method, "url", url, "timeout", t.forwardTimeout, "err", err)
+			http.Error(w, fmt.Sprintf("forward timed out after %v", t.forwardTimeout), http.StatusGatewayTimeout)
+			return
+		}
+		t.logger.Error("Forward", "failed to forward", "method", method, "url", url, "err", err)
 		http.Error(w, fmt.Sprintf("failed to forward request: %v", err), http.StatusInternalServerError)
 		return
 	}
 	defer resp.Body.Close()
 
-	log.Printf("Forwarded %s to %s with status code %d", method, url, resp.StatusCode)
+	t.logger.Info("Forward", "forwarded", "method", method, "url", url, "status", resp.StatusCode, "hops", resp.Header.Get(hopCountHeader))
+
+	// Pass the owner's headers on, e.g. the hop count and Retry-After
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
 	w.WriteHeader(resp.StatusCode)
 	_, err = io.Copy(w, resp.Body)
 	if err != nil {
-		log.Printf("ERROR: Failed to copy response from %s: %v", url, err)
+		t.logger.Error("Forward", "failed to copy response", "url", url, "err", err)
+	}
+}
+
+// parseTTL returns the expiry of a PUT or batch POST from the X-TTL-Seconds header, zero if not set
+func parseTTL(r *http.Request) (time.Duration, error) {
+	header := r.Header.Get(ttlHeader)
+	if header == "" || (r.Method != http.MethodPut && r.Method != http.MethodPost) {
+		return 0, nil
 	}
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds <= 0 {
+		return 0, fmt.Errorf("invalid %s '%s', must be a positive number of seconds", ttlHeader, header)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// etag returns the ETag of a value's version, the version in double quotes
+func etag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
+// parseETag returns the version of an ETag, false if the header is not a version in double quotes
+func parseETag(header string) (uint64, bool) {
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.ParseUint(header[1:len(header)-1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return version, true
+}
+
+// storageHeaders returns the headers of a storage request that are kept when forwarding it
+func storageHeaders(r *http.Request) http.Header {
+	header := http.Header{}
+	if ttl := r.Header.Get(ttlHeader); ttl != "" {
+		header.Set(ttlHeader, ttl)
+	}
+	if idempotencyKey := r.Header.Get(idempotencyKeyHeader); idempotencyKey != "" {
+		header.Set(idempotencyKeyHeader, idempotencyKey)
+	}
+	if expected, conditional := r.Header[ifMatchHeader]; conditional {
+		header[ifMatchHeader] = expected
+	}
+	if hops := r.Header.Get(hopCountHeader); hops != "" {
+		header.Set(hopCountHeader, hops)
+	}
+	if visited := r.Header.Get(visitedHeader); visited != "" {
+		header.Set(visitedHeader, visited)
+	}
+	return header
+}
+
+// visitedNodes returns the addresses a forwarded request has visited, empty if not forwarded
+func visitedNodes(header http.Header) []string {
+	visited := header.Get(visitedHeader)
+	if visited == "" {
+		return nil
+	}
+	return strings.Split(visited, ",")
+}
+
+// hopCount returns the hop count of a forwarded request, zero if not forwarded
+func hopCount(header http.Header) int {
+	hops, err := strconv.Atoi(header.Get(hopCountHeader))
+	if err != nil || hops < 0 {
+		return 0
+	}
+	return hops
+}
+
+// forwardedRequest sends a request to another node marked as a forwarded hop
+// Used by the ring traversals, which visit each node on its advertised rpc address
+func (t *HTTPTransport) forwardedRequest(ctx context.Context, method string, url string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(forwardedHeader, t.address)
+	return t.traversalClient.Do(req)
+}
+
+// successorSelfWithPeers returns the peers in the finger table of the node if its successor is self, otherwise nil
+func successorSelfWithPeers(node dht.INode) []string {
+	self := node.Address()
+	if _, succAddr := node.Successor(); succAddr != self {
+		return nil
+	}
+
+	var peers []string
+	seen := make(map[string]bool)
+	for _, addr := range node.FingerTable() {
+		if addr != self && addr != "" && !seen[addr] {
+			peers = append(peers, addr)
+			seen[addr] = true
+		}
+	}
+	return peers
+}
+
+// refuseMovingKey returns a 503 with Retry-After for a write to a key that is being moved
+func (t *HTTPTransport) refuseMovingKey(w http.ResponseWriter, key string) {
+	t.logger.Info("Storage", "write refused, key is being moved", "key", key)
+	w.Header().Set("Retry-After", "1")
+	w.Header().Set(unavailableReasonHeader, reasonQuiesced)
+	http.Error(w, "key is being moved, retry", http.StatusServiceUnavailable)
+}
+
+// refuseLoop returns a 508 Loop Detected with the forwarding chain for a request that came back to the node at self
+func (t *HTTPTransport) refuseLoop(w http.ResponseWriter, self string, key string, visited []string) {
+	chain := append(visited, self)
+	t.logger.Warn("Storage", "forwarding loop detected", "key", key, "chain", strings.Join(chain, " -> "))
+	w.Header().Set(visitedHeader, strings.Join(chain, ","))
+	http.Error(w, "loop detected, forwarding chain: "+strings.Join(chain, " -> "), http.StatusLoopDetected)
+}
+
+// refuseRequest returns a 503 Service Unavailable response with the reason
+func refuseRequest(w http.ResponseWriter, r *http.Request, reason string) {
+	w.Header().Set(unavailableReasonHeader, reason)
+	http.Error(w, unavailableMessage(reason), http.StatusServiceUnavailable)
 }
 
-// refuseRequest returns a 503 Service Unavailable response
-func refuseRequest(w http.ResponseWriter, r *http.Request) {
-	http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+// unavailableMessage returns the message of a request refused for the reason
+func unavailableMessage(reason string) string {
+	return "service unavailable: " + reason
 }
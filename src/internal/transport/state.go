@@ -0,0 +1,179 @@
+package transport
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Node states reported in /stats
+const (
+	stateActive  = "active"
+	stateCrashed = "crashed"
+	stateLeaving = "leaving" // handing off data and rewiring the neighbours, not inactive yet
+	stateLeft    = "left"
+)
+
+// Errors returned for a state transition that conflicts with the current state
+// A leave in progress supersedes a crash or recovery, and a crashed node can't leave until it recovers.
+var (
+	errLeaveInProgress = errors.New("leave in progress")
+	errAlreadyLeft     = errors.New("node has left the ring")
+	errCrashed         = errors.New("node is crashed, recover it first")
+	errNotLeft         = errors.New("node has not left the ring")
+)
+
+// stateStats records the inactive/crash state transitions of the node for post-test analysis
+type stateStats struct {
+	mu              sync.Mutex
+	currentState    string
+	simCrashTotal   int
+	simRecoverTotal int
+	leaveTotal      int
+	rejoinTotal     int
+	lastCrashAt     time.Time
+	lastRecoverAt   time.Time
+	lastLeaveAt     time.Time
+	lastRejoinAt    time.Time
+}
+
+// simCrash marks the node as inactive after a simulated crash
+// Refused while leaving or after the node left, a repeated crash is a no-op.
+func (t *HTTPTransport) simCrash() error {
+	t.stats.mu.Lock()
+	defer t.stats.mu.Unlock()
+
+	switch t.stats.currentState {
+	case stateLeaving:
+		return errLeaveInProgress
+	case stateLeft:
+		return errAlreadyLeft
+	}
+
+	t.stats.simCrashTotal++
+	t.stats.lastCrashAt = time.Now()
+	t.transition(stateCrashed)
+	return nil
+}
+
+// simRecover marks the node as active after a simulated recovery
+// Refused while leaving, the leave decides whether the node ends up active or left, and after the
+// node left, it has no ring links and has to rejoin.
+func (t *HTTPTransport) simRecover() error {
+	t.stats.mu.Lock()
+	defer t.stats.mu.Unlock()
+
+	switch t.stats.currentState {
+	case stateLeaving:
+		return errLeaveInProgress
+	case stateLeft:
+		return errAlreadyLeft
+	}
+
+	t.stats.simRecoverTotal++
+	t.stats.lastRecoverAt = time.Now()
+	t.transition(stateActive)
+	return nil
+}
+
+// beginLeave marks the node as leaving. Storage requests are refused while it drains, ring RPCs
+// such as the handoff are still served, the node only becomes inactive in endLeave. Returns false without changing anything if the node has already left, and an error if
+// another leave is in progress or the node is crashed.
+func (t *HTTPTransport) beginLeave() (bool, error) {
+	t.stats.mu.Lock()
+	defer t.stats.mu.Unlock()
+
+	switch t.stats.currentState {
+	case stateLeft:
+		return false, nil
+	case stateLeaving:
+		return false, errLeaveInProgress
+	case stateCrashed:
+		return false, errCrashed
+	}
+
+	t.transition(stateLeaving)
+	return true, nil
+}
+
+// endLeave moves a leaving node to left, or back to active if the leave was aborted
+func (t *HTTPTransport) endLeave(left bool) {
+	t.stats.mu.Lock()
+	defer t.stats.mu.Unlock()
+
+	if !left {
+		t.transition(stateActive)
+		return
+	}
+
+	t.stats.leaveTotal++
+	t.stats.lastLeaveAt = time.Now()
+	t.transition(stateLeft)
+}
+
+// beginRejoin marks a node that left the ring as active again while it rejoins, so the ring
+// RPCs of the join reach it. Refused unless the node has left, e.g. to a second rejoin in progress.
+func (t *HTTPTransport) beginRejoin() error {
+	t.stats.mu.Lock()
+	defer t.stats.mu.Unlock()
+
+	if t.stats.currentState != stateLeft {
+		return errNotLeft
+	}
+
+	t.transition(stateActive)
+	return nil
+}
+
+// endRejoin counts a completed rejoin, or moves the node back to left if the rejoin failed
+func (t *HTTPTransport) endRejoin(joined bool) {
+	t.stats.mu.Lock()
+	defer t.stats.mu.Unlock()
+
+	if !joined {
+		t.transition(stateLeft)
+		return
+	}
+
+	t.stats.rejoinTotal++
+	t.stats.lastRejoinAt = time.Now()
+}
+
+// unavailableReason returns the reason an inactive node refuses requests
+func (t *HTTPTransport) unavailableReason() string {
+	t.stats.mu.Lock()
+	defer t.stats.mu.Unlock()
+
+	switch t.stats.currentState {
+	case stateLeaving, stateLeft:
+		return reasonQuiesced
+	}
+	return reasonCrashed
+}
+
+// markActive marks the node as active after joining a ring
+func (t *HTTPTransport) markActive() {
+	t.stats.mu.Lock()
+	defer t.stats.mu.Unlock()
+
+	t.transition(stateActive)
+}
+
+// transition updates the inactive and draining flags to match the state, stats lock must be held
+// A leaving node is only draining, it turns inactive once the leave is over.
+func (t *HTTPTransport) transition(state string) {
+	previous := t.stats.currentState
+	t.stats.currentState = state
+	t.inactive.Store(state != stateActive && state != stateLeaving)
+	t.draining.Store(state == stateLeaving)
+
+	t.logger.Info("Transition", "state transition", "from", previous, "to", state)
+}
+
+// formatTime returns the time in RFC3339 format, or empty if the time was never set
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,208 @@
+package transport
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"assignment/internal/dht"
+)
+
+// Run with -race, the crash state is written by the transitions while the requests read it
+func TestCrashTogglesWhilePinging(t *testing.T) {
+	tr := newTestTransport(t, dht.Create("127.0.0.1:1", dht.WithLogger(quietLogger())))
+	tr.markActive()
+
+	const toggles, pingers = 200, 4
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	stop := sync.OnceFunc(func() {
+		close(done)
+		wg.Wait()
+	})
+	defer stop()
+	for range pingers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				_ = tr.IsInactive()
+				w := serve(tr, http.MethodGet, "/ping", nil, nil)
+				switch {
+				case w.Code == http.StatusOK:
+				case w.Code == http.StatusServiceUnavailable && w.Header().Get(unavailableReasonHeader) == reasonCrashed:
+				default:
+					t.Errorf("GET /ping: status %d reason %q, want 200 or 503 %q", w.Code, w.Header().Get(unavailableReasonHeader), reasonCrashed)
+					return
+				}
+			}
+		}()
+	}
+
+	for range toggles {
+		if w := serve(tr, http.MethodPost, "/sim-crash", nil, nil); w.Code != http.StatusOK {
+			t.Fatalf("POST /sim-crash: status %d: %s", w.Code, w.Body)
+		}
+		if w := serve(tr, http.MethodPost, "/sim-recover", nil, nil); w.Code != http.StatusOK {
+			t.Fatalf("POST /sim-recover: status %d: %s", w.Code, w.Body)
+		}
+	}
+	stop()
+
+	if tr.IsInactive() {
+		t.Error("node is inactive after its last recovery")
+	}
+	if w := serve(tr, http.MethodGet, "/ping", nil, nil); w.Code != http.StatusOK {
+		t.Errorf("GET /ping after the last recovery: status %d, want 200", w.Code)
+	}
+}
+
+// stateReport is the state transition part of the /stats response
+type stateReport struct {
+	CurrentState    string `json:"current_state"`
+	SimCrashTotal   int    `json:"sim_crash_total"`
+	SimRecoverTotal int    `json:"sim_recover_total"`
+	LastCrashAt     string `json:"last_crash_at"`
+}
+
+// getStateStats returns the state transitions reported in /stats
+func getStateStats(t *testing.T, tr *HTTPTransport) stateReport {
+	t.Helper()
+	w := serve(tr, http.MethodGet, "/stats", nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /stats: status %d: %s", w.Code, w.Body)
+	}
+	var stats stateReport
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("failed to decode stats: %v", err)
+	}
+	return stats
+}
+
+func TestStatsCountCrashAndRecover(t *testing.T) {
+	tr := newTestTransport(t, dht.Create("127.0.0.1:1", dht.WithLogger(quietLogger())))
+	tr.markActive()
+
+	var lastCrashAt time.Time
+	for i := 1; i <= 3; i++ {
+		if w := serve(tr, http.MethodPost, "/sim-crash", nil, nil); w.Code != http.StatusOK {
+			t.Fatalf("POST /sim-crash %d: status %d: %s", i, w.Code, w.Body)
+		}
+		stats := getStateStats(t, tr)
+		if stats.CurrentState != stateCrashed || stats.SimCrashTotal != i || stats.SimRecoverTotal != i-1 {
+			t.Errorf("stats after crash %d = %+v, want %s with %d crashes and %d recoveries", i, stats, stateCrashed, i, i-1)
+		}
+		crashAt, err := time.Parse(time.RFC3339Nano, stats.LastCrashAt)
+		if err != nil {
+			t.Fatalf("last_crash_at %q: %v", stats.LastCrashAt, err)
+		}
+		if crashAt.Before(lastCrashAt) {
+			t.Errorf("last_crash_at went back from %v to %v", lastCrashAt, crashAt)
+		}
+		lastCrashAt = crashAt
+
+		if w := serve(tr, http.MethodPost, "/sim-recover", nil, nil); w.Code != http.StatusOK {
+			t.Fatalf("POST /sim-recover %d: status %d: %s", i, w.Code, w.Body)
+		}
+		stats = getStateStats(t, tr)
+		if stats.CurrentState != stateActive || stats.SimRecoverTotal != i {
+			t.Errorf("stats after recovery %d = %+v, want %s with %d recoveries", i, stats, stateActive, i)
+		}
+	}
+}
+
+func TestRecoverRefusedAfterLeave(t *testing.T) {
+	nodes := dht.BuildRing(3)
+	tr := newTestTransport(t, nodes[1])
+	tr.markActive()
+
+	if w := serve(tr, http.MethodPost, "/leave", nil, nil); w.Code != http.StatusOK {
+		t.Fatalf("POST /leave: status %d: %s", w.Code, w.Body)
+	}
+	if w := serve(tr, http.MethodPost, "/sim-recover", nil, nil); w.Code != http.StatusConflict {
+		t.Errorf("POST /sim-recover after the leave: status %d, want 409", w.Code)
+	}
+	stats := getStateStats(t, tr)
+	if stats.CurrentState != stateLeft || stats.SimRecoverTotal != 0 {
+		t.Errorf("stats after the refused recovery = %+v, want %s with no recoveries", stats, stateLeft)
+	}
+	if w := serve(tr, http.MethodGet, "/ping", nil, nil); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /ping after the refused recovery: status %d, want 503", w.Code)
+	}
+}
+
+// movingNode is a node whose keys are all being handed off, writes are refused with dht.ErrKeyMoving
+type movingNode struct {
+	*dht.Node
+}
+
+func (movingNode) Put(key string, value []byte, ttl time.Duration) (uint64, string, error) {
+	return 0, "", dht.ErrKeyMoving
+}
+
+func TestUnavailableCarriesReason(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		setup  func(t *testing.T, tr *HTTPTransport) // puts the transport in the unavailable condition
+		node   func(node *dht.Node) dht.INode
+		want   string
+	}{
+		{"crashed", http.MethodGet, "/storage/key", func(t *testing.T, tr *HTTPTransport) {
+			if err := tr.simCrash(); err != nil {
+				t.Fatalf("simCrash: %v", err)
+			}
+		}, nil, reasonCrashed},
+		{"crashed ping", http.MethodGet, "/ping", func(t *testing.T, tr *HTTPTransport) {
+			if err := tr.simCrash(); err != nil {
+				t.Fatalf("simCrash: %v", err)
+			}
+		}, nil, reasonCrashed},
+		{"left", http.MethodGet, "/storage/key", func(t *testing.T, tr *HTTPTransport) {
+			if w := serve(tr, http.MethodPost, "/leave", nil, nil); w.Code != http.StatusOK {
+				t.Fatalf("POST /leave: status %d: %s", w.Code, w.Body)
+			}
+		}, nil, reasonQuiesced},
+		{"leaving", http.MethodPut, "/storage/key", func(t *testing.T, tr *HTTPTransport) {
+			tr.stats.mu.Lock()
+			tr.transition(stateLeaving)
+			tr.stats.mu.Unlock()
+		}, nil, reasonQuiesced},
+		{"key moving", http.MethodPut, "/storage/key", nil, func(node *dht.Node) dht.INode {
+			return movingNode{node}
+		}, reasonQuiesced},
+		{"overloaded", http.MethodGet, "/storage/key", func(t *testing.T, tr *HTTPTransport) {
+			tr.shedder.inFlight.Add(1) // a request already in flight at the cap
+		}, nil, reasonOverloaded},
+		{"warming", http.MethodGet, "/storage/key", func(t *testing.T, tr *HTTPTransport) {
+			tr.warming.Store(true)
+		}, nil, reasonWarming},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var node dht.INode = dht.Create("127.0.0.1:1", dht.WithLogger(quietLogger()))
+			if tt.node != nil {
+				node = tt.node(node.(*dht.Node))
+			}
+			tr := newTestTransport(t, node, WithMaxInFlight(1))
+			tr.markActive()
+			if tt.setup != nil {
+				tt.setup(t, tr)
+			}
+
+			w := serve(tr, tt.method, tt.path, []byte("value"), nil)
+			if w.Code != http.StatusServiceUnavailable || w.Header().Get(unavailableReasonHeader) != tt.want {
+				t.Errorf("%s %s: status %d reason %q, want 503 %q: %s", tt.method, tt.path, w.Code, w.Header().Get(unavailableReasonHeader), tt.want, w.Body)
+			}
+		})
+	}
+}
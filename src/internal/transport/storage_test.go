@@ -0,0 +1,636 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"assignment/internal/dht"
+)
+
+func TestPutETagIncrements(t *testing.T) {
+	node := dht.Create("127.0.0.1:1", dht.WithLogger(quietLogger()))
+	tr := newTestTransport(t, node)
+
+	for i, want := range []string{`"1"`, `"2"`, `"3"`} {
+		w := serve(tr, http.MethodPut, "/storage/key", []byte{byte('a' + i)}, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("PUT %d: status %d: %s", i+1, w.Code, w.Body)
+		}
+		if got := w.Header().Get(etagHeader); got != want {
+			t.Errorf("ETag of PUT %d = %s, want %s", i+1, got, want)
+		}
+	}
+
+	w := serve(tr, http.MethodGet, "/storage/key", nil, nil)
+	if w.Code != http.StatusOK || w.Body.String() != "c" {
+		t.Fatalf("GET: status %d, body %q, want 200 \"c\"", w.Code, w.Body)
+	}
+	if got := w.Header().Get(etagHeader); got != `"3"` {
+		t.Errorf("ETag of GET = %s, want \"3\"", got)
+	}
+}
+
+func TestPutRejectsNewKeysWhenFull(t *testing.T) {
+	const maxKeys = 3
+	node := dht.Create("127.0.0.1:1", dht.WithLogger(quietLogger()), dht.WithMaxKeys(maxKeys, true))
+	tr := newTestTransport(t, node)
+
+	put := func(key string, want int) {
+		t.Helper()
+		if w := serve(tr, http.MethodPut, "/storage/"+key, []byte("value of "+key), nil); w.Code != want {
+			t.Fatalf("PUT %s: status %d, want %d: %s", key, w.Code, want, w.Body)
+		}
+	}
+	for i := range maxKeys {
+		put(fmt.Sprintf("key-%d", i), http.StatusOK)
+	}
+
+	// At the limit a new key is refused, a stored key can still be overwritten
+	put("key-new", http.StatusInsufficientStorage)
+	put("key-1", http.StatusOK)
+	if w := serve(tr, http.MethodGet, "/storage/key-1", nil, nil); w.Code != http.StatusOK || w.Header().Get(etagHeader) != `"2"` {
+		t.Errorf("GET key-1 after the overwrite: status %d, ETag %s, want 200 \"2\"", w.Code, w.Header().Get(etagHeader))
+	}
+
+	// A delete makes room again
+	if w := serve(tr, http.MethodDelete, "/storage/key-0", nil, nil); w.Code != http.StatusOK {
+		t.Fatalf("DELETE key-0: status %d: %s", w.Code, w.Body)
+	}
+	put("key-new", http.StatusOK)
+	put("key-other", http.StatusInsufficientStorage)
+}
+
+func TestPutClampsTTL(t *testing.T) {
+	const maxTTL = time.Minute
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{"above the ceiling", "3600", maxTTL},
+		{"no ttl", "", maxTTL},
+		{"below the ceiling", "30", 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := dht.Create("127.0.0.1:1", dht.WithLogger(quietLogger()), dht.WithMaxTTL(maxTTL))
+			tr := newTestTransport(t, node)
+
+			header := http.Header{}
+			if tt.header != "" {
+				header.Set(ttlHeader, tt.header)
+			}
+			before := time.Now()
+			if w := serve(tr, http.MethodPut, "/storage/key", []byte("value"), header); w.Code != http.StatusOK {
+				t.Fatalf("PUT: status %d: %s", w.Code, w.Body)
+			}
+			after := time.Now()
+
+			value, _, err := node.Get("key")
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if value.ExpiresAt.Before(before.Add(tt.want)) || value.ExpiresAt.After(after.Add(tt.want)) {
+				t.Errorf("key expires in %v, want %v", value.ExpiresAt.Sub(before).Round(time.Second), tt.want)
+			}
+		})
+	}
+}
+
+// Keys that change the meaning of the URL if sent unescaped
+var unsafeKeys = []string{"a?b=c", "frag#ment", "100%", "%41", "dir/file", "sp ace"}
+
+func TestStorageKeysAreEscaped(t *testing.T) {
+	node := dht.Create("127.0.0.1:1", dht.WithLogger(quietLogger()))
+	tr := newTestTransport(t, node)
+
+	for _, key := range unsafeKeys {
+		if w := serve(tr, http.MethodPut, "/storage/"+url.PathEscape(key), []byte("value of "+key), nil); w.Code != http.StatusOK {
+			t.Fatalf("PUT %q: status %d: %s", key, w.Code, w.Body)
+		}
+		if value, _, err := node.Get(key); err != nil || string(value.Value) != "value of "+key {
+			t.Errorf("key %q stored as %q, %v", key, value.Value, err)
+		}
+	}
+}
+
+func TestStoreKeyEscapesKey(t *testing.T) {
+	node := dht.Create("127.0.0.1:1", dht.WithLogger(quietLogger()))
+	owner := httptest.NewServer(newTestTransport(t, node).server.Handler)
+	defer owner.Close()
+	addr := strings.TrimPrefix(owner.URL, "http://")
+
+	tr := newTestTransport(t, dht.Create("127.0.0.1:2", dht.WithLogger(quietLogger())))
+	for _, key := range unsafeKeys {
+		if err := tr.StoreKey(addr, key, []byte("v")); err != nil {
+			t.Fatalf("StoreKey(%q): %v", key, err)
+		}
+		if _, _, err := node.Get(key); err != nil {
+			t.Errorf("key %q not stored on the owner: %v", key, err)
+		}
+	}
+}
+
+func TestForwardedKeysAreEscaped(t *testing.T) {
+	nodes := dht.BuildRing(2)
+	tr := newTestTransport(t, nodes[0])
+	header := http.Header{preferRedirectHeader: []string{"true"}}
+
+	forwarded := 0
+	for _, key := range unsafeKeys {
+		if nodes[0].NextHop(key) == "" {
+			continue
+		}
+		forwarded++
+		for _, query := range []string{"", "?consistency=quorum"} {
+			w := serve(tr, http.MethodGet, "/storage/"+url.PathEscape(key)+query, nil, header)
+			if w.Code != http.StatusTemporaryRedirect {
+				t.Fatalf("GET %q%s: status %d, want 307: %s", key, query, w.Code, w.Body)
+			}
+			location, err := url.Parse(w.Header().Get("Location"))
+			if err != nil {
+				t.Fatalf("invalid location %q: %v", w.Header().Get("Location"), err)
+			}
+			if got := strings.TrimPrefix(location.Path, "/storage/"); got != key {
+				t.Errorf("GET %q%s redirected to the key %q", key, query, got)
+			}
+			if want := strings.TrimPrefix(query, "?"); location.RawQuery != want {
+				t.Errorf("GET %q%s redirected with the query %q, want %q", key, query, location.RawQuery, want)
+			}
+		}
+	}
+	if forwarded == 0 {
+		t.Fatal("no key is forwarded")
+	}
+}
+
+func TestHandoffRouteIsNotAKey(t *testing.T) {
+	node := dht.Create("127.0.0.1:1", dht.WithLogger(quietLogger()))
+	tr := newTestTransport(t, node)
+
+	if w := serve(tr, http.MethodPut, "/storage/handoff", []byte("user value"), nil); w.Code != http.StatusOK {
+		t.Fatalf("PUT /storage/handoff: status %d: %s", w.Code, w.Body)
+	}
+
+	// A client can't confirm a handoff of the key and have it deleted
+	body := []byte(`{"handoff": {"value": "dXNlciB2YWx1ZQ==", "version": 1}}`)
+	if w := serve(tr, http.MethodPost, "/storage/handoff", body, nil); w.Code == http.StatusOK {
+		t.Errorf("POST /storage/handoff: status 200, want the storage endpoint to refuse it")
+	}
+
+	w := serve(tr, http.MethodGet, "/storage/handoff", nil, nil)
+	if w.Code != http.StatusOK || w.Body.String() != "user value" {
+		t.Errorf("GET /storage/handoff: status %d, body %q, want 200 \"user value\"", w.Code, w.Body)
+	}
+}
+
+func TestBatchRouteIsNotAKey(t *testing.T) {
+	node := dht.Create("127.0.0.1:1", dht.WithLogger(quietLogger()))
+	tr := newTestTransport(t, node)
+
+	if w := serve(tr, http.MethodPut, "/storage/batch", []byte("user value"), nil); w.Code != http.StatusOK {
+		t.Fatalf("PUT /storage/batch: status %d: %s", w.Code, w.Body)
+	}
+	w := serve(tr, http.MethodGet, "/storage/batch", nil, nil)
+	if w.Code != http.StatusOK || w.Body.String() != "user value" {
+		t.Errorf("GET /storage/batch: status %d, body %q, want 200 \"user value\"", w.Code, w.Body)
+	}
+
+	if w := serve(tr, http.MethodPost, "/batch", []byte(`{"a": "aGVsbG8="}`), nil); w.Code != http.StatusOK {
+		t.Fatalf("POST /batch: status %d: %s", w.Code, w.Body)
+	}
+	w = serve(tr, http.MethodGet, "/storage/a", nil, nil)
+	if w.Code != http.StatusOK || w.Body.String() != "hello" {
+		t.Errorf("GET of a batch key: status %d, body %q, want 200 \"hello\"", w.Code, w.Body)
+	}
+}
+
+// roundTripFunc is an http.RoundTripper calling the function, e.g. a slow next hop
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// forwardedKey returns a key the node forwards to another node
+func forwardedKey(t *testing.T, node *dht.Node) string {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		if key := fmt.Sprintf("key-%d", i); node.NextHop(key) != "" {
+			return key
+		}
+	}
+	t.Fatal("no key is forwarded")
+	return ""
+}
+
+// routeForwards passes the storage forwards of every transport of the ring to the handler of the
+// transport they are addressed to
+func routeForwards(ring map[string]*HTTPTransport) {
+	for _, tr := range ring {
+		tr.forwardClient.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			next := ring[r.URL.Host]
+			if next == nil {
+				return nil, fmt.Errorf("%s is not a node of the ring", r.URL.Host)
+			}
+			var body []byte
+			if r.Body != nil {
+				var err error
+				if body, err = io.ReadAll(r.Body); err != nil {
+					return nil, err
+				}
+			}
+			return serve(next, r.Method, r.URL.RequestURI(), body, r.Header).Result(), nil
+		})
+	}
+}
+
+func TestForwardRespectsRequestDeadline(t *testing.T) {
+	const budget, slack = 200 * time.Millisecond, 150 * time.Millisecond
+	nodes := dht.BuildRing(2)
+	key := forwardedKey(t, nodes[0])
+
+	tests := []struct {
+		name     string
+		header   string        // X-DHT-Deadline-Ms sent by the client, none if empty
+		wantLeft time.Duration // most of the deadline the next hop may be given
+	}{
+		{"request timeout", "", budget},
+		{"shorter client deadline", "100", 100 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := newTestTransport(t, nodes[0], WithRequestTimeout(budget))
+
+			// The next hop never answers, it only records the deadline it was given
+			var forwardedMs string
+			tr.forwardClient.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				forwardedMs = r.Header.Get(deadlineHeader)
+				<-r.Context().Done()
+				return nil, r.Context().Err()
+			})
+
+			header := http.Header{}
+			if tt.header != "" {
+				header.Set(deadlineHeader, tt.header)
+			}
+			start := time.Now()
+			w := serve(tr, http.MethodGet, "/storage/"+key, nil, header)
+			elapsed := time.Since(start)
+
+			if w.Code != http.StatusGatewayTimeout {
+				t.Errorf("GET through a slow next hop: status %d, want 504: %s", w.Code, w.Body)
+			}
+			if elapsed > tt.wantLeft+slack {
+				t.Errorf("GET through a slow next hop took %v, want about %v", elapsed, tt.wantLeft)
+			}
+			ms, err := strconv.Atoi(forwardedMs)
+			if err != nil {
+				t.Fatalf("%s forwarded to the next hop = %q: %v", deadlineHeader, forwardedMs, err)
+			}
+			if left := time.Duration(ms) * time.Millisecond; left <= 0 || left > tt.wantLeft {
+				t.Errorf("%s forwarded to the next hop = %v, want in (0, %v]", deadlineHeader, left, tt.wantLeft)
+			}
+		})
+	}
+}
+
+func TestForwardRefusesExpiredDeadline(t *testing.T) {
+	nodes := dht.BuildRing(2)
+	key := forwardedKey(t, nodes[0])
+	tr := newTestTransport(t, nodes[0])
+
+	forwarded := false
+	tr.forwardClient.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		forwarded = true
+		return nil, fmt.Errorf("unexpected forward to %s", r.URL)
+	})
+
+	header := http.Header{}
+	header.Set(deadlineHeader, "0")
+	if w := serve(tr, http.MethodGet, "/storage/"+key, nil, header); w.Code != http.StatusGatewayTimeout {
+		t.Errorf("GET with an expired deadline: status %d, want 504: %s", w.Code, w.Body)
+	}
+	if forwarded {
+		t.Error("request with an expired deadline was forwarded")
+	}
+}
+
+// followRedirects sends the storage request to the transport and follows its 307 redirects through
+// the transports of the ring like a redirect-following client, returning the final response and
+// the hosts redirected to
+func followRedirects(t *testing.T, ring map[string]*HTTPTransport, tr *HTTPTransport, method string, target string, body []byte) (*httptest.ResponseRecorder, []string) {
+	t.Helper()
+	header := http.Header{}
+	header.Set(preferRedirectHeader, "true")
+	var hosts []string
+	for range len(ring) + 1 {
+		w := serve(tr, method, target, body, header)
+		if w.Code != http.StatusTemporaryRedirect {
+			return w, hosts
+		}
+		location, err := url.Parse(w.Header().Get("Location"))
+		if err != nil {
+			t.Fatalf("invalid location %q: %v", w.Header().Get("Location"), err)
+		}
+		hosts = append(hosts, location.Host)
+		if tr = ring[location.Host]; tr == nil {
+			t.Fatalf("%s %s redirected to %s, not a node of the ring", method, target, location)
+		}
+		target = location.RequestURI()
+	}
+	t.Fatalf("%s %s still redirected after %d hops: %v", method, target, len(ring)+1, hosts)
+	return nil, nil
+}
+
+func TestRedirectsReachOwner(t *testing.T) {
+	nodes := dht.BuildRing(5)
+	ring := make(map[string]*HTTPTransport)
+	for _, node := range nodes {
+		ring[node.Address()] = newTestTransport(t, node)
+	}
+	entry := nodes[0]
+
+	redirected := 0
+	for i := range 20 {
+		key := fmt.Sprintf("key-%d", i)
+		value := []byte("value of " + key)
+
+		w, hosts := followRedirects(t, ring, ring[entry.Address()], http.MethodPut, "/storage/"+key, value)
+		if w.Code != http.StatusOK {
+			t.Fatalf("PUT %q: status %d: %s", key, w.Code, w.Body)
+		}
+		if next := entry.NextHop(key); next != "" {
+			redirected++
+			if len(hosts) == 0 || hosts[0] != next {
+				t.Errorf("PUT %q first redirected to %v, want the next hop %s", key, hosts, next)
+			}
+		}
+
+		w, _ = followRedirects(t, ring, ring[entry.Address()], http.MethodGet, "/storage/"+key, nil)
+		if w.Code != http.StatusOK || w.Body.String() != string(value) {
+			t.Errorf("GET %q: status %d, body %q, want 200 %q", key, w.Code, w.Body, value)
+		}
+	}
+	if redirected == 0 {
+		t.Fatal("no key is redirected")
+	}
+}
+
+// newTestRing returns a transport for every node of the ring by address, forwarding to each other
+func newTestRing(t *testing.T, nodes []*dht.Node, opts ...Option) map[string]*HTTPTransport {
+	t.Helper()
+	ring := make(map[string]*HTTPTransport)
+	for _, node := range nodes {
+		ring[node.Address()] = newTestTransport(t, node, opts...)
+	}
+	routeForwards(ring)
+	return ring
+}
+
+// wedgeOwner points the owner of a key past it at a predecessor that leaves the key out, with
+// /admin/links, so the owner forwards the key back to the node in front of it. Returns the key.
+func wedgeOwner(t *testing.T, ring map[string]*HTTPTransport, from *dht.Node, owner *dht.Node) string {
+	t.Helper()
+	for port := 3000; port < 4000; port++ {
+		predecessor := fmt.Sprintf("127.0.0.1:%d", port)
+		predecessorId := dht.KeyToRingId(predecessor, dht.ID_SPACE_SIZE)
+		if !dht.InIntervalOpen(predecessorId, from.Id(), owner.Id()) {
+			continue
+		}
+		for i := range 1000 {
+			key := fmt.Sprintf("key-%d", i)
+			if !dht.InIntervalRightInclusive(dht.KeyToRingId(key, dht.ID_SPACE_SIZE), from.Id(), predecessorId) {
+				continue
+			}
+			body := []byte(`{"successor": "` + from.Address() + `", "predecessor": "` + predecessor + `"}`)
+			if w := serve(ring[owner.Address()], http.MethodPost, "/admin/links", body, nil); w.Code != http.StatusOK {
+				t.Fatalf("POST /admin/links: status %d: %s", w.Code, w.Body)
+			}
+			if from.NextHop(key) != owner.Address() || owner.NextHop(key) != from.Address() {
+				t.Fatalf("key %q routed %s -> %s -> %s, want a cycle", key, from.Address(), from.NextHop(key), owner.NextHop(key))
+			}
+			return key
+		}
+	}
+	t.Fatal("no predecessor and key found to wedge the owner")
+	return ""
+}
+
+func TestForwardCycleIsRefused(t *testing.T) {
+	nodes := dht.BuildRing(2)
+	ring := newTestRing(t, nodes, WithAdmin(true))
+	a, b := nodes[0], nodes[1]
+	key := wedgeOwner(t, ring, a, b)
+
+	// a -> b -> a, a finds itself in the visited nodes and stops
+	chain := []string{a.Address(), b.Address(), a.Address()}
+	for _, method := range []string{http.MethodGet, http.MethodPut} {
+		w := serve(ring[a.Address()], method, "/storage/"+key, []byte("value"), nil)
+		if w.Code != http.StatusLoopDetected {
+			t.Fatalf("%s through a cycle: status %d, want 508: %s", method, w.Code, w.Body)
+		}
+		if got := w.Header().Get(visitedHeader); got != strings.Join(chain, ",") {
+			t.Errorf("%s through a cycle: %s = %q, want %q", method, visitedHeader, got, strings.Join(chain, ","))
+		}
+		if !strings.Contains(w.Body.String(), strings.Join(chain, " -> ")) {
+			t.Errorf("%s through a cycle: body %q, want the chain %s", method, w.Body, strings.Join(chain, " -> "))
+		}
+	}
+	for _, node := range nodes {
+		if slices.Contains(node.LocalKeys(), key) {
+			t.Errorf("key of the refused PUT stored on %s", node.Address())
+		}
+	}
+}
+
+func TestForwardToSelfIsRefused(t *testing.T) {
+	nodes := dht.BuildRing(2)
+	ring := newTestRing(t, nodes, WithAdmin(true))
+	a, b := nodes[0], nodes[1]
+	key := forwardedKey(t, a)
+
+	// a is its own successor but not the owner, routing points back at itself
+	body := []byte(`{"successor": "` + a.Address() + `", "predecessor": "` + b.Address() + `"}`)
+	if w := serve(ring[a.Address()], http.MethodPost, "/admin/links", body, nil); w.Code != http.StatusOK {
+		t.Fatalf("POST /admin/links: status %d: %s", w.Code, w.Body)
+	}
+	if next := a.NextHop(key); next != a.Address() {
+		t.Fatalf("next hop of %q = %q, want the node itself", key, next)
+	}
+
+	w := serve(ring[a.Address()], http.MethodGet, "/storage/"+key, nil, nil)
+	if w.Code != http.StatusLoopDetected || w.Header().Get(visitedHeader) != a.Address() {
+		t.Errorf("GET forwarded to self: status %d, %s %q, want 508 with only the node: %s", w.Code, visitedHeader, w.Header().Get(visitedHeader), w.Body)
+	}
+}
+
+func TestForwardStopsAtMaxHops(t *testing.T) {
+	nodes := dht.BuildRing(8)
+	entry := nodes[0]
+
+	// A key reached over at least two forwards of distinct nodes
+	key := ""
+	for i := 0; i < 1000 && key == ""; i++ {
+		candidate := fmt.Sprintf("key-%d", i)
+		if next := entry.NextHop(candidate); next != "" && nodeByAddress(nodes, next).NextHop(candidate) != "" {
+			key = candidate
+		}
+	}
+	if key == "" {
+		t.Fatal("no key takes two forwards")
+	}
+
+	ring := newTestRing(t, nodes, WithMaxHops(1))
+	w := serve(ring[entry.Address()], http.MethodGet, "/storage/"+key, nil, nil)
+	if w.Code != http.StatusLoopDetected || !strings.Contains(w.Body.String(), "hop limit of 1") {
+		t.Errorf("GET past -max-hops 1: status %d, body %q, want 508 naming the hop limit", w.Code, w.Body)
+	}
+
+	// The default limit lets the same request through
+	ring = newTestRing(t, nodes)
+	if w := serve(ring[entry.Address()], http.MethodGet, "/storage/"+key, nil, nil); w.Code != http.StatusNotFound {
+		t.Errorf("GET within the hop limit: status %d, want 404 from the owner: %s", w.Code, w.Body)
+	}
+}
+
+// nodeByAddress returns the node of the ring with the address
+func nodeByAddress(nodes []*dht.Node, addr string) *dht.Node {
+	for _, node := range nodes {
+		if node.Address() == addr {
+			return node
+		}
+	}
+	return nil
+}
+
+func TestOwnerReturnsHopCount(t *testing.T) {
+	nodes := dht.BuildRing(4)
+	ring := newTestRing(t, nodes)
+	entry := nodes[0]
+
+	most := 0
+	for i := range 50 {
+		key := fmt.Sprintf("key-%d", i)
+
+		// Hops the key takes from the entry node to its owner
+		want := 0
+		for next := entry.NextHop(key); next != ""; next = nodeByAddress(nodes, next).NextHop(key) {
+			want++
+		}
+		most = max(most, want)
+
+		if w := serve(ring[entry.Address()], http.MethodPut, "/storage/"+key, []byte("value"), nil); w.Code != http.StatusOK || w.Header().Get(hopCountHeader) != strconv.Itoa(want) {
+			t.Errorf("PUT %q: status %d, %s %q, want 200 after %d hops", key, w.Code, hopCountHeader, w.Header().Get(hopCountHeader), want)
+		}
+		if w := serve(ring[entry.Address()], http.MethodGet, "/storage/"+key, nil, nil); w.Code != http.StatusOK || w.Header().Get(hopCountHeader) != strconv.Itoa(want) {
+			t.Errorf("GET %q: status %d, %s %q, want 200 after %d hops", key, w.Code, hopCountHeader, w.Header().Get(hopCountHeader), want)
+		}
+	}
+	if most < 2 {
+		t.Errorf("keys took at most %d hops, want a key forwarded over several nodes", most)
+	}
+}
+
+func TestHeadReportsLengthWithoutBody(t *testing.T) {
+	nodes := dht.BuildRing(2)
+	ring := newTestRing(t, nodes)
+	entry := ring[nodes[0].Address()]
+	server := httptest.NewServer(entry.server.Handler)
+	defer server.Close()
+	large := bytes.Repeat([]byte("0123456789"), 100_000)
+
+	// The owner sees the HEAD itself, not a GET
+	var methods []string
+	forward := entry.forwardClient.Transport
+	entry.forwardClient.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		methods = append(methods, r.Method)
+		return forward.RoundTrip(r)
+	})
+
+	head := func(key string) (*http.Response, []byte) {
+		t.Helper()
+		resp, err := http.Head(server.URL + "/storage/" + key)
+		if err != nil {
+			t.Fatalf("HEAD %q: %v", key, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, body
+	}
+
+	for _, key := range []string{putOwned(t, nodes[0]), forwardedKey(t, nodes[0])} {
+		if w := serve(entry, http.MethodPut, "/storage/"+key, large, nil); w.Code != http.StatusOK {
+			t.Fatalf("PUT %q: status %d: %s", key, w.Code, w.Body)
+		}
+		resp, body := head(key)
+		if resp.StatusCode != http.StatusOK || resp.ContentLength != int64(len(large)) || len(body) != 0 {
+			t.Errorf("HEAD %q: status %d, Content-Length %d, %d body bytes, want 200 with length %d and no body", key, resp.StatusCode, resp.ContentLength, len(body), len(large))
+		}
+		if resp.Header.Get(etagHeader) == "" {
+			t.Errorf("HEAD %q: no %s", key, etagHeader)
+		}
+	}
+	if !slices.Contains(methods, http.MethodHead) {
+		t.Errorf("methods forwarded to the owner = %v, want a HEAD", methods)
+	}
+
+	for _, key := range []string{"missing-0", "missing-1", "missing-2"} {
+		if resp, body := head(key); resp.StatusCode != http.StatusNotFound || len(body) != 0 {
+			t.Errorf("HEAD of the missing key %q: status %d, %d body bytes, want 404 without a body", key, resp.StatusCode, len(body))
+		}
+	}
+}
+
+func TestGetConsistencyLevels(t *testing.T) {
+	nodes := dht.BuildRing(3, dht.WithReplicationFactor(3), dht.WithSuccessorListSize(2))
+	for range 3 {
+		for _, node := range nodes {
+			node.Stabilize(context.Background())
+		}
+	}
+	ring := newTestRing(t, nodes)
+
+	// The owner and one replica hold the value, the other replica a stale copy
+	key := forwardedKey(t, nodes[0])
+	owner := nodeByAddress(nodes, nodes[0].NextHop(key))
+	for owner.NextHop(key) != "" {
+		owner = nodeByAddress(nodes, owner.NextHop(key))
+	}
+	if _, _, err := owner.Put(key, []byte("value"), 0); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	_, stale := owner.Predecessor()
+	nodeByAddress(nodes, stale).AcceptReplicas(map[string]dht.Versioned{key: {Value: []byte("stale"), Version: 7}})
+
+	// Read through a node forwarding to the owner, all before quorum as a successful read repairs the copies
+	tr := ring[nodes[0].Address()]
+	tests := []struct {
+		query string
+		code  int
+		body  string
+	}{
+		{"?consistency=all", http.StatusConflict, ""},
+		{"?consistency=most", http.StatusBadRequest, ""},
+		{"?consistency=quorum", http.StatusOK, "value"},
+		{"", http.StatusOK, "value"},
+	}
+	for _, tt := range tests {
+		w := serve(tr, http.MethodGet, "/storage/"+key+tt.query, nil, nil)
+		if w.Code != tt.code || (tt.body != "" && w.Body.String() != tt.body) {
+			t.Errorf("GET %s%s: status %d, body %q, want %d %q", key, tt.query, w.Code, w.Body, tt.code, tt.body)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"assignment/internal/dht"
+	"assignment/internal/metrics"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// observedRoundTripper reports the outcome of every ring RPC sent by the fast client to the node,
+// which stretches its maintenance interval while peers answer 503 or time out, and records the
+// latency of the answered ones by peer host
+type observedRoundTripper struct {
+	next      http.RoundTripper
+	observe   func(overloaded bool)
+	latencies *dht.PeerLatencies
+}
+
+// RoundTrip sends the request and reports whether the peer appeared overloaded.
+// A 503 of a crashed node is a failure, not a sign of load, and is not counted as overloaded.
+func (o *observedRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := o.next.RoundTrip(req)
+	if err == nil {
+		o.latencies.Observe(req.URL.Host, time.Since(start))
+	}
+	switch {
+	case err != nil:
+		o.report(isTimeout(err))
+	case resp.StatusCode == http.StatusServiceUnavailable:
+		o.report(resp.Header.Get(unavailableReasonHeader) != reasonCrashed)
+	default:
+		o.report(false)
+	}
+	return resp, err
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped round tripper, which
+// http.Client.CloseIdleConnections can't reach through the wrapper otherwise
+func (o *observedRoundTripper) CloseIdleConnections() {
+	if closer, ok := o.next.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// report passes the outcome on to the node and counts it
+func (o *observedRoundTripper) report(overloaded bool) {
+	metrics.RingRPCs.WithLabelValues(strconv.FormatBool(overloaded)).Inc()
+	o.observe(overloaded)
+}
+
+// isTimeout reports whether the error is a deadline or network timeout
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.As(err, &ne) && ne.Timeout()
+}
+
+// observeGRPC reports the outcome of a ring RPC sent over gRPC like observedRoundTripper.
+// gRPC maps a refused call and a refused connection alike to Unavailable, both count as
+// overloaded unless the peer refused the call as crashed.
+func (g *GRPCTransport) observeGRPC(err error) {
+	s := status.Convert(err)
+	overloaded := s.Code() == codes.DeadlineExceeded ||
+		s.Code() == codes.Unavailable && s.Message() != unavailableMessage(reasonCrashed)
+	metrics.RingRPCs.WithLabelValues(strconv.FormatBool(overloaded)).Inc()
+	g.observeRPC(overloaded)
+}
@@ -0,0 +1,67 @@
+package transport
+
+import (
+	"assignment/internal/dht"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// configureTLS loads the certificate and CA pool and switches the transport to https.
+// The certificate is served on both listeners, peers are verified against the CA pool,
+// or the system pool if no CA file is configured.
+func (t *HTTPTransport) configureTLS() (*tls.Config, error) {
+
+	cert, err := tls.LoadX509KeyPair(t.tlsCertFile, t.tlsKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tls certificate: %w", err)
+	}
+
+	clientConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if t.tlsCAFile != "" {
+		pem, err := os.ReadFile(t.tlsCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tls ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in tls ca file '%s'", t.tlsCAFile)
+		}
+		clientConfig.RootCAs = pool
+	}
+
+	t.scheme = "https"
+	t.clientTLS = clientConfig
+	t.pool.TLSClientConfig = clientConfig
+
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}, nil
+}
+
+// url returns the url of the path on the node at the address, with the transport's scheme.
+// The vnode of a vnode address is passed as "?vnode=" to its process.
+func (t *HTTPTransport) url(addr string, path string) string {
+	host, vnode := dht.SplitVnode(addr)
+	if vnode == 0 {
+		return t.scheme + "://" + host + path
+	}
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return t.scheme + "://" + host + path + separator + vnodeParam + "=" + strconv.Itoa(vnode)
+}
+
+// listenAndServe serves the server over https if TLS is configured, otherwise plain http
+func (t *HTTPTransport) listenAndServe(server *http.Server) error {
+	if server.TLSConfig != nil {
+		return server.ListenAndServeTLS("", "")
+	}
+	return server.ListenAndServe()
+}
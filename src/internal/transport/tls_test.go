@@ -0,0 +1,141 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"assignment/internal/dht"
+)
+
+// writeTestPKI writes a self-signed CA and a certificate for 127.0.0.1 signed by it to the
+// directory, and returns the paths of the certificate, its key and the CA
+func writeTestPKI(t *testing.T, dir string) (certFile string, keyFile string, caFile string) {
+	t.Helper()
+
+	writePEM := func(name string, kind string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate the ca key: %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create the ca certificate: %v", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate the node key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caTemplate, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create the node certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal the node key: %v", err)
+	}
+
+	return writePEM("node.pem", "CERTIFICATE", der), writePEM("node-key.pem", "EC PRIVATE KEY", keyDER), writePEM("ca.pem", "CERTIFICATE", caDER)
+}
+
+func TestJoinOverTLS(t *testing.T) {
+	certFile, keyFile, caFile := writeTestPKI(t, t.TempDir())
+	seed, seedTransport := startNode(t, WithTLS(certFile, keyFile, caFile))
+	joiner, joinerTransport := startNode(t, WithTLS(certFile, keyFile, caFile))
+	if seedTransport.scheme != "https" || joinerTransport.scheme != "https" {
+		t.Fatalf("transports use %q and %q, want https", seedTransport.scheme, joinerTransport.scheme)
+	}
+
+	// The lookup through the seed, the key pull and the notify all go over https
+	if err := joinerTransport.Join(seed.Address()); err != nil {
+		t.Fatalf("Join over https: %v", err)
+	}
+	if _, successor := joiner.Successor(); successor != seed.Address() {
+		t.Errorf("successor of the joined node = %s, want the seed %s", successor, seed.Address())
+	}
+	if _, predecessor := seed.Predecessor(); predecessor != joiner.Address() {
+		t.Errorf("predecessor of the seed = %s, want the joined node %s", predecessor, joiner.Address())
+	}
+
+	// A client trusting the CA reaches the node, one with only the system pool is refused
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		t.Fatalf("failed to read the ca: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caPEM)
+	trusting := &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := trusting.Get("https://" + seed.Address() + "/ping")
+	if err != nil {
+		t.Fatalf("GET /ping with the ca: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /ping with the ca: status %d, want 200", resp.StatusCode)
+	}
+
+	untrusting := &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{}}
+	_, err = untrusting.Get("https://" + seed.Address() + "/ping")
+	var unknownAuthority x509.UnknownAuthorityError
+	if !errors.As(err, &unknownAuthority) {
+		t.Errorf("GET /ping without the ca = %v, want an unknown authority error", err)
+	}
+
+	// Plain http is not served next to https
+	if resp, err := untrusting.Get("http://" + seed.Address() + "/ping"); err == nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("GET /ping over plain http: status %d, want 400", resp.StatusCode)
+		}
+	}
+}
+
+func TestTLSRefusesMissingCA(t *testing.T) {
+	certFile, keyFile, _ := writeTestPKI(t, t.TempDir())
+	node := dht.Create("127.0.0.1:1", dht.WithLogger(quietLogger()))
+
+	if _, err := New("127.0.0.1", "1", node, WithLogger(quietLogger()), WithTLS(certFile, keyFile, filepath.Join(t.TempDir(), "missing.pem"))); err == nil {
+		t.Error("New with a missing ca file succeeded")
+	}
+	if _, err := New("127.0.0.1", "1", node, WithLogger(quietLogger()), WithTLS(certFile, certFile, "")); err == nil {
+		t.Error("New with the certificate as its own key succeeded")
+	}
+}
@@ -0,0 +1,243 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Number of topology snapshots kept for "/topology/diff", the oldest is dropped first
+const topologyHistorySize = 16
+
+// nodeTopology is the per-node result of a "/topology" traversal
+type nodeTopology struct {
+	Node        string   `json:"node"`
+	Successor   string   `json:"successor"`
+	Predecessor string   `json:"predecessor"`
+	Fingers     []string `json:"fingers"`
+}
+
+// topologySnapshot is the ring topology captured by "/topology" under a snapshot id
+type topologySnapshot struct {
+	ID      string         `json:"id"`
+	TakenAt string         `json:"taken_at"`
+	Nodes   []nodeTopology `json:"nodes"`
+}
+
+// linkChange is a successor, predecessor or finger link that differs between two snapshots
+// A node missing from one of the snapshots has empty links there.
+type linkChange struct {
+	Node string `json:"node"`
+	Link string `json:"link"` // "successor", "predecessor" or "finger[i]"
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// topologyHistory is the bounded history of captured snapshots, oldest first
+type topologyHistory struct {
+	mu        sync.Mutex
+	snapshots []topologySnapshot
+	nextID    int
+}
+
+// add stores the snapshot, replacing a snapshot with the same id
+func (h *topologyHistory) add(snapshot topologySnapshot) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, s := range h.snapshots {
+		if s.ID == snapshot.ID {
+			h.snapshots = append(h.snapshots[:i], h.snapshots[i+1:]...)
+			break
+		}
+	}
+	h.snapshots = append(h.snapshots, snapshot)
+	if len(h.snapshots) > topologyHistorySize {
+		h.snapshots = h.snapshots[len(h.snapshots)-topologyHistorySize:]
+	}
+}
+
+// get returns the snapshot with the given id
+func (h *topologyHistory) get(id string) (topologySnapshot, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, s := range h.snapshots {
+		if s.ID == id {
+			return s, true
+		}
+	}
+	return topologySnapshot{}, false
+}
+
+// generateID returns a snapshot id for a request that did not name one
+func (h *topologyHistory) generateID() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.nextID++
+	return strconv.Itoa(h.nextID)
+}
+
+// handleTopology handles requests to the "/topology" path
+// Captures the successor, predecessor and finger links of every node, collected by walking the
+// ring like "/network", and stores them under "?snapshot=<id>" (generated if not given).
+// Hops forwarded by other nodes carry "?origin=" and only return the links collected so far.
+func (t *HTTPTransport) handleTopology(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Forwarded hop of a traversal
+	if origin := r.URL.Query().Get("origin"); origin != "" {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(t.collectTopology(r.Context(), origin)); err != nil {
+			http.Error(w, fmt.Sprintf("failed to encode topology: %v", err), http.StatusInternalServerError)
+			return
+		}
+		return
+	}
+
+	id := r.URL.Query().Get("snapshot")
+	if id == "" {
+		id = t.topology.generateID()
+	}
+
+	snapshot := topologySnapshot{
+		ID:      id,
+		TakenAt: formatTime(time.Now()),
+		Nodes:   t.collectTopology(r.Context(), t.vnode(r.Context()).Address()),
+	}
+	t.topology.add(snapshot)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(snapshot); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode topology: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// collectTopology returns the links of this node followed by the nodes up to the origin
+func (t *HTTPTransport) collectTopology(ctx context.Context, origin string) []nodeTopology {
+
+	node := t.vnode(ctx)
+
+	_, succAdr := node.Successor()
+	_, predAdr := node.Predecessor()
+
+	nodes := []nodeTopology{{
+		Node:        node.Address(),
+		Successor:   succAdr,
+		Predecessor: predAdr,
+		Fingers:     node.FingerTable(),
+	}}
+
+	// We keep forwarding the request until the ring is covered
+	if succAdr != origin && succAdr != node.Address() {
+		forwardURL := t.url(succAdr, "/topology?origin="+url.QueryEscape(origin))
+		resp, err := t.forwardedRequest(ctx, http.MethodGet, forwardURL, nil)
+		if err == nil {
+			defer resp.Body.Close()
+			var succNodes []nodeTopology
+			if err := json.NewDecoder(resp.Body).Decode(&succNodes); err == nil {
+				nodes = append(nodes, succNodes...)
+			}
+		} else {
+			t.logger.Error("Topology", "failed to forward topology request to successor", "successor", succAdr, "err", err)
+		}
+	}
+
+	return nodes
+}
+
+// handleTopologyDiff handles requests to the "/topology/diff" path
+// Returns the links that changed between the snapshots "?from=<id>" and "?to=<id>", both must
+// still be in the history.
+func (t *HTTPTransport) handleTopologyDiff(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	fromID, toID := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	from, ok := t.topology.get(fromID)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown snapshot '%s'", fromID), http.StatusNotFound)
+		return
+	}
+	to, ok := t.topology.get(toID)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown snapshot '%s'", toID), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(diffTopology(from.Nodes, to.Nodes)); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode topology diff: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// diffTopology returns the changed links, in the ring order of the "to" snapshot followed by
+// the nodes that are only in the "from" snapshot
+func diffTopology(from []nodeTopology, to []nodeTopology) []linkChange {
+
+	links := func(n nodeTopology) map[string]string {
+		m := map[string]string{"successor": n.Successor, "predecessor": n.Predecessor}
+		for i, finger := range n.Fingers {
+			m[fmt.Sprintf("finger[%d]", i)] = finger
+		}
+		return m
+	}
+
+	fromNodes := make(map[string]nodeTopology, len(from))
+	for _, n := range from {
+		fromNodes[n.Node] = n
+	}
+
+	compare := func(node string, before, after map[string]string) []linkChange {
+		var changes []linkChange
+		names := []string{"successor", "predecessor"}
+		for i := 0; ; i++ {
+			name := fmt.Sprintf("finger[%d]", i)
+			_, inBefore := before[name]
+			_, inAfter := after[name]
+			if !inBefore && !inAfter {
+				break
+			}
+			names = append(names, name)
+		}
+		for _, name := range names {
+			if before[name] != after[name] {
+				changes = append(changes, linkChange{Node: node, Link: name, From: before[name], To: after[name]})
+			}
+		}
+		return changes
+	}
+
+	changes := []linkChange{}
+	seen := make(map[string]bool, len(to))
+	for _, n := range to {
+		seen[n.Node] = true
+		before := map[string]string{}
+		if prev, ok := fromNodes[n.Node]; ok {
+			before = links(prev)
+		}
+		changes = append(changes, compare(n.Node, before, links(n))...)
+	}
+	for _, n := range from {
+		if !seen[n.Node] {
+			changes = append(changes, compare(n.Node, links(n), map[string]string{})...)
+		}
+	}
+
+	return changes
+}
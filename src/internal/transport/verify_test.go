@@ -0,0 +1,75 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+
+	"assignment/internal/dht"
+)
+
+// getVerify returns the /verify report of the transport's node
+func getVerify(t *testing.T, tr *HTTPTransport) verifyResult {
+	t.Helper()
+	w := serve(tr, http.MethodGet, "/verify", nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /verify: status %d: %s", w.Code, w.Body)
+	}
+	var result verifyResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode verify result: %v", err)
+	}
+	return result
+}
+
+func TestVerifyReportsStaleFingers(t *testing.T) {
+	net := dht.NewMemoryNetwork()
+	nodes := net.BuildRing(8)
+	tr := newTestTransport(t, nodes[0])
+	ctx := context.Background()
+
+	if result := getVerify(t, tr); !result.Consistent || len(result.Mismatches) != 0 {
+		t.Fatalf("verify on a stable ring = %+v, want consistent", result)
+	}
+
+	// Nodes join and the links settle, but no finger is fixed
+	ring := slices.Clone(nodes)
+	for i := range 6 {
+		joining := dht.Create(fmt.Sprintf("late-%d", i), dht.WithLogger(quietLogger()))
+		net.Add(joining)
+		successorAddr, err := nodes[0].FindSuccessor(ctx, joining.Id())
+		if err != nil {
+			t.Fatalf("FindSuccessor: %v", err)
+		}
+		if err := joining.Join(successorAddr); err != nil {
+			t.Fatalf("Join: %v", err)
+		}
+		ring = append(ring, joining)
+	}
+	for range 5 {
+		for _, node := range ring {
+			node.Stabilize(ctx)
+		}
+	}
+
+	before := nodes[0].FingerTable()
+	result := getVerify(t, tr)
+	if result.Consistent || len(result.Mismatches) == 0 {
+		t.Errorf("verify after the joins = %+v, want the stale fingers reported", result)
+	}
+	for _, mismatch := range result.Mismatches {
+		if mismatch.Expected == "" || mismatch.Expected == mismatch.Finger {
+			t.Errorf("mismatch %+v, want the finger and a different expected node", mismatch)
+		}
+	}
+	if after := nodes[0].FingerTable(); !slices.Equal(before, after) {
+		t.Errorf("verify changed the finger table from %v to %v", before, after)
+	}
+
+	if w := serve(tr, http.MethodPost, "/verify", nil, nil); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /verify: status %d, want 405", w.Code)
+	}
+}
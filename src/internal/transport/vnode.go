@@ -0,0 +1,90 @@
+package transport
+
+import (
+	"assignment/internal/dht"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Query parameter addressing a request to a virtual node of the process, see dht.VnodeAddress
+const vnodeParam = "vnode"
+
+// gRPC metadata key addressing a ring RPC to a virtual node of the process
+const vnodeMetadata = "x-dht-vnode"
+
+// vnodeKey is the context key of the index of the vnode a request is addressed to
+type vnodeKey struct{}
+
+// withVnode returns the context of a request addressed to the i-th vnode
+func withVnode(ctx context.Context, i int) context.Context {
+	return context.WithValue(ctx, vnodeKey{}, i)
+}
+
+// vnode returns the vnode the request of the context is addressed to, the primary node if none
+func (t *HTTPTransport) vnode(ctx context.Context) dht.INode {
+	if i, ok := ctx.Value(vnodeKey{}).(int); ok && i >= 0 && i < len(t.vnodes) {
+		return t.vnodes[i]
+	}
+	return t.node
+}
+
+// Vnodes returns the ring positions served by the process, the primary node first
+func (t *HTTPTransport) Vnodes() []dht.INode {
+	return t.vnodes
+}
+
+// localVnode returns the index of the vnode at the ring address if it is served by this process
+func (t *HTTPTransport) localVnode(addr string) (int, bool) {
+	host, i := dht.SplitVnode(addr)
+	return i, host == t.address && i < len(t.vnodes)
+}
+
+// parseVnode returns the vnode index of a "?vnode=" query value, false if the process has no such vnode
+func (t *HTTPTransport) parseVnode(value string) (int, bool) {
+	i, err := strconv.Atoi(value)
+	return i, err == nil && i >= 0 && i < len(t.vnodes)
+}
+
+// vnodeMiddleware scopes a request with a "?vnode=" query to that vnode, see vnode
+func (t *HTTPTransport) vnodeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value := r.URL.Query().Get(vnodeParam)
+		if value == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		i, ok := t.parseVnode(value)
+		if !ok {
+			http.Error(w, "unknown vnode "+value, http.StatusNotFound)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withVnode(r.Context(), i)))
+	})
+}
+
+// serveLocalVnode serves a storage hop to the i-th vnode of this process in process instead of
+// over the network, marked as forwarded like a hop sent by forwardRequest
+func (t *HTTPTransport) serveLocalVnode(w http.ResponseWriter, r *http.Request, i int, body io.Reader) {
+
+	local := r.Clone(withVnode(r.Context(), i))
+	local.Header.Set(hopCountHeader, strconv.Itoa(hopCount(r.Header)+1))
+	local.Header.Set(visitedHeader, strings.Join(append(visitedNodes(r.Header), t.vnode(r.Context()).Address()), ","))
+	local.Header.Set(forwardedHeader, t.address)
+	local.Body = http.NoBody
+	if body != nil {
+		local.Body = io.NopCloser(body)
+	}
+
+	t.handleStorage(w, local)
+}
+
+// observeRPC feeds the outcome of a ring RPC to the maintenance throttle of every vnode, they
+// share the process's connections to the peers
+func (t *HTTPTransport) observeRPC(overloaded bool) {
+	for _, node := range t.vnodes {
+		node.ObserveRPC(overloaded)
+	}
+}